@@ -2,6 +2,8 @@ package cache
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -153,6 +155,66 @@ func (c *CacheService) GetJSON(key string, dest interface{}) bool {
 	return err == nil
 }
 
+// releaseLockScript 仅当锁仍由自己持有时才删除，避免误删其他请求在锁过期后获取的锁
+var releaseLockScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLock 获取分布式锁（SET NX + 过期时间），返回释放函数和是否获取成功；
+// Redis 未连接时直接返回成功，由调用方使用数据库乐观锁兜底
+func (c *CacheService) AcquireLock(key string, ttl time.Duration) (func(), bool, error) {
+	if RedisClient == nil {
+		return func() {}, true, nil
+	}
+
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, false, err
+	}
+	token := hex.EncodeToString(buf)
+
+	ok, err := RedisClient.SetNX(c.ctx, key, token, ttl).Result()
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	release := func() {
+		if err := releaseLockScript.Run(c.ctx, RedisClient, []string{key}, token).Err(); err != nil && err != redis.Nil {
+			log.Printf("⚠️ 释放锁失败: %s, %v", key, err)
+		}
+	}
+	return release, true, nil
+}
+
+// Publish 发布JSON格式的消息到频道，返回是否已通过 Redis 发布；Redis 未连接时返回 false，由调用方在本地处理
+func (c *CacheService) Publish(channel string, value interface{}) (bool, error) {
+	if RedisClient == nil {
+		return false, nil
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	if err := RedisClient.Publish(c.ctx, channel, data).Err(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Subscribe 订阅频道，Redis 未连接时返回 nil
+func (c *CacheService) Subscribe(channel string) *redis.PubSub {
+	if RedisClient == nil {
+		return nil
+	}
+
+	return RedisClient.Subscribe(c.ctx, channel)
+}
+
 // 缓存键名常量
 const (
 	// 匹配池相关缓存键
@@ -168,6 +230,14 @@ const (
 	// 统计信息缓存键
 	CacheKeyStats     = "stats:general"
 	CacheKeyPoolStats = "stats:pool:%d"
+
+	// 分布式锁键
+	CacheKeyPoolMatchLock = "lock:pool:%d:match"
+)
+
+// 发布订阅频道常量
+const (
+	ChannelPoolEvents = "events:pools" // 匹配池实时事件
 )
 
 // 缓存过期时间常量
@@ -202,3 +272,8 @@ func GenerateHistoryKey(historyID int) string {
 func GeneratePoolStatsKey(poolID int) string {
 	return fmt.Sprintf(CacheKeyPoolStats, poolID)
 }
+
+// GeneratePoolMatchLockKey 生成匹配池匹配锁的键
+func GeneratePoolMatchLockKey(poolID int) string {
+	return fmt.Sprintf(CacheKeyPoolMatchLock, poolID)
+}
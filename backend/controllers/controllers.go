@@ -3,9 +3,13 @@ package controllers
 import (
 	"christmas-link-backend/models"
 	"christmas-link-backend/services"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -13,16 +17,36 @@ import (
 
 // PoolController 匹配池控制器
 type PoolController struct {
-	poolService *services.PoolService
+	poolService       *services.PoolService
+	lifecycleService  *services.LifecycleService
+	visibilityService *services.VisibilityService
+	duplicateService  *services.DuplicateService
+	eventBroker       *services.EventBroker
 }
 
 // NewPoolController 创建匹配池控制器实例
 func NewPoolController(db *gorm.DB) *PoolController {
 	return &PoolController{
-		poolService: services.NewPoolService(db),
+		poolService:       services.NewPoolService(db),
+		lifecycleService:  services.NewLifecycleService(db),
+		visibilityService: services.NewVisibilityService(db),
+		duplicateService:  services.NewDuplicateService(db),
+		eventBroker:       services.GetEventBroker(),
 	}
 }
 
+// requestViewer 识别请求者身份：管理员令牌或参与者管理令牌，都未提供时为匿名访问者。
+// 联系方式可以被猜到，不作为参与者身份的凭证
+func requestViewer(c *gin.Context, visibilityService *services.VisibilityService) (*services.Viewer, error) {
+	if c.GetHeader("Authorization") == "Bearer admin_authenticated" {
+		return &services.Viewer{Admin: true}, nil
+	}
+	if token := participantToken(c); token != "" {
+		return visibilityService.TokenViewer(token)
+	}
+	return &services.Viewer{}, nil
+}
+
 // CreatePool 创建匹配池
 func (pc *PoolController) CreatePool(c *gin.Context) {
 	var req models.CreatePoolRequest
@@ -101,6 +125,188 @@ func (pc *PoolController) GetPoolByID(c *gin.Context) {
 	})
 }
 
+// PoolEvents 以 Server-Sent Events 推送匹配池的实时事件：连接建立时先推送 snapshot（匹配池当前信息），
+// 之后推送参与者变动、状态变更和匹配结果，匹配结果按请求者身份隐藏字段；每 25 秒发送一次心跳注释
+func (pc *PoolController) PoolEvents(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的匹配池ID",
+			"data":    nil,
+		})
+		return
+	}
+	poolID := uint(id)
+
+	viewer, err := requestViewer(c, pc.visibilityService)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	// 先订阅再读取匹配池信息，避免漏掉两者之间发生的事件
+	events, cancel := pc.eventBroker.Subscribe(poolID)
+	defer cancel()
+
+	pool, err := pc.poolService.GetPoolByID(poolID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "匹配池不存在",
+			"data":    nil,
+		})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁用 Nginx 缓冲
+	c.Status(http.StatusOK)
+
+	snapshot, _ := json.Marshal(pool)
+	if !writePoolEvent(c, models.PoolEvent{
+		Type:      models.PoolEventSnapshot,
+		PoolID:    poolID,
+		Data:      snapshot,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	}) {
+		return
+	}
+
+	heartbeat := time.NewTicker(25 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		case event, ok := <-events:
+			if !ok {
+				// 处理过慢被断开，客户端重连后会重新收到 snapshot
+				return
+			}
+			if event.Type == models.PoolEventMatchCompleted {
+				if event.Data, err = pc.redactMatchEvent(event.Data, viewer); err != nil {
+					log.Printf("⚠️ 隐藏匹配结果字段失败: %v", err)
+					continue
+				}
+			}
+			if !writePoolEvent(c, event) || event.Type == models.PoolEventPoolDeleted {
+				return
+			}
+		}
+	}
+}
+
+// redactMatchEvent 按请求者身份隐藏匹配结果事件中无权查看的用户数据
+func (pc *PoolController) redactMatchEvent(data json.RawMessage, viewer *services.Viewer) (json.RawMessage, error) {
+	var result models.MatchResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	redacted, err := pc.visibilityService.Redact(&result, *viewer)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(redacted)
+}
+
+// writePoolEvent 写出一条 SSE 事件，事件名为事件类型，数据为完整事件的 JSON；写入失败（连接已断开）时返回 false
+func writePoolEvent(c *gin.Context, event models.PoolEvent) bool {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return false
+	}
+	if _, err := fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return false
+	}
+	c.Writer.Flush()
+	return true
+}
+
+// GetPoolStats 获取匹配池选择类字段的作答统计
+func (pc *PoolController) GetPoolStats(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的匹配池ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	admin := c.GetHeader("Authorization") == "Bearer admin_authenticated"
+	stats, err := pc.poolService.GetPoolStats(uint(id), admin)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取匹配池统计成功",
+		"data":    stats,
+	})
+}
+
+// GetDuplicates 获取匹配池中疑似重复报名的用户（管理员）
+func (pc *PoolController) GetDuplicates(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的匹配池ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	groups, err := pc.duplicateService.FindDuplicates(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("发现 %d 组疑似重复报名", len(groups)),
+		"data":    groups,
+	})
+}
+
 // JoinPool 加入匹配池
 func (pc *PoolController) JoinPool(c *gin.Context) {
 	var req models.JoinPoolRequest
@@ -113,8 +319,34 @@ func (pc *PoolController) JoinPool(c *gin.Context) {
 		return
 	}
 
-	err := pc.poolService.JoinPool(&req)
+	result, err := pc.poolService.JoinPool(&req)
 	if err != nil {
+		// 重复报名，标出与已有报名相同的字段
+		var duplicateErr *services.DuplicateJoinError
+		if errors.As(err, &duplicateErr) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "已有相同的报名，请勿重复报名",
+				"data": gin.H{
+					"fieldErrors": duplicateErr.Fields,
+				},
+			})
+			return
+		}
+
+		// 逐字段的校验错误，前端据此标出填写有误的字段
+		var fieldErrors services.FieldErrors
+		if errors.As(err, &fieldErrors) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "用户数据校验失败，请检查填写的内容",
+				"data": gin.H{
+					"fieldErrors": fieldErrors,
+				},
+			})
+			return
+		}
+
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -123,10 +355,18 @@ func (pc *PoolController) JoinPool(c *gin.Context) {
 		return
 	}
 
+	message := "加入匹配池成功"
+	if result.Waitlisted {
+		message = fmt.Sprintf("匹配池已满，已加入候补名单（第 %d 位），有人退出时将自动递补", result.WaitlistPosition)
+	}
+	if result.PendingVerification {
+		message += "，验证码已发送到你的邮箱，验证通过后才会参与匹配"
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "加入匹配池成功",
-		"data":    nil,
+		"message": message,
+		"data":    result,
 	})
 }
 
@@ -143,6 +383,18 @@ func (pc *PoolController) StartMatch(c *gin.Context) {
 		return
 	}
 
+	req.IdempotencyKey = c.GetHeader("Idempotency-Key")
+
+	viewer, err := requestViewer(c, pc.visibilityService)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
 	log.Printf("🎯 开始匹配请求: PoolID=%d", req.PoolID)
 	result, err := pc.poolService.StartMatch(&req)
 	if err != nil {
@@ -155,32 +407,12 @@ func (pc *PoolController) StartMatch(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "匹配完成",
-		"data":    result,
-	})
-}
-
-// HistoryController 历史记录控制器
-type HistoryController struct {
-	historyService *services.HistoryService
-}
-
-// NewHistoryController 创建历史记录控制器实例
-func NewHistoryController(db *gorm.DB) *HistoryController {
-	return &HistoryController{
-		historyService: services.NewHistoryService(db),
-	}
-}
-
-// GetHistory 获取匹配历史记录
-func (hc *HistoryController) GetHistory(c *gin.Context) {
-	history, err := hc.historyService.GetHistory()
+	// 按字段可见性隐藏请求者无权查看的用户数据
+	result, err = pc.visibilityService.Redact(result, *viewer)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "获取历史记录失败: " + err.Error(),
+			"message": "获取匹配结果失败: " + err.Error(),
 			"data":    nil,
 		})
 		return
@@ -188,48 +420,49 @@ func (hc *HistoryController) GetHistory(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "获取历史记录成功",
-		"data":    history,
+		"message": "匹配完成",
+		"data":    result,
 	})
 }
 
-// GetHistoryByID 根据ID获取历史记录详情
-func (hc *HistoryController) GetHistoryByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+// UpdatePool 更新匹配池（PUT 和 PATCH 均只更新请求中提供的字段）
+func (pc *PoolController) UpdatePool(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
 			"success": false,
-			"message": "无效的历史记录ID",
+			"message": "需要管理员权限",
 			"data":    nil,
 		})
 		return
 	}
 
-	record, err := hc.historyService.GetHistoryByID(uint(id))
+	poolID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "历史记录不存在",
+			"message": "无效的匹配池ID",
 			"data":    nil,
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "获取历史记录详情成功",
-		"data":    record,
-	})
-}
+	var req models.UpdatePoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
 
-// GetStatistics 获取统计信息
-func (hc *HistoryController) GetStatistics(c *gin.Context) {
-	stats, err := hc.historyService.GetStatistics()
+	pool, err := pc.poolService.UpdatePool(uint(poolID), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "获取统计信息失败: " + err.Error(),
+			"message": "更新匹配池失败: " + err.Error(),
 			"data":    nil,
 		})
 		return
@@ -237,77 +470,1181 @@ func (hc *HistoryController) GetStatistics(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "获取统计信息成功",
-		"data":    stats,
+		"message": "更新匹配池成功",
+		"data":    pool,
 	})
 }
 
-// UserController 用户控制器
-type UserController struct {
-	userService *services.UserService
-}
-
-// NewUserController 创建用户控制器实例
-func NewUserController(db *gorm.DB) *UserController {
-	return &UserController{
-		userService: services.NewUserService(db),
+// DeletePool 删除匹配池，已有匹配记录时默认归档，?purge=true 时连同匹配记录一起删除
+func (pc *PoolController) DeletePool(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
 	}
-}
 
-// SearchUsers 搜索用户
-func (uc *UserController) SearchUsers(c *gin.Context) {
-	var req services.SearchUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	poolID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "请求参数错误: " + err.Error(),
+			"message": "无效的匹配池ID",
 			"data":    nil,
 		})
 		return
 	}
 
-	users, err := uc.userService.SearchUsers(&req)
+	archived, err := pc.poolService.DeletePool(uint(poolID), c.Query("purge") == "true")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "搜索用户失败: " + err.Error(),
+			"message": "删除匹配池失败: " + err.Error(),
 			"data":    nil,
 		})
 		return
 	}
 
+	message := "删除匹配池成功"
+	if archived {
+		message = "匹配池已有匹配记录，已归档以保留历史（如需彻底删除请使用 ?purge=true）"
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"message": "搜索用户成功",
-		"data":    users,
+		"message": message,
+		"data": gin.H{
+			"archived": archived,
+		},
 	})
 }
 
-// RemoveUser 移除用户
-func (uc *UserController) RemoveUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.ParseUint(idStr, 10, 32)
+// TransitionPool 变更匹配池的生命周期状态
+func (pc *PoolController) TransitionPool(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	poolID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "无效的用户ID",
+			"message": "无效的匹配池ID",
 			"data":    nil,
 		})
 		return
 	}
 
-	if err := uc.userService.RemoveUser(uint(id)); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	var req models.TransitionPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "移除用户失败: " + err.Error(),
+			"message": "请求参数错误: " + err.Error(),
 			"data":    nil,
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "移除用户成功",
+	pool, err := pc.lifecycleService.TransitionPool(uint(poolID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "匹配池状态已变更",
+		"data":    pool,
+	})
+}
+
+// ConstraintController 排除约束控制器
+type ConstraintController struct {
+	constraintService *services.ConstraintService
+}
+
+// NewConstraintController 创建排除约束控制器实例
+func NewConstraintController(db *gorm.DB) *ConstraintController {
+	return &ConstraintController{
+		constraintService: services.NewConstraintService(db),
+	}
+}
+
+// GetConstraints 获取匹配池的排除约束列表
+func (cc *ConstraintController) GetConstraints(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	poolID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的匹配池ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	constraints, err := cc.constraintService.GetConstraints(uint(poolID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取排除约束成功",
+		"data":    constraints,
+	})
+}
+
+// CreateConstraint 为匹配池添加排除约束
+func (cc *ConstraintController) CreateConstraint(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	poolID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的匹配池ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	var req models.CreateConstraintRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	constraint, err := cc.constraintService.CreateConstraint(uint(poolID), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "添加排除约束成功",
+		"data":    constraint,
+	})
+}
+
+// DeleteConstraint 删除匹配池的排除约束
+func (cc *ConstraintController) DeleteConstraint(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	poolID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的匹配池ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	constraintID, err := strconv.ParseUint(c.Param("constraintId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的约束ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := cc.constraintService.DeleteConstraint(uint(poolID), uint(constraintID)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "删除排除约束成功",
+		"data":    nil,
+	})
+}
+
+// HistoryController 历史记录控制器
+type HistoryController struct {
+	historyService    *services.HistoryService
+	visibilityService *services.VisibilityService
+}
+
+// NewHistoryController 创建历史记录控制器实例
+func NewHistoryController(db *gorm.DB) *HistoryController {
+	return &HistoryController{
+		historyService:    services.NewHistoryService(db),
+		visibilityService: services.NewVisibilityService(db),
+	}
+}
+
+// GetHistory 获取匹配历史记录
+func (hc *HistoryController) GetHistory(c *gin.Context) {
+	history, err := hc.historyService.GetHistory()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取历史记录失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取历史记录成功",
+		"data":    history,
+	})
+}
+
+// GetHistoryByID 根据ID获取历史记录详情
+func (hc *HistoryController) GetHistoryByID(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的历史记录ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	viewer, err := requestViewer(c, hc.visibilityService)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	record, err := hc.historyService.GetHistoryByID(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "历史记录不存在",
+			"data":    nil,
+		})
+		return
+	}
+
+	// 按字段可见性隐藏请求者无权查看的用户数据
+	record, err = hc.visibilityService.Redact(record, *viewer)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取历史记录失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取历史记录详情成功",
+		"data":    record,
+	})
+}
+
+// VerifyHistory 验证可验证匹配的历史记录
+func (hc *HistoryController) VerifyHistory(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的历史记录ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	result, err := hc.historyService.VerifyRecord(uint(id))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	message := "验证通过：配对结果可由公开的种子复算"
+	if !result.Verified {
+		message = "验证未通过"
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data":    result,
+	})
+}
+
+// GetStatistics 获取统计信息
+func (hc *HistoryController) GetStatistics(c *gin.Context) {
+	stats, err := hc.historyService.GetStatistics()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取统计信息失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取统计信息成功",
+		"data":    stats,
+	})
+}
+
+// RepairController 匹配记录修复控制器
+type RepairController struct {
+	repairService *services.RepairService
+}
+
+// NewRepairController 创建匹配记录修复控制器实例
+func NewRepairController(db *gorm.DB) *RepairController {
+	return &RepairController{
+		repairService: services.NewRepairService(db),
+	}
+}
+
+// RepairRecord 参与者在匹配后退出时修复匹配记录
+func (rc *RepairController) RepairRecord(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的历史记录ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	var req models.RepairMatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	revision, err := rc.repairService.RepairRecord(uint(id), &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "修复匹配记录成功",
+		"data":    revision,
+	})
+}
+
+// GetRevisions 获取匹配记录的修订历史
+func (rc *RepairController) GetRevisions(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的历史记录ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	revisions, err := rc.repairService.GetRevisions(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取修订历史成功",
+		"data":    revisions,
+	})
+}
+
+// NotificationController 匹配结果通知控制器
+type NotificationController struct {
+	notificationService *services.NotificationService
+}
+
+// NewNotificationController 创建匹配结果通知控制器实例
+func NewNotificationController(db *gorm.DB) *NotificationController {
+	return &NotificationController{
+		notificationService: services.NewNotificationService(db),
+	}
+}
+
+// GetNotifications 获取匹配记录各配对的通知发送状态
+func (nc *NotificationController) GetNotifications(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的历史记录ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	notifications, err := nc.notificationService.GetRecordNotifications(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取通知状态成功",
+		"data":    notifications,
+	})
+}
+
+// ResendPairNotification 重新发送某个配对的匹配结果通知
+func (nc *NotificationController) ResendPairNotification(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的历史记录ID",
+			"data":    nil,
+		})
+		return
+	}
+	pairNumber, err := strconv.Atoi(c.Param("pair"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的配对编号",
+			"data":    nil,
+		})
+		return
+	}
+
+	notifications, err := nc.notificationService.ResendPair(uint(id), pairNumber)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已加入发送队列，发送状态可在通知列表中查看",
+		"data":    notifications,
+	})
+}
+
+// ReminderController 匹配池提醒控制器
+type ReminderController struct {
+	reminderService *services.ReminderService
+}
+
+// NewReminderController 创建匹配池提醒控制器实例
+func NewReminderController(db *gorm.DB) *ReminderController {
+	return &ReminderController{
+		reminderService: services.NewReminderService(db),
+	}
+}
+
+// GetReminders 获取匹配池的提醒设置、提醒时间点和发送记录
+func (rc *ReminderController) GetReminders(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的匹配池ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	reminders, err := rc.reminderService.GetPoolReminders(uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取提醒成功",
+		"data":    reminders,
+	})
+}
+
+// WebhookController Webhook 订阅控制器
+type WebhookController struct {
+	webhookService *services.WebhookService
+}
+
+// NewWebhookController 创建 Webhook 订阅控制器实例
+func NewWebhookController(db *gorm.DB) *WebhookController {
+	return &WebhookController{
+		webhookService: services.NewWebhookService(db),
+	}
+}
+
+// CreateWebhook 创建 Webhook 订阅，签名密钥只在创建时返回一次
+func (wc *WebhookController) CreateWebhook(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	subscription, err := wc.webhookService.CreateSubscription(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "创建 Webhook 订阅成功，请妥善保存签名密钥",
+		"data":    subscription,
+	})
+}
+
+// GetWebhooks 获取全部 Webhook 订阅
+func (wc *WebhookController) GetWebhooks(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	subscriptions, err := wc.webhookService.GetSubscriptions()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "获取 Webhook 订阅失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取 Webhook 订阅成功",
+		"data":    subscriptions,
+	})
+}
+
+// DeleteWebhook 删除 Webhook 订阅
+func (wc *WebhookController) DeleteWebhook(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的 Webhook 订阅ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := wc.webhookService.DeleteSubscription(uint(id)); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "删除 Webhook 订阅成功",
+		"data":    nil,
+	})
+}
+
+// GetWebhookDeliveries 获取 Webhook 订阅的投递记录，limit 默认 50，最多 200
+func (wc *WebhookController) GetWebhookDeliveries(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的 Webhook 订阅ID",
+			"data":    nil,
+		})
+		return
+	}
+	limit, _ := strconv.Atoi(c.Query("limit"))
+
+	deliveries, err := wc.webhookService.GetDeliveries(uint(id), limit)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取投递记录成功",
+		"data":    deliveries,
+	})
+}
+
+// UserController 用户控制器
+type UserController struct {
+	userService *services.UserService
+}
+
+// NewUserController 创建用户控制器实例
+func NewUserController(db *gorm.DB) *UserController {
+	return &UserController{
+		userService: services.NewUserService(db),
+	}
+}
+
+// SearchUsers 搜索用户（管理员），参与者通过管理令牌查看本人的报名信息
+func (uc *UserController) SearchUsers(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	var req services.SearchUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	users, err := uc.userService.SearchUsers(&req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "搜索用户失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "搜索用户成功",
+		"data":    users,
+	})
+}
+
+// RemoveUser 移除用户（管理员），参与者通过管理令牌退出报名
+func (uc *UserController) RemoveUser(c *gin.Context) {
+	// 验证管理员权限
+	authHeader := c.GetHeader("Authorization")
+	if authHeader != "Bearer admin_authenticated" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "需要管理员权限",
+			"data":    nil,
+		})
+		return
+	}
+
+	idStr := c.Param("id")
+	id, err := strconv.ParseUint(idStr, 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "无效的用户ID",
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := uc.userService.RemoveUser(uint(id)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "移除用户失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "移除用户成功",
+		"data":    nil,
+	})
+}
+
+// ParticipantController 参与者自助服务控制器
+type ParticipantController struct {
+	participantService  *services.ParticipantService
+	verificationService *services.VerificationService
+}
+
+// NewParticipantController 创建参与者自助服务控制器实例
+func NewParticipantController(db *gorm.DB) *ParticipantController {
+	return &ParticipantController{
+		participantService:  services.NewParticipantService(db),
+		verificationService: services.NewVerificationService(db),
+	}
+}
+
+// participantToken 获取参与者管理令牌：请求头 X-Participant-Token 或查询参数 token
+func participantToken(c *gin.Context) string {
+	if token := c.GetHeader("X-Participant-Token"); token != "" {
+		return token
+	}
+	return c.Query("token")
+}
+
+// GetEntry 查看本人的报名信息
+func (pc *ParticipantController) GetEntry(c *gin.Context) {
+	entry, err := pc.participantService.GetEntry(participantToken(c))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取报名信息成功",
+		"data":    entry,
+	})
+}
+
+// UpdateEntry 修改本人的报名信息
+func (pc *ParticipantController) UpdateEntry(c *gin.Context) {
+	var req models.UpdateEntryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	entry, err := pc.participantService.UpdateEntry(participantToken(c), &req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidManageToken) {
+			status = http.StatusUnauthorized
+		}
+		// 重复报名，标出与已有报名相同的字段
+		var duplicateErr *services.DuplicateJoinError
+		if errors.As(err, &duplicateErr) {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "已有相同的报名，请勿重复报名",
+				"data": gin.H{
+					"fieldErrors": duplicateErr.Fields,
+				},
+			})
+			return
+		}
+
+		var fieldErrors services.FieldErrors
+		if errors.As(err, &fieldErrors) {
+			c.JSON(status, gin.H{
+				"success": false,
+				"message": "用户数据校验失败，请检查填写的内容",
+				"data":    gin.H{"fieldErrors": fieldErrors},
+			})
+			return
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "修改报名信息成功",
+		"data":    entry,
+	})
+}
+
+// Withdraw 退出报名
+func (pc *ParticipantController) Withdraw(c *gin.Context) {
+	if err := pc.participantService.Withdraw(participantToken(c)); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidManageToken) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": "退出报名失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "已退出报名",
+		"data":    nil,
+	})
+}
+
+// VerifyEmail 提交邮箱验证码，凭管理令牌或请求体中的参与者ID验证
+func (pc *ParticipantController) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	pc.verifyEmail(c, participantToken(c), req.UserID, req.Code)
+}
+
+// VerifyEmailLink 打开邮件中的验证链接完成验证
+func (pc *ParticipantController) VerifyEmailLink(c *gin.Context) {
+	userID, err := strconv.ParseUint(c.Query("userId"), 10, 32)
+	if err != nil || c.Query("code") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "验证链接无效",
+			"data":    nil,
+		})
+		return
+	}
+
+	pc.verifyEmail(c, "", uint(userID), c.Query("code"))
+}
+
+// verifyEmail 校验验证码并返回结果
+func (pc *ParticipantController) verifyEmail(c *gin.Context, token string, userID uint, code string) {
+	if err := pc.verificationService.Verify(token, userID, code); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidManageToken) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": "邮箱验证失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "邮箱验证成功，将参与之后的匹配",
+		"data":    nil,
+	})
+}
+
+// ResendVerification 重新发送邮箱验证码
+func (pc *ParticipantController) ResendVerification(c *gin.Context) {
+	if err := pc.verificationService.Resend(participantToken(c)); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidManageToken) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "验证码已重新发送，请查收邮件",
+		"data":    nil,
+	})
+}
+
+// GetMatches 查询本人在各轮匹配中的配对，latest=true 时只返回最近一次的匹配结果。
+// 凭管理令牌验证身份；丢失令牌时可以提供匹配池ID、报名邮箱和邮件中的验证码
+func (pc *ParticipantController) GetMatches(c *gin.Context) {
+	var results []models.MatchResult
+	var err error
+	if token := participantToken(c); token != "" || c.Query("code") == "" {
+		results, err = pc.participantService.GetMatches(token)
+	} else {
+		poolID, parseErr := strconv.ParseUint(c.Query("poolId"), 10, 32)
+		if parseErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "无效的匹配池ID",
+				"data":    nil,
+			})
+			return
+		}
+		results, err = pc.participantService.GetMatchesByContact(uint(poolID), c.Query("contact"), c.Query("code"))
+	}
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidManageToken) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": "获取匹配结果失败: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	if c.Query("latest") == "true" {
+		if len(results) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{
+				"success": false,
+				"message": "尚未匹配，请等待匹配完成",
+				"data":    nil,
+			})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "获取匹配结果成功",
+			"data":    results[0],
+		})
+		return
+	}
+
+	if results == nil {
+		results = []models.MatchResult{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "获取匹配结果成功",
+		"data":    results,
+	})
+}
+
+// SendLookupCode 向报名邮箱发送查询匹配结果的验证码，供丢失管理令牌的参与者使用
+func (pc *ParticipantController) SendLookupCode(c *gin.Context) {
+	var req models.LookupCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "请求参数错误: " + err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	if err := pc.participantService.SendLookupCode(req.PoolID, req.ContactInfo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+			"data":    nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "如果该邮箱已报名，将收到查询匹配结果的验证码",
 		"data":    nil,
 	})
 }
@@ -30,22 +30,42 @@ func InitDatabase() {
 	log.Println("✅ 数据库连接成功")
 
 	// 自动迁移数据库结构
-	err = DB.AutoMigrate(
+	if err := Migrate(DB); err != nil {
+		log.Fatal("❌ 数据库迁移失败:", err)
+	}
+
+	log.Println("✅ 数据库迁移完成")
+
+	// 创建示例数据（仅在开发环境）
+	createSampleData()
+}
+
+// Migrate 自动迁移数据库结构，并把旧版本的数据迁移到当前格式
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&models.MatchPool{},
 		&models.PoolField{},
 		&models.PoolUser{},
+		&models.PoolConstraint{},
 		&models.MatchRecord{},
 		&models.MatchPair{},
+		&models.MatchGroupMember{},
+		&models.ScheduledMatchJob{},
+		&models.MatchRevision{},
+		&models.MatchNotification{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&models.PoolReminder{},
+		&models.ReminderDelivery{},
 	)
-
 	if err != nil {
-		log.Fatal("❌ 数据库迁移失败:", err)
+		return err
 	}
 
-	log.Println("✅ 数据库迁移完成")
-
-	// 创建示例数据（仅在开发环境）
-	createSampleData()
+	// 旧版本的匹配池状态迁移到生命周期状态：active → open，expired → closed
+	db.Model(&models.MatchPool{}).Where("status = ?", "active").Update("status", models.PoolStatusOpen)
+	db.Model(&models.MatchPool{}).Where("status = ?", "expired").Update("status", models.PoolStatusClosed)
+	return nil
 }
 
 // createSampleData 创建示例数据
@@ -65,7 +85,7 @@ func createSampleData() {
 		Name:        "圣诞节匹配池示例",
 		Description: "这是一个示例匹配池，用于测试系统功能",
 		ValidUntil:  time.Now().Add(24 * time.Hour), // 24小时后过期
-		Status:      "active",
+		Status:      models.PoolStatusOpen,
 		Fields: []models.PoolField{
 			{
 				FieldName:  "name",
@@ -4,6 +4,7 @@ import (
 	"christmas-link-backend/cache"
 	"christmas-link-backend/controllers"
 	"christmas-link-backend/database"
+	"christmas-link-backend/services"
 	"log"
 	"net/http"
 	"os"
@@ -27,8 +28,8 @@ func main() {
 	// 添加CORS中间件
 	r.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, Idempotency-Key, X-Participant-Token")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -43,6 +44,27 @@ func main() {
 	historyController := controllers.NewHistoryController(database.GetDB())
 	userController := controllers.NewUserController(database.GetDB())
 	adminController := controllers.NewAdminController(database.GetDB())
+	constraintController := controllers.NewConstraintController(database.GetDB())
+	repairController := controllers.NewRepairController(database.GetDB())
+	participantController := controllers.NewParticipantController(database.GetDB())
+	notificationController := controllers.NewNotificationController(database.GetDB())
+	webhookController := controllers.NewWebhookController(database.GetDB())
+	reminderController := controllers.NewReminderController(database.GetDB())
+
+	// 启动定时匹配调度器
+	services.NewSchedulerService(database.GetDB()).Start()
+
+	// 启动匹配结果通知服务
+	services.NewNotificationService(database.GetDB()).Start()
+
+	// 启动提醒服务
+	services.NewReminderService(database.GetDB()).Start()
+
+	// 启动匹配池实时事件分发（Redis 可用时跨实例转发）
+	services.GetEventBroker().Start()
+
+	// 启动 Webhook 投递服务
+	services.NewWebhookService(database.GetDB()).Start()
 
 	// 基础健康检查端点
 	r.GET("/", func(c *gin.Context) {
@@ -70,7 +92,18 @@ func main() {
 			pools.POST("", poolController.CreatePool)
 			pools.GET("", poolController.GetPools)
 			pools.GET("/:id", poolController.GetPoolByID)
+			pools.PUT("/:id", poolController.UpdatePool)
+			pools.PATCH("/:id", poolController.UpdatePool)
+			pools.DELETE("/:id", poolController.DeletePool)
 			pools.POST("/join", poolController.JoinPool)
+			pools.POST("/:id/status", poolController.TransitionPool)
+			pools.GET("/:id/stats", poolController.GetPoolStats)
+			pools.GET("/:id/events", poolController.PoolEvents)
+			pools.GET("/:id/reminders", reminderController.GetReminders)
+			pools.GET("/:id/duplicates", poolController.GetDuplicates)
+			pools.GET("/:id/constraints", constraintController.GetConstraints)
+			pools.POST("/:id/constraints", constraintController.CreateConstraint)
+			pools.DELETE("/:id/constraints/:constraintId", constraintController.DeleteConstraint)
 		}
 
 		// 匹配路由
@@ -81,6 +114,11 @@ func main() {
 		{
 			history.GET("", historyController.GetHistory)
 			history.GET("/:id", historyController.GetHistoryByID)
+			history.GET("/:id/verify", historyController.VerifyHistory)
+			history.POST("/:id/repair", repairController.RepairRecord)
+			history.GET("/:id/revisions", repairController.GetRevisions)
+			history.GET("/:id/notifications", notificationController.GetNotifications)
+			history.POST("/:id/pairs/:pair/notify", notificationController.ResendPairNotification)
 		}
 
 		// 统计信息路由
@@ -93,6 +131,28 @@ func main() {
 			users.DELETE("/:id", userController.RemoveUser)
 		}
 
+		// 参与者自助服务路由（凭管理令牌）
+		participant := api.Group("/participant")
+		{
+			participant.GET("", participantController.GetEntry)
+			participant.PUT("", participantController.UpdateEntry)
+			participant.DELETE("", participantController.Withdraw)
+			participant.GET("/matches", participantController.GetMatches)
+			participant.POST("/matches/code", participantController.SendLookupCode)
+			participant.POST("/verify", participantController.VerifyEmail)
+			participant.GET("/verify", participantController.VerifyEmailLink)
+			participant.POST("/verify/resend", participantController.ResendVerification)
+		}
+
+		// Webhook 订阅路由（管理员）
+		webhooks := api.Group("/webhooks")
+		{
+			webhooks.POST("", webhookController.CreateWebhook)
+			webhooks.GET("", webhookController.GetWebhooks)
+			webhooks.DELETE("/:id", webhookController.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", webhookController.GetWebhookDeliveries)
+		}
+
 		// 管理员路由
 		admin := api.Group("/admin")
 		{
@@ -121,13 +181,36 @@ func main() {
 	log.Println("   POST /api/pools        - Create pool")
 	log.Println("   GET  /api/pools        - Get pools")
 	log.Println("   GET  /api/pools/:id    - Get pool by ID")
+	log.Println("   PUT/PATCH /api/pools/:id - Update pool")
+	log.Println("   DELETE /api/pools/:id  - Delete pool")
 	log.Println("   POST /api/pools/join   - Join pool")
+	log.Println("   POST /api/pools/:id/status - Change pool lifecycle status")
+	log.Println("   GET  /api/pools/:id/stats - Get pool answer statistics")
+	log.Println("   GET  /api/pools/:id/events - Live pool events (Server-Sent Events)")
+	log.Println("   GET  /api/pools/:id/reminders - Pool reminder schedule and deliveries (admin)")
+	log.Println("   GET  /api/pools/:id/duplicates - Report suspected duplicate entries")
+	log.Println("   GET  /api/pools/:id/constraints - Get pool constraints (admin)")
+	log.Println("   POST /api/pools/:id/constraints - Add pool constraint (admin)")
+	log.Println("   DELETE /api/pools/:id/constraints/:constraintId - Delete pool constraint (admin)")
 	log.Println("   POST /api/match        - Start match")
 	log.Println("   GET  /api/history      - Get history")
 	log.Println("   GET  /api/history/:id  - Get history by ID")
+	log.Println("   GET  /api/history/:id/verify - Verify match with revealed seed")
+	log.Println("   POST /api/history/:id/repair - Repair match after a participant drops out")
+	log.Println("   GET  /api/history/:id/notifications - Match notification delivery status (admin)")
+	log.Println("   POST /api/history/:id/pairs/:pair/notify - Resend notification for a pair (admin)")
+	log.Println("   GET  /api/history/:id/revisions - Get match revisions")
 	log.Println("   GET  /api/stats        - Get statistics")
 	log.Println("   POST /api/users/search - Search users")
 	log.Println("   DELETE /api/users/:id  - Remove user")
+	log.Println("   GET/PUT/DELETE /api/participant - View, edit or withdraw own entry (manage token)")
+	log.Println("   GET  /api/participant/matches - Get own matches across rounds (?latest=true for the latest)")
+	log.Println("   POST /api/participant/matches/code - Email a one-time code to look up matches without a token")
+	log.Println("   POST /api/participant/verify - Verify email with code (GET for email link)")
+	log.Println("   POST /api/participant/verify/resend - Resend verification code (manage token)")
+	log.Println("   POST/GET /api/webhooks - Create or list webhook subscriptions (admin)")
+	log.Println("   DELETE /api/webhooks/:id - Delete webhook subscription (admin)")
+	log.Println("   GET  /api/webhooks/:id/deliveries - Webhook delivery log (admin)")
 	log.Println("💡 Redis缓存已启用，提供更快的响应速度")
 
 	if err := r.Run(port); err != nil {
@@ -7,21 +7,111 @@ import (
 	"gorm.io/gorm"
 )
 
+// 匹配模式
+const (
+	MatchModePair  = "pair"  // 两两配对，人数为奇数时产生轮空用户
+	MatchModeGift  = "gift"  // 交换礼物：所有人组成单一有向环，每人送出并收到一份礼物
+	MatchModeScore = "score" // 按字段匹配规则打分，求总分最高的两两配对
+)
+
+// 字段匹配规则（score 匹配模式使用）
+const (
+	MatchRuleSame      = "same"      // 偏好取值相同
+	MatchRuleDifferent = "different" // 偏好取值不同
+	MatchRuleSimilar   = "similar"   // 偏好文本/标签相似
+)
+
+// 分组剩余用户处理策略
+const (
+	LeftoverSmaller = "smaller" // 剩余用户单独组成一个较小的分组（两人一组时即为轮空）
+	LeftoverMerge   = "merge"   // 剩余用户依次并入已有分组
+)
+
+// 随机数来源
+const (
+	RandomSourceRandomOrg    = "random_org"    // random.org 签名随机数（API Key 取自 RANDOM_ORG_API_KEY）
+	RandomSourceCrypto       = "crypto"        // 操作系统密码学安全随机数（crypto/rand）
+	RandomSourceSeeded       = "seeded"        // 固定种子的确定性随机数，用于测试和复现
+	RandomSourceCommitReveal = "commit_reveal" // 可验证匹配：由事先承诺的种子推导
+)
+
+// 匹配池生命周期状态
+const (
+	PoolStatusDraft    = "draft"    // 草稿：尚未开放报名
+	PoolStatusOpen     = "open"     // 开放报名，超过截止时间后视为 closed
+	PoolStatusClosed   = "closed"   // 已截止报名，等待匹配
+	PoolStatusMatching = "matching" // 匹配进行中
+	PoolStatusMatched  = "matched"  // 已完成匹配，冷却结束后可进行下一轮匹配
+	PoolStatusArchived = "archived" // 已归档，不再接受任何操作
+)
+
 // MatchPool 匹配池模型
 type MatchPool struct {
 	ID            uint       `json:"id" gorm:"primarykey"`
 	Name          string     `json:"name" gorm:"not null"`
 	Description   string     `json:"description"`
 	ValidUntil    time.Time  `json:"validUntil" gorm:"not null"`
-	Status        string     `json:"status" gorm:"default:active"`  // active, expired, matched
+	Status        string     `json:"status" gorm:"default:open"`    // 生命周期状态，读取时使用 CurrentStatus
 	CooldownTime  int        `json:"cooldownTime" gorm:"default:5"` // 冷却时间（秒）
+	MatchMode     string     `json:"matchMode" gorm:"default:pair"` // pair, gift, score
 	LastMatchedAt *time.Time `json:"lastMatchedAt"`                 // 最后匹配时间
+	Version       int        `json:"-" gorm:"default:0"`            // 乐观锁版本号，每次匹配递增
 	CreatedAt     time.Time  `json:"createdAt"`
 	UpdatedAt     time.Time  `json:"updatedAt"`
 
+	// 分组设置：每组人数及剩余用户的处理策略
+	GroupSize      int    `json:"groupSize" gorm:"default:2"`
+	LeftoverPolicy string `json:"leftoverPolicy" gorm:"default:smaller"` // smaller, merge
+
+	// 避免重复配对：参考最近 N 轮的匹配记录，0 表示不限制
+	AvoidRepeatRounds   int    `json:"avoidRepeatRounds" gorm:"default:0"`
+	RepeatIdentityField string `json:"repeatIdentityField"` // 识别同一参与者的字段（如 email），为空时只按用户ID识别
+
+	// 可验证匹配：匹配前公布下一轮种子的 SHA-256 承诺，匹配后公开种子供任何人复算
+	Verifiable      bool       `json:"verifiable" gorm:"default:false"`
+	SeedCommitment  string     `json:"seedCommitment"`  // 下一轮种子的承诺（十六进制 SHA-256）
+	SeedCommittedAt *time.Time `json:"seedCommittedAt"` // 承诺公布时间
+	PendingSeed     string     `json:"-"`               // 尚未公开的种子，匹配完成后随匹配记录公开
+
+	// 随机数来源：random_org、crypto、seeded，为空时使用全局默认来源（环境变量 RANDOM_SOURCE）
+	RandomSource string `json:"randomSource"`
+
+	// 自动匹配：到达 MatchAt（未设置时为 ValidUntil）时由后台调度器执行一次匹配
+	AutoMatch bool       `json:"autoMatch" gorm:"default:false"`
+	MatchAt   *time.Time `json:"matchAt"`
+
+	// 人数上限，0 表示不限；满员后加入的用户进入候补名单，有人移除时按加入顺序递补
+	MaxParticipants int `json:"maxParticipants" gorm:"default:0"`
+
+	// 邮箱验证：加入时联系方式必须是邮箱，验证通过前为待验证状态，不参与匹配
+	VerifyEmail bool `json:"verifyEmail" gorm:"default:false"`
+
+	// 匹配结果通知：匹配完成后给每位参与者发邮件告知匹配对象
+	NotifyMatches    bool   `json:"notifyMatches" gorm:"default:false"`
+	NotifyEmailField string `json:"notifyEmailField"`                // 收件邮箱所在字段，为空时使用联系方式
+	NotifyTemplate   string `json:"notifyTemplate" gorm:"type:text"` // 邮件正文模板（Go text/template），为空时使用默认模板
+
+	// 提醒：截止报名前 CloseReminderHours 小时（如 [24, 1]）提醒组织者和邮件列表当前人数；
+	// 交换日期 ExchangeAt 前 ExchangeReminderHours 小时提醒最近一轮已匹配的参与者
+	OrganizerEmail        string          `json:"organizerEmail"`
+	ReminderMailingList   json.RawMessage `json:"reminderMailingList" gorm:"type:text"`   // 同时接收截止提醒的邮箱（JSON 字符串数组）
+	CloseReminderHours    json.RawMessage `json:"closeReminderHours" gorm:"type:text"`    // JSON 整数数组，为空表示不提醒
+	ExchangeAt            *time.Time      `json:"exchangeAt"`                             // 揭晓/交换礼物的日期
+	ExchangeReminderHours json.RawMessage `json:"exchangeReminderHours" gorm:"type:text"` // JSON 整数数组，为空表示不提醒
+
+	// 最近一次发出 pool.expired 事件的时间，早于 ValidUntil 时说明截止时间延长后尚未再次通知
+	ExpiredEventAt *time.Time `json:"-"`
+
+	// 生命周期各状态的进入时间（已匹配时间即 LastMatchedAt）
+	OpenedAt          *time.Time `json:"openedAt"`
+	ClosedAt          *time.Time `json:"closedAt"`
+	MatchingStartedAt *time.Time `json:"matchingStartedAt"`
+	ArchivedAt        *time.Time `json:"archivedAt"`
+
 	// 关联关系
-	Fields []PoolField `json:"fields" gorm:"foreignKey:PoolID;constraint:OnDelete:CASCADE"`
-	Users  []PoolUser  `json:"users" gorm:"foreignKey:PoolID;constraint:OnDelete:CASCADE"`
+	Fields      []PoolField      `json:"fields" gorm:"foreignKey:PoolID;constraint:OnDelete:CASCADE"`
+	Users       []PoolUser       `json:"users" gorm:"foreignKey:PoolID;constraint:OnDelete:CASCADE"`
+	Constraints []PoolConstraint `json:"constraints" gorm:"foreignKey:PoolID;constraint:OnDelete:CASCADE"`
 }
 
 // PoolField 匹配池字段配置模型
@@ -30,11 +120,69 @@ type PoolField struct {
 	PoolID     uint      `json:"poolId" gorm:"not null"`
 	FieldName  string    `json:"name" gorm:"not null"`
 	FieldLabel string    `json:"label" gorm:"not null"`
-	FieldType  string    `json:"type" gorm:"not null"` // text, textarea, number, email, url
+	FieldType  string    `json:"type" gorm:"not null"` // 字段类型，见 FieldType 常量
 	IsRequired bool      `json:"required" gorm:"default:false"`
 	FieldOrder int       `json:"order" gorm:"default:0"`
 	CreatedAt  time.Time `json:"createdAt"`
 	UpdatedAt  time.Time `json:"updatedAt"`
+
+	// 匹配规则：same, different, similar，为空表示不参与打分
+	MatchRule   string  `json:"matchRule"`
+	MatchWeight float64 `json:"matchWeight" gorm:"default:1"`
+
+	// 校验规则：加入匹配池时检查用户填写的内容
+	MinLength int             `json:"minLength" gorm:"default:0"` // 最小长度（字符数），0 表示不限
+	MaxLength int             `json:"maxLength" gorm:"default:0"` // 最大长度（字符数），0 表示不限
+	Pattern   string          `json:"pattern"`                    // 取值需要匹配的正则表达式
+	Options   json.RawMessage `json:"options" gorm:"type:text"`   // 允许的取值（JSON 字符串数组），为空表示不限
+
+	// 可见性：public 所有人可见，partner 仅匹配对象可见，admin 仅管理员可见
+	Visibility string `json:"visibility" gorm:"default:public"`
+
+	// 同一匹配池内取值必须唯一（忽略大小写、空白，电话号码忽略分隔符），用于防止重复报名
+	Unique bool `json:"unique" gorm:"default:false"`
+}
+
+// 字段可见性
+const (
+	FieldVisibilityPublic  = "public"
+	FieldVisibilityPartner = "partner"
+	FieldVisibilityAdmin   = "admin"
+)
+
+// EffectiveVisibility 字段的可见性，未设置时为 public
+func (f *PoolField) EffectiveVisibility() string {
+	if f.Visibility == "" {
+		return FieldVisibilityPublic
+	}
+	return f.Visibility
+}
+
+// 字段类型
+const (
+	FieldTypeText     = "text"
+	FieldTypeTextarea = "textarea"
+	FieldTypeNumber   = "number"
+	FieldTypeEmail    = "email"
+	FieldTypeURL      = "url"
+
+	// 选择类字段，取值限定在 options 中（boolean 的取值为 true/false，date 的格式为 YYYY-MM-DD）
+	FieldTypeSelect      = "select"
+	FieldTypeMultiSelect = "multiselect"
+	FieldTypeBoolean     = "boolean"
+	FieldTypeDate        = "date"
+)
+
+// OptionList 解析字段允许的取值
+func (f *PoolField) OptionList() ([]string, error) {
+	if len(f.Options) == 0 || string(f.Options) == "null" {
+		return nil, nil
+	}
+	var options []string
+	if err := json.Unmarshal(f.Options, &options); err != nil {
+		return nil, err
+	}
+	return options, nil
 }
 
 // PoolUser 匹配池用户模型
@@ -44,11 +192,251 @@ type PoolUser struct {
 	UserData    json.RawMessage `json:"userData" gorm:"type:text;not null"` // JSON格式存储用户数据
 	ContactInfo string          `json:"contactInfo"`                        // 用于移除功能
 	JoinedAt    time.Time       `json:"joinedAt"`
+	Waitlisted  bool            `json:"waitlisted" gorm:"default:false;index"` // 匹配池已满时进入候补名单，不参与匹配
+
+	ManageTokenHash string `json:"-" gorm:"index"` // 参与者自助管理令牌的 SHA-256，令牌本身只在加入时返回一次
+
+	// 邮箱验证：待验证的用户占用名额但不参与匹配，验证码只保存 SHA-256
+	PendingVerification   bool       `json:"pendingVerification" gorm:"default:false;index"`
+	VerificationCodeHash  string     `json:"-"`
+	VerificationExpiresAt *time.Time `json:"-"`
+	VerificationAttempts  int        `json:"-" gorm:"default:0"` // 验证码输错次数，达到上限后需要重新发送
+	VerificationSentAt    *time.Time `json:"-"`                  // 最近一次发送验证码的时间，用于限制重发频率
+	VerifiedAt            *time.Time `json:"verifiedAt"`
 
 	// 用于解析JSON数据的临时字段
 	ParsedUserData map[string]interface{} `json:"parsedUserData" gorm:"-"`
 }
 
+// 排除约束类型
+const (
+	ConstraintExcludePair      = "exclude_pair"       // 指定的两名用户不能配对
+	ConstraintExcludeSameField = "exclude_same_field" // 指定字段取值相同的用户不能配对
+)
+
+// PoolConstraint 匹配池排除约束模型
+type PoolConstraint struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	PoolID    uint      `json:"poolId" gorm:"not null;index"`
+	Type      string    `json:"type" gorm:"not null"` // exclude_pair, exclude_same_field
+	User1ID   *uint     `json:"user1Id"`              // exclude_pair 使用
+	User2ID   *uint     `json:"user2Id"`              // exclude_pair 使用
+	FieldName string    `json:"fieldName"`            // exclude_same_field 使用
+	Note      string    `json:"note"`                 // 备注，例如 "情侣"、"室友"
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 定时匹配任务状态
+const (
+	JobStatusPending = "pending" // 等待执行
+	JobStatusRunning = "running" // 已被某个实例领取，正在执行
+	JobStatusDone    = "done"    // 执行成功
+	JobStatusFailed  = "failed"  // 多次重试后仍失败
+)
+
+// ScheduledMatchJob 定时匹配任务模型，持久化保存以便服务重启后继续执行；
+// 多个后端实例通过条件更新领取任务（LockedBy/LockedUntil），保证同一匹配池只被匹配一次
+type ScheduledMatchJob struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	PoolID      uint       `json:"poolId" gorm:"not null;uniqueIndex"`
+	RunAt       time.Time  `json:"runAt" gorm:"not null;index"`
+	Status      string     `json:"status" gorm:"default:pending;index"` // pending, running, done, failed
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	LockedBy    string     `json:"lockedBy"`    // 领取任务的实例标识
+	LockedUntil *time.Time `json:"lockedUntil"` // 锁过期后其他实例可重新领取
+	LastError   string     `json:"lastError"`
+	RecordID    *uint      `json:"recordId"` // 成功后生成的匹配记录
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// 提醒类型
+const (
+	ReminderKindClose    = "close"    // 截止报名前提醒组织者和邮件列表
+	ReminderKindExchange = "exchange" // 交换日期前提醒已匹配的参与者
+)
+
+// 提醒状态
+const (
+	ReminderStatusPending = "pending" // 等待到达提醒时间
+	ReminderStatusFired   = "fired"   // 已生成待发送的邮件
+	ReminderStatusSkipped = "skipped" // 不再需要提醒，例如登记时提醒时间已过、匹配池已截止或尚未匹配
+)
+
+// PoolReminder 匹配池的一个提醒时间点，持久化保存以便服务重启后继续执行。
+// 到达提醒时间后在同一事务中标记为 fired 并生成每位收件人的 ReminderDelivery，
+// 因此不会重复生成；截止时间或交换日期变更后尚未发出的提醒按新时间重新计算
+type PoolReminder struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	PoolID     uint       `json:"poolId" gorm:"not null;uniqueIndex:idx_pool_reminder"`
+	Kind       string     `json:"kind" gorm:"not null;uniqueIndex:idx_pool_reminder"`  // close, exchange
+	Hours      int        `json:"hours" gorm:"not null;uniqueIndex:idx_pool_reminder"` // 提前的小时数
+	TargetAt   time.Time  `json:"targetAt"`                                            // 截止时间或交换日期
+	RunAt      time.Time  `json:"runAt" gorm:"not null;index"`
+	Status     string     `json:"status" gorm:"default:pending;index"` // pending, fired, skipped
+	Recipients int        `json:"recipients"`                          // 生成的邮件数量
+	Note       string     `json:"note"`                                // 跳过的原因
+	FiredAt    *time.Time `json:"firedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// ReminderDelivery 一封提醒邮件，内容在提醒触发时生成，发送失败后原样重试；状态同匹配结果通知
+type ReminderDelivery struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	ReminderID    uint       `json:"reminderId" gorm:"not null;index"`
+	PoolID        uint       `json:"poolId" gorm:"not null;index"`
+	UserID        *uint      `json:"userId"` // 交换日期提醒的参与者，截止提醒为空
+	Email         string     `json:"email"`
+	Subject       string     `json:"subject"`
+	Body          string     `json:"-" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"default:pending;index"` // pending, sending, sent, failed
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt" gorm:"index"`
+	LockedBy      string     `json:"-"`
+	LockedUntil   *time.Time `json:"-"`
+	LastError     string     `json:"lastError"`
+	SentAt        *time.Time `json:"sentAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// PoolRemindersResponse 匹配池的提醒设置、提醒时间点及发送记录
+type PoolRemindersResponse struct {
+	PoolID                uint               `json:"poolId"`
+	OrganizerEmail        string             `json:"organizerEmail"`
+	ReminderMailingList   []string           `json:"reminderMailingList"`
+	CloseReminderHours    []int              `json:"closeReminderHours"`
+	ExchangeAt            *string            `json:"exchangeAt"`
+	ExchangeReminderHours []int              `json:"exchangeReminderHours"`
+	Reminders             []PoolReminder     `json:"reminders"`
+	Deliveries            []ReminderDelivery `json:"deliveries"`
+}
+
+// 匹配结果通知状态
+const (
+	NotificationStatusPending = "pending" // 等待发送（含等待重试）
+	NotificationStatusSending = "sending" // 发送中
+	NotificationStatusSent    = "sent"    // 已发送
+	NotificationStatusFailed  = "failed"  // 多次重试后仍失败
+	NotificationStatusSkipped = "skipped" // 没有可用的邮箱地址
+)
+
+// MatchNotification 匹配结果通知，每个配对的每位收件人一条；交换礼物模式下只通知送礼人
+// 由后台通知服务发送，失败后按尝试次数延后重试
+type MatchNotification struct {
+	ID            uint       `json:"id" gorm:"primarykey"`
+	RecordID      uint       `json:"recordId" gorm:"not null;index"`
+	PairID        uint       `json:"pairId" gorm:"not null;index"`
+	PairNumber    int        `json:"pair"`
+	UserID        uint       `json:"userId" gorm:"not null"`
+	Email         string     `json:"email"`
+	Status        string     `json:"status" gorm:"default:pending;index"` // pending, sending, sent, failed, skipped
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time  `json:"nextAttemptAt" gorm:"index"`
+	LockedBy      string     `json:"-"`
+	LockedUntil   *time.Time `json:"-"` // 锁过期后其他实例可重新领取
+	LastError     string     `json:"lastError"`
+	SentAt        *time.Time `json:"sentAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// Webhook 事件类型
+const (
+	WebhookEventPoolCreated        = "pool.created"
+	WebhookEventParticipantJoined  = "participant.joined"
+	WebhookEventParticipantRemoved = "participant.removed"
+	WebhookEventMatchCompleted     = "match.completed"
+	WebhookEventPoolExpired        = "pool.expired"
+)
+
+// Webhook 投递状态
+const (
+	WebhookStatusPending   = "pending"   // 等待投递（含等待重试）
+	WebhookStatusSending   = "sending"   // 投递中
+	WebhookStatusDelivered = "delivered" // 对方返回 2xx
+	WebhookStatusFailed    = "failed"    // 多次重试后仍失败
+)
+
+// WebhookSubscription Webhook 订阅，PoolID 为空时订阅所有匹配池的事件
+type WebhookSubscription struct {
+	ID        uint            `json:"id" gorm:"primarykey"`
+	PoolID    *uint           `json:"poolId" gorm:"index"`
+	URL       string          `json:"url" gorm:"not null"`
+	Secret    string          `json:"-" gorm:"not null"`          // HMAC-SHA256 签名密钥，只在创建时返回
+	Events    json.RawMessage `json:"events" gorm:"type:text"`    // 订阅的事件（JSON 字符串数组），为空表示全部事件
+	Active    bool            `json:"active" gorm:"default:true"` // 停用后不再产生新的投递
+	CreatedAt time.Time       `json:"createdAt"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// EventList 解析订阅的事件列表，未设置时返回空
+func (w *WebhookSubscription) EventList() ([]string, error) {
+	if len(w.Events) == 0 || string(w.Events) == "null" {
+		return nil, nil
+	}
+	var events []string
+	if err := json.Unmarshal(w.Events, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// WebhookDelivery Webhook 投递记录，每个事件对每个订阅一条；载荷在事件发生时生成，重试时原样重发
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primarykey"`
+	SubscriptionID uint       `json:"subscriptionId" gorm:"not null;index"`
+	EventID        string     `json:"eventId" gorm:"index"` // 同一事件投递给各订阅时相同，接收方可据此去重
+	Event          string     `json:"event" gorm:"not null"`
+	PoolID         uint       `json:"poolId"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status" gorm:"default:pending;index"` // pending, sending, delivered, failed
+	Attempts       int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  time.Time  `json:"nextAttemptAt" gorm:"index"`
+	LockedBy       string     `json:"-"`
+	LockedUntil    *time.Time `json:"-"`              // 锁过期后其他实例可重新领取
+	ResponseStatus int        `json:"responseStatus"` // 最近一次投递的 HTTP 状态码，0 表示未收到响应
+	LastError      string     `json:"lastError"`
+	DeliveredAt    *time.Time `json:"deliveredAt"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// CreateWebhookRequest 创建 Webhook 订阅请求结构
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	PoolID *uint    `json:"poolId"` // 为空时订阅所有匹配池
+	Events []string `json:"events"` // 为空时订阅全部事件
+	Secret string   `json:"secret"` // 为空时自动生成
+}
+
+// CreateWebhookResponse 创建 Webhook 订阅响应结构，签名密钥只返回这一次
+type CreateWebhookResponse struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// 匹配池实时事件类型
+const (
+	PoolEventSnapshot            = "snapshot"             // 连接建立时推送的匹配池当前信息
+	PoolEventParticipantJoined   = "participant.joined"   // 用户加入
+	PoolEventParticipantLeft     = "participant.left"     // 用户被移除或退出
+	PoolEventParticipantVerified = "participant.verified" // 用户通过邮箱验证
+	PoolEventStatusChanged       = "status.changed"       // 匹配池状态变更
+	PoolEventPoolUpdated         = "pool.updated"         // 管理员修改了匹配池设置，数据为最新的匹配池信息
+	PoolEventMatchCompleted      = "match.completed"      // 匹配完成
+	PoolEventPoolDeleted         = "pool.deleted"         // 匹配池被删除，推送后关闭连接
+)
+
+// PoolEvent 推送给匹配池实时订阅者的事件，多实例部署时经 Redis 发布订阅转发
+type PoolEvent struct {
+	Type      string          `json:"type"`
+	PoolID    uint            `json:"poolId"`
+	Data      json.RawMessage `json:"data"`
+	Timestamp string          `json:"timestamp"`
+}
+
 // MatchRecord 匹配记录模型
 type MatchRecord struct {
 	ID          uint      `json:"id" gorm:"primarykey"`
@@ -57,45 +445,179 @@ type MatchRecord struct {
 	TotalUsers  int       `json:"totalUsers" gorm:"not null"`
 	PairsCount  int       `json:"pairsCount" gorm:"not null"`
 	HasLoneUser bool      `json:"hasLoneUser" gorm:"default:false"`
+	MatchMode   string    `json:"matchMode" gorm:"default:pair"`   // pair, gift, score
+	GroupSize   int       `json:"groupSize" gorm:"default:2"`      // 每组人数
+	RepeatCount int       `json:"repeatCount" gorm:"default:0"`    // 无法避免的重复配对数量
+	TotalScore  float64   `json:"totalScore" gorm:"default:0"`     // score 模式下所有配对的总分
 	Status      string    `json:"status" gorm:"default:completed"` // completed, in_progress
 	MatchedAt   time.Time `json:"matchedAt"`
+	Revision    int       `json:"revision" gorm:"default:0"` // 修订号，每次参与者退出后的局部重新配对递增
+
+	// 可验证匹配：公开的种子、事先公布的承诺、参与者列表摘要及复算所需的输入快照
+	Seed              string `json:"seed"`
+	SeedCommitment    string `json:"seedCommitment"`
+	ParticipantDigest string `json:"participantDigest"`
+	MatchInputs       string `json:"-" gorm:"type:text"`
+
+	// 发起匹配时携带的 Idempotency-Key，重复请求返回同一记录
+	IdempotencyKey *string `json:"-" gorm:"uniqueIndex"`
+
+	// 实际使用的随机数来源，random.org 来源同时记录签名序列号、签名及签名覆盖的原文
+	RandomSource     string `json:"randomSource"`
+	RandomSerial     int64  `json:"randomSerial"`
+	RandomSignature  string `json:"randomSignature" gorm:"type:text"`
+	RandomSignedData string `json:"randomSignedData" gorm:"type:text"`
 
 	// 关联关系
 	Pairs []MatchPair `json:"pairs" gorm:"foreignKey:RecordID;constraint:OnDelete:CASCADE"`
 }
 
-// MatchPair 匹配配对模型
+// MatchRevision 匹配记录修订：参与者在匹配后退出时，只对受影响的人重新配对，并记录变更内容
+type MatchRevision struct {
+	ID              uint            `json:"id" gorm:"primarykey"`
+	RecordID        uint            `json:"recordId" gorm:"not null;uniqueIndex:idx_record_revision"`
+	Revision        int             `json:"revision" gorm:"not null;uniqueIndex:idx_record_revision"`
+	RemovedUserID   uint            `json:"removedUserId" gorm:"not null"`
+	RemovedUserData json.RawMessage `json:"-" gorm:"type:text"`
+	Note            string          `json:"note"`
+	Diff            json.RawMessage `json:"-" gorm:"type:text"` // JSON格式存储的配对变更
+	CreatedAt       time.Time       `json:"createdAt"`
+
+	// 用于解析JSON数据的临时字段
+	ParsedRemovedUserData map[string]interface{} `json:"removedUserData" gorm:"-"`
+	ParsedDiff            []PairChange           `json:"diff" gorm:"-"`
+}
+
+// 配对变更类型
+const (
+	PairChangeRemoved = "removed" // 配对被拆除
+	PairChangeAdded   = "added"   // 新增配对
+)
+
+// PairChange 修订中的一条配对变更
+type PairChange struct {
+	Change   string   `json:"change"` // removed, added
+	Pair     int      `json:"pair"`
+	Directed bool     `json:"directed"`
+	UserIDs  []uint   `json:"userIds"`
+	Names    []string `json:"names"`
+}
+
+// MatchPair 匹配配对（分组）模型
+// User1/User2 为分组的前两名成员，完整成员列表见 Members；
+// 交换礼物模式下配对是有向的：User1 为送礼人，User2 为收礼人
 type MatchPair struct {
 	ID         uint            `json:"id" gorm:"primarykey"`
 	RecordID   uint            `json:"recordId" gorm:"not null"`
 	PairNumber int             `json:"pair" gorm:"not null"`
 	User1ID    uint            `json:"user1Id" gorm:"not null"`
 	User2ID    *uint           `json:"user2Id"` // NULL表示轮空
+	Directed   bool            `json:"directed" gorm:"default:false"`
 	User1Data  json.RawMessage `json:"user1Data" gorm:"type:text;not null"`
 	User2Data  json.RawMessage `json:"user2Data" gorm:"type:text"`
+	Size       int             `json:"size" gorm:"default:2"`  // 分组人数，1表示轮空
+	Score      float64         `json:"score" gorm:"default:0"` // score 模式下的配对分数
+
+	// 关联关系
+	Members []MatchGroupMember `json:"members" gorm:"foreignKey:PairID;constraint:OnDelete:CASCADE"`
 
 	// 用于解析JSON数据的临时字段
 	ParsedUser1Data map[string]interface{} `json:"user1" gorm:"-"`
 	ParsedUser2Data map[string]interface{} `json:"user2" gorm:"-"`
 }
 
+// MatchGroupMember 匹配分组成员模型
+type MatchGroupMember struct {
+	ID       uint            `json:"id" gorm:"primarykey"`
+	PairID   uint            `json:"pairId" gorm:"not null;index"`
+	UserID   uint            `json:"userId" gorm:"not null"`
+	Position int             `json:"position" gorm:"not null"` // 成员在分组中的顺序，从1开始
+	UserData json.RawMessage `json:"-" gorm:"type:text;not null"`
+
+	// 用于解析JSON数据的临时字段
+	ParsedUserData map[string]interface{} `json:"userData" gorm:"-"`
+}
+
 // BeforeCreate 创建前的钩子函数
 func (p *MatchPool) BeforeCreate(tx *gorm.DB) error {
 	if p.Status == "" {
-		p.Status = "active"
+		p.Status = PoolStatusOpen
+	}
+	if p.Status == PoolStatusOpen && p.OpenedAt == nil {
+		now := time.Now()
+		p.OpenedAt = &now
+	}
+	if p.MatchMode == "" {
+		p.MatchMode = MatchModePair
+	}
+	if p.GroupSize == 0 {
+		p.GroupSize = 2
+	}
+	if p.LeftoverPolicy == "" {
+		p.LeftoverPolicy = LeftoverSmaller
 	}
 	return nil
 }
 
+// MailingList 解析截止提醒的邮件列表，未设置时返回空
+func (p *MatchPool) MailingList() []string {
+	var list []string
+	if len(p.ReminderMailingList) > 0 {
+		json.Unmarshal(p.ReminderMailingList, &list)
+	}
+	return list
+}
+
+// CloseReminders 解析截止报名前的提醒时间（小时），未设置时返回空
+func (p *MatchPool) CloseReminders() []int {
+	var hours []int
+	if len(p.CloseReminderHours) > 0 {
+		json.Unmarshal(p.CloseReminderHours, &hours)
+	}
+	return hours
+}
+
+// ExchangeReminders 解析交换日期前的提醒时间（小时），未设置时返回空
+func (p *MatchPool) ExchangeReminders() []int {
+	var hours []int
+	if len(p.ExchangeReminderHours) > 0 {
+		json.Unmarshal(p.ExchangeReminderHours, &hours)
+	}
+	return hours
+}
+
 // IsExpired 检查匹配池是否已过期
 func (p *MatchPool) IsExpired() bool {
 	return time.Now().After(p.ValidUntil)
 }
 
-// GetUserCount 获取匹配池用户数量
+// CurrentStatus 返回匹配池当前的生命周期状态，所有读取状态的地方都应使用此方法：
+// 开放报名的匹配池超过截止时间后视为已截止
+func (p *MatchPool) CurrentStatus() string {
+	if p.Status == PoolStatusOpen && p.IsExpired() {
+		return PoolStatusClosed
+	}
+	return p.Status
+}
+
+// GetUserCount 获取匹配池正式参与者数量（不含候补和待验证邮箱的用户）
 func (p *MatchPool) GetUserCount(db *gorm.DB) int64 {
 	var count int64
-	db.Model(&PoolUser{}).Where("pool_id = ?", p.ID).Count(&count)
+	db.Model(&PoolUser{}).Where("pool_id = ? AND waitlisted = ? AND pending_verification = ?", p.ID, false, false).Count(&count)
+	return count
+}
+
+// GetPendingCount 获取匹配池待验证邮箱的人数（含候补名单中待验证的用户）
+func (p *MatchPool) GetPendingCount(db *gorm.DB) int64 {
+	var count int64
+	db.Model(&PoolUser{}).Where("pool_id = ? AND pending_verification = ?", p.ID, true).Count(&count)
+	return count
+}
+
+// GetWaitlistCount 获取匹配池候补名单人数
+func (p *MatchPool) GetWaitlistCount(db *gorm.DB) int64 {
+	var count int64
+	db.Model(&PoolUser{}).Where("pool_id = ? AND waitlisted = ?", p.ID, true).Count(&count)
 	return count
 }
 
@@ -113,6 +635,27 @@ func (pu *PoolUser) AfterFind(tx *gorm.DB) error {
 	return nil
 }
 
+// AfterFind 查询后的钩子函数 - MatchRevision
+func (r *MatchRevision) AfterFind(tx *gorm.DB) error {
+	if len(r.RemovedUserData) > 0 {
+		if err := json.Unmarshal(r.RemovedUserData, &r.ParsedRemovedUserData); err != nil {
+			return err
+		}
+	}
+	if len(r.Diff) > 0 {
+		return json.Unmarshal(r.Diff, &r.ParsedDiff)
+	}
+	return nil
+}
+
+// AfterFind 查询后的钩子函数 - MatchGroupMember
+func (m *MatchGroupMember) AfterFind(tx *gorm.DB) error {
+	if len(m.UserData) > 0 {
+		return json.Unmarshal(m.UserData, &m.ParsedUserData)
+	}
+	return nil
+}
+
 // BeforeCreate 创建前的钩子函数 - MatchRecord
 func (mr *MatchRecord) BeforeCreate(tx *gorm.DB) error {
 	mr.MatchedAt = time.Now()
@@ -137,13 +680,141 @@ func (mp *MatchPair) AfterFind(tx *gorm.DB) error {
 	return nil
 }
 
+// MatchInputs 可验证匹配的输入快照，复算时按相同的输入重新执行匹配
+type MatchInputs struct {
+	Algorithm         string           `json:"algorithm"` // 打乱算法版本，为空表示最初的版本
+	Pairing           string           `json:"pairing"`   // 配对算法版本，为空表示最初的版本
+	MatchMode         string           `json:"matchMode"`
+	GroupSize         int              `json:"groupSize"`
+	LeftoverPolicy    string           `json:"leftoverPolicy"`
+	Fields            []PoolField      `json:"fields"`
+	Participants      []PoolUser       `json:"participants"` // 按用户ID升序排列
+	Constraints       []PoolConstraint `json:"constraints"`
+	AvoidRepeatRounds int              `json:"avoidRepeatRounds"`
+	RepeatPairs       [][2]uint        `json:"repeatPairs"` // 最近几轮中已配对过的用户ID对
+}
+
 // CreatePoolRequest 创建匹配池请求结构
 type CreatePoolRequest struct {
 	Name         string      `json:"name" binding:"required"`
 	Description  string      `json:"description"`
 	ValidUntil   time.Time   `json:"validUntil" binding:"required"`
 	CooldownTime int         `json:"cooldownTime"` // 冷却时间（秒），默认5秒
+	MatchMode    string      `json:"matchMode"`    // 匹配模式：pair（默认）、gift 或 score
 	Fields       []PoolField `json:"fields" binding:"required"`
+
+	GroupSize      int    `json:"groupSize"`      // 每组人数，默认2
+	LeftoverPolicy string `json:"leftoverPolicy"` // 剩余用户处理策略：smaller（默认）或 merge
+
+	AvoidRepeatRounds   int    `json:"avoidRepeatRounds"`   // 避免与最近 N 轮重复配对
+	RepeatIdentityField string `json:"repeatIdentityField"` // 识别同一参与者的字段名
+
+	Verifiable   bool   `json:"verifiable"`   // 是否启用可验证匹配（种子承诺-公开）
+	RandomSource string `json:"randomSource"` // 随机数来源，为空时使用全局默认来源
+
+	AutoMatch bool       `json:"autoMatch"` // 是否在截止时间自动匹配
+	MatchAt   *time.Time `json:"matchAt"`   // 自动匹配时间，为空时使用 validUntil
+
+	Status string `json:"status"` // 初始状态：open（默认）或 draft
+
+	MaxParticipants int `json:"maxParticipants"` // 人数上限，0 表示不限
+
+	VerifyEmail bool `json:"verifyEmail"` // 是否要求参与者验证邮箱
+
+	NotifyMatches    bool   `json:"notifyMatches"`    // 匹配完成后邮件通知参与者
+	NotifyEmailField string `json:"notifyEmailField"` // 收件邮箱所在字段，为空时使用联系方式
+	NotifyTemplate   string `json:"notifyTemplate"`   // 邮件正文模板，为空时使用默认模板
+
+	OrganizerEmail        string     `json:"organizerEmail"`        // 接收截止提醒的组织者邮箱
+	ReminderMailingList   []string   `json:"reminderMailingList"`   // 同时接收截止提醒的邮箱
+	CloseReminderHours    []int      `json:"closeReminderHours"`    // 截止报名前几小时提醒，如 [24, 1]
+	ExchangeAt            *time.Time `json:"exchangeAt"`            // 揭晓/交换礼物的日期
+	ExchangeReminderHours []int      `json:"exchangeReminderHours"` // 交换日期前几小时提醒已匹配的参与者
+}
+
+// JoinPoolResponse 加入匹配池响应结构
+type JoinPoolResponse struct {
+	UserID           uint `json:"userId"`
+	Waitlisted       bool `json:"waitlisted"`       // 匹配池已满，进入候补名单
+	WaitlistPosition int  `json:"waitlistPosition"` // 候补名单中的位置，从1开始
+
+	// 自助管理令牌，用于查看、修改、退出报名和查询本人的匹配结果；只返回这一次，请妥善保存
+	ManageToken string `json:"manageToken"`
+
+	// 匹配池要求验证邮箱，验证码已发送到联系邮箱，验证通过前不参与匹配
+	PendingVerification bool `json:"pendingVerification"`
+}
+
+// ParticipantEntry 参与者本人的报名信息
+type ParticipantEntry struct {
+	UserID           uint                   `json:"userId"`
+	PoolID           uint                   `json:"poolId"`
+	PoolName         string                 `json:"poolName"`
+	PoolStatus       string                 `json:"poolStatus"`
+	Status           string                 `json:"status"` // 候补用户为 waitlisted，未验证邮箱为 pending_verification，其余为匹配池状态
+	UserData         map[string]interface{} `json:"userData"`
+	ContactInfo      string                 `json:"contactInfo"`
+	JoinedAt         string                 `json:"joinedAt"`
+	Waitlisted       bool                   `json:"waitlisted"`
+	WaitlistPosition int                    `json:"waitlistPosition"`
+	Editable         bool                   `json:"editable"` // 匹配池仍在报名中，可以修改或退出
+	Fields           []PoolField            `json:"fields"`
+
+	PendingVerification bool    `json:"pendingVerification"` // 邮箱尚未验证，不参与匹配
+	VerifiedAt          *string `json:"verifiedAt"`
+}
+
+// VerifyEmailRequest 验证邮箱请求结构：使用管理令牌（请求头）或用户ID加验证码
+type VerifyEmailRequest struct {
+	UserID uint   `json:"userId"`
+	Code   string `json:"code" binding:"required"`
+}
+
+// LookupCodeRequest 请求查询匹配结果验证码的请求结构：匹配池ID和报名时使用的邮箱
+type LookupCodeRequest struct {
+	PoolID      uint   `json:"poolId" binding:"required"`
+	ContactInfo string `json:"contactInfo" binding:"required"`
+}
+
+// UpdateEntryRequest 参与者修改报名信息请求结构
+type UpdateEntryRequest struct {
+	UserData    map[string]interface{} `json:"userData" binding:"required"`
+	ContactInfo *string                `json:"contactInfo"` // 未提供时保持不变
+}
+
+// UpdatePoolRequest 更新匹配池请求结构，未提供的字段保持不变
+type UpdatePoolRequest struct {
+	Name         *string     `json:"name"`
+	Description  *string     `json:"description"`
+	ValidUntil   *time.Time  `json:"validUntil"`
+	CooldownTime *int        `json:"cooldownTime"`
+	Fields       []PoolField `json:"fields"` // 提供时替换全部字段，按字段名对应已有字段
+
+	MatchMode      *string `json:"matchMode"`
+	GroupSize      *int    `json:"groupSize"`
+	LeftoverPolicy *string `json:"leftoverPolicy"`
+
+	AvoidRepeatRounds   *int    `json:"avoidRepeatRounds"`
+	RepeatIdentityField *string `json:"repeatIdentityField"`
+
+	RandomSource *string `json:"randomSource"`
+
+	AutoMatch *bool      `json:"autoMatch"` // 关闭自动匹配时同时清除 matchAt
+	MatchAt   *time.Time `json:"matchAt"`
+
+	MaxParticipants *int `json:"maxParticipants"` // 提高上限或改为 0（不限）时候补用户自动递补
+
+	VerifyEmail *bool `json:"verifyEmail"` // 关闭后待验证的用户直接视为已验证
+
+	NotifyMatches    *bool   `json:"notifyMatches"`
+	NotifyEmailField *string `json:"notifyEmailField"`
+	NotifyTemplate   *string `json:"notifyTemplate"`
+
+	OrganizerEmail        *string    `json:"organizerEmail"`
+	ReminderMailingList   *[]string  `json:"reminderMailingList"`
+	CloseReminderHours    *[]int     `json:"closeReminderHours"` // 提供空数组时取消提醒
+	ExchangeAt            *time.Time `json:"exchangeAt"`
+	ExchangeReminderHours *[]int     `json:"exchangeReminderHours"`
 }
 
 // JoinPoolRequest 加入匹配池请求结构
@@ -153,9 +824,15 @@ type JoinPoolRequest struct {
 	ContactInfo string                 `json:"contactInfo"`
 }
 
+// TransitionPoolRequest 匹配池状态变更请求结构
+type TransitionPoolRequest struct {
+	Status string `json:"status" binding:"required"` // 目标状态
+}
+
 // StartMatchRequest 开始匹配请求结构
 type StartMatchRequest struct {
-	PoolID uint `json:"poolId" binding:"required"`
+	PoolID         uint   `json:"poolId" binding:"required"`
+	IdempotencyKey string `json:"-"` // 来自请求头 Idempotency-Key
 }
 
 // PoolResponse 匹配池响应结构
@@ -167,25 +844,159 @@ type PoolResponse struct {
 	ValidUntil    string      `json:"validUntil"`
 	Status        string      `json:"status"`
 	CooldownTime  int         `json:"cooldownTime"`
+	MatchMode     string      `json:"matchMode"`
 	LastMatchedAt *string     `json:"lastMatchedAt"`
 	Fields        []PoolField `json:"fields"`
+
+	GroupSize      int    `json:"groupSize"`
+	LeftoverPolicy string `json:"leftoverPolicy"`
+
+	AvoidRepeatRounds   int    `json:"avoidRepeatRounds"`
+	RepeatIdentityField string `json:"repeatIdentityField"`
+
+	Verifiable     bool   `json:"verifiable"`
+	SeedCommitment string `json:"seedCommitment,omitempty"`
+	RandomSource   string `json:"randomSource"`
+
+	AutoMatch bool    `json:"autoMatch"`
+	MatchAt   *string `json:"matchAt"`
+
+	OpenedAt          *string `json:"openedAt"`
+	ClosedAt          *string `json:"closedAt"`
+	MatchingStartedAt *string `json:"matchingStartedAt"`
+	ArchivedAt        *string `json:"archivedAt"`
+
+	MaxParticipants int   `json:"maxParticipants"` // 人数上限，0 表示不限
+	WaitlistCount   int64 `json:"waitlistCount"`   // 候补名单人数
+
+	VerifyEmail  bool  `json:"verifyEmail"`  // 是否要求参与者验证邮箱
+	PendingCount int64 `json:"pendingCount"` // 待验证邮箱的人数，不计入 userCount
+
+	NotifyMatches    bool   `json:"notifyMatches"`
+	NotifyEmailField string `json:"notifyEmailField"`
+	NotifyTemplate   string `json:"notifyTemplate,omitempty"`
+
+	// 提醒设置，组织者邮箱和邮件列表只在管理员的提醒接口中返回
+	CloseReminderHours    []int   `json:"closeReminderHours"`
+	ExchangeAt            *string `json:"exchangeAt"`
+	ExchangeReminderHours []int   `json:"exchangeReminderHours"`
+}
+
+// PoolStatsResponse 匹配池统计信息
+type PoolStatsResponse struct {
+	PoolID        uint         `json:"poolId"`
+	PoolName      string       `json:"poolName"`
+	UserCount     int64        `json:"userCount"`     // 正式参与者人数（统计范围）
+	WaitlistCount int64        `json:"waitlistCount"` // 候补名单人数（不计入统计）
+	Fields        []FieldStats `json:"fields"`
+	GeneratedAt   string       `json:"generatedAt"`
+}
+
+// FieldStats 选择类字段的作答统计
+type FieldStats struct {
+	Name       string        `json:"name"`
+	Label      string        `json:"label"`
+	Type       string        `json:"type"`
+	Visibility string        `json:"visibility"`
+	Answered   int64         `json:"answered"` // 填写了该字段的人数
+	Options    []OptionCount `json:"options"`
+}
+
+// OptionCount 单个选项的选择人数，多选字段中一人可计入多个选项
+type OptionCount struct {
+	Option string `json:"option"`
+	Count  int64  `json:"count"`
+}
+
+// DuplicateGroup 疑似重复报名的一组用户
+type DuplicateGroup struct {
+	Reason string          `json:"reason"`          // contact：联系方式相同；field：唯一字段取值相同；name：显示名称相同（仅供参考）
+	Field  string          `json:"field,omitempty"` // reason 为 field 时的字段名
+	Value  string          `json:"value"`           // 归一化后的取值
+	Users  []DuplicateUser `json:"users"`
+}
+
+// DuplicateUser 疑似重复报名的用户
+type DuplicateUser struct {
+	ID          uint   `json:"id"`
+	Name        string `json:"name"`
+	ContactInfo string `json:"contactInfo"`
+	JoinedAt    string `json:"joinedAt"`
+	Waitlisted  bool   `json:"waitlisted"`
 }
 
 // MatchResult 匹配结果结构
 type MatchResult struct {
-	PoolName   string            `json:"poolName"`
-	TotalUsers int               `json:"totalUsers"`
-	Pairs      []MatchPairResult `json:"pairs"`
-	Timestamp  string            `json:"timestamp"`
+	RecordID    uint              `json:"recordId"`
+	PoolID      uint              `json:"poolId"`
+	PoolName    string            `json:"poolName"`
+	Round       int               `json:"round"` // 该匹配池的第几轮匹配
+	TotalUsers  int               `json:"totalUsers"`
+	MatchMode   string            `json:"matchMode"`
+	GroupSize   int               `json:"groupSize"`
+	RepeatCount int               `json:"repeatCount"` // 无法避免的重复配对数量
+	TotalScore  float64           `json:"totalScore"`
+	Pairs       []MatchPairResult `json:"pairs"`
+	Timestamp   string            `json:"timestamp"`
+	Revision    int               `json:"revision"`
+
+	// 可验证匹配的种子信息，未启用时为空
+	Seed           string `json:"seed,omitempty"`
+	SeedCommitment string `json:"seedCommitment,omitempty"`
+
+	// 实际使用的随机数来源及 random.org 签名信息
+	RandomSource     string `json:"randomSource"`
+	RandomSerial     int64  `json:"randomSerial,omitempty"`
+	RandomSignature  string `json:"randomSignature,omitempty"`
+	RandomSignedData string `json:"randomSignedData,omitempty"`
+}
+
+// VerifyResult 匹配记录验证结果
+type VerifyResult struct {
+	RecordID          uint     `json:"recordId"`
+	Verified          bool     `json:"verified"`
+	CommitmentValid   bool     `json:"commitmentValid"`   // SHA-256(种子) 是否等于事先公布的承诺
+	ParticipantsValid bool     `json:"participantsValid"` // 参与者列表摘要是否一致
+	PairingValid      bool     `json:"pairingValid"`      // 复算的配对是否与记录一致
+	Seed              string   `json:"seed"`
+	SeedCommitment    string   `json:"seedCommitment"`
+	ParticipantDigest string   `json:"participantDigest"`
+	ParticipantIDs    []uint   `json:"participantIds"` // 按用户ID升序排列的参与者，即打乱前的输入顺序
+	ShuffledIDs       []uint   `json:"shuffledIds"`    // 由种子推导出的打乱顺序
+	Problems          []string `json:"problems"`
+
+	// 复算所需的公开输入：任何人都可以据此复算打乱顺序；PublicInputs 为 true 时还可以独立复算配对，
+	// 否则配对依赖不公开的约束或报名数据，只能由服务器复算
+	Algorithm      string    `json:"algorithm"` // 打乱算法版本
+	Pairing        string    `json:"pairing"`   // 配对算法版本
+	MatchMode      string    `json:"matchMode"`
+	GroupSize      int       `json:"groupSize"`
+	LeftoverPolicy string    `json:"leftoverPolicy"`
+	RepeatPairs    [][2]uint `json:"repeatPairs"`    // 需要避免的历史配对
+	HasConstraints bool      `json:"hasConstraints"` // 是否有排除约束（约束内容不公开）
+	PublicInputs   bool      `json:"publicInputs"`   // 配对是否仅凭公开的输入即可复算
 }
 
 // MatchPairResult 匹配配对结果结构
 type MatchPairResult struct {
-	Pair      int                    `json:"pair"`
-	User1     string                 `json:"user1"`
-	User2     string                 `json:"user2,omitempty"`
-	User1Data map[string]interface{} `json:"user1Data"`
-	User2Data map[string]interface{} `json:"user2Data,omitempty"`
+	Pair        int                    `json:"pair"`
+	User1ID     uint                   `json:"user1Id"`
+	User2ID     *uint                  `json:"user2Id,omitempty"`
+	User1       string                 `json:"user1"`
+	User2       string                 `json:"user2,omitempty"`
+	User1Data   map[string]interface{} `json:"user1Data"`
+	User2Data   map[string]interface{} `json:"user2Data,omitempty"`
+	Directed    bool                   `json:"directed"`              // 是否为有向配对（User1 送礼物给 User2）
+	Description string                 `json:"description,omitempty"` // 例如 "A 送礼物给 B"
+	Members     []MatchMemberResult    `json:"members"`               // 分组的全部成员
+	Score       float64                `json:"score"`                 // score 模式下的配对分数
+}
+
+// MatchMemberResult 匹配分组成员结果结构
+type MatchMemberResult struct {
+	UserID uint                   `json:"userId"`
+	Name   string                 `json:"name"`
+	Data   map[string]interface{} `json:"data"`
 }
 
 // HistoryRecord 历史记录结构
@@ -195,8 +1006,28 @@ type HistoryRecord struct {
 	MatchDate   string `json:"matchDate"`
 	TotalUsers  int    `json:"totalUsers"`
 	PairsCount  int    `json:"pairsCount"`
+	GroupSize   int    `json:"groupSize"`
 	HasLoneUser bool   `json:"hasLoneUser"`
+	RepeatCount int    `json:"repeatCount"`
 	Status      string `json:"status"`
+	Revision    int    `json:"revision"`
+
+	RandomSource string `json:"randomSource"`
+}
+
+// CreateConstraintRequest 创建排除约束请求结构
+type CreateConstraintRequest struct {
+	Type      string `json:"type" binding:"required"`
+	User1ID   *uint  `json:"user1Id"`
+	User2ID   *uint  `json:"user2Id"`
+	FieldName string `json:"fieldName"`
+	Note      string `json:"note"`
+}
+
+// RepairMatchRequest 修复匹配记录请求结构
+type RepairMatchRequest struct {
+	UserID uint   `json:"userId" binding:"required"` // 退出的参与者
+	Note   string `json:"note"`
 }
 
 // AdminLoginRequest 管理员登录请求
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"fmt"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// ConstraintService 排除约束服务
+type ConstraintService struct {
+	db *gorm.DB
+}
+
+// NewConstraintService 创建排除约束服务实例
+func NewConstraintService(db *gorm.DB) *ConstraintService {
+	return &ConstraintService{
+		db: db,
+	}
+}
+
+// GetConstraints 获取匹配池的所有排除约束
+func (s *ConstraintService) GetConstraints(poolID uint) ([]models.PoolConstraint, error) {
+	var pool models.MatchPool
+	if err := s.db.First(&pool, poolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+
+	constraints := []models.PoolConstraint{}
+	if err := s.db.Where("pool_id = ?", poolID).Order("id").Find(&constraints).Error; err != nil {
+		return nil, err
+	}
+
+	return constraints, nil
+}
+
+// CreateConstraint 为匹配池添加排除约束
+func (s *ConstraintService) CreateConstraint(poolID uint, req *models.CreateConstraintRequest) (*models.PoolConstraint, error) {
+	var pool models.MatchPool
+	if err := s.db.Preload("Fields").First(&pool, poolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+
+	constraint := &models.PoolConstraint{
+		PoolID: poolID,
+		Type:   req.Type,
+		Note:   req.Note,
+	}
+
+	switch req.Type {
+	case models.ConstraintExcludePair:
+		if req.User1ID == nil || req.User2ID == nil {
+			return nil, fmt.Errorf("exclude_pair 约束需要指定 user1Id 和 user2Id")
+		}
+		if *req.User1ID == *req.User2ID {
+			return nil, fmt.Errorf("exclude_pair 约束的两名用户不能相同")
+		}
+
+		var count int64
+		s.db.Model(&models.PoolUser{}).
+			Where("pool_id = ? AND id IN ?", poolID, []uint{*req.User1ID, *req.User2ID}).
+			Count(&count)
+		if count != 2 {
+			return nil, fmt.Errorf("指定的用户不在该匹配池中")
+		}
+
+		constraint.User1ID = req.User1ID
+		constraint.User2ID = req.User2ID
+
+	case models.ConstraintExcludeSameField:
+		if req.FieldName == "" {
+			return nil, fmt.Errorf("exclude_same_field 约束需要指定 fieldName")
+		}
+
+		found := false
+		for _, field := range pool.Fields {
+			if field.FieldName == req.FieldName {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("匹配池中不存在字段: %s", req.FieldName)
+		}
+
+		constraint.FieldName = req.FieldName
+
+	default:
+		return nil, fmt.Errorf("不支持的约束类型: %s", req.Type)
+	}
+
+	if err := s.db.Create(constraint).Error; err != nil {
+		return nil, err
+	}
+
+	log.Printf("✅ 添加排除约束成功: Pool %d, 约束 %d (%s)", poolID, constraint.ID, constraint.Type)
+	return constraint, nil
+}
+
+// DeleteConstraint 删除匹配池的排除约束
+func (s *ConstraintService) DeleteConstraint(poolID, constraintID uint) error {
+	result := s.db.Where("pool_id = ?", poolID).Delete(&models.PoolConstraint{}, constraintID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("约束不存在")
+	}
+
+	log.Printf("🗑️ 删除排除约束成功: Pool %d, 约束 %d", poolID, constraintID)
+	return nil
+}
+
+// deleteUserConstraints 删除引用指定用户的排除约束（用户被移除时调用）
+func deleteUserConstraints(db *gorm.DB, userID uint) error {
+	return db.Where("type = ? AND (user1_id = ? OR user2_id = ?)", models.ConstraintExcludePair, userID, userID).
+		Delete(&models.PoolConstraint{}).Error
+}
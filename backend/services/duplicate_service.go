@@ -0,0 +1,204 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DuplicateJoinError 重复报名错误，键为字段名（联系方式为 contactInfo）
+type DuplicateJoinError struct {
+	Fields FieldErrors
+}
+
+// Error 实现 error 接口
+func (e *DuplicateJoinError) Error() string {
+	names := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = e.Fields[name]
+	}
+	return "重复报名: " + strings.Join(messages, "；")
+}
+
+// normalizeContact 归一化联系方式：电话号码只保留数字（国际区号以 + 开头，+86 的手机号去掉区号），
+// 其他联系方式（邮箱、微信号等）去掉空白并转为小写
+func normalizeContact(contact string) string {
+	contact = strings.TrimSpace(contact)
+	if contact == "" {
+		return ""
+	}
+	if phone := normalizePhone(contact); phone != "" {
+		return phone
+	}
+	return strings.ToLower(strings.Join(strings.Fields(contact), ""))
+}
+
+// normalizePhone 将电话号码归一化为纯数字，不是电话号码时返回空字符串
+func normalizePhone(value string) string {
+	plus := strings.HasPrefix(value, "+")
+	if plus {
+		value = value[1:]
+	}
+
+	var digits strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return ""
+		}
+	}
+
+	number := digits.String()
+	if !plus && strings.HasPrefix(number, "00") {
+		plus = true
+		number = number[2:]
+	}
+	if len(number) < 7 || len(number) > 15 {
+		return ""
+	}
+	if plus && strings.HasPrefix(number, "86") && len(number) == 13 {
+		return number[2:]
+	}
+	if plus {
+		return "+" + number
+	}
+	return number
+}
+
+// uniqueValueKey 唯一字段取值的归一化表示，空值返回空字符串
+func uniqueValueKey(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return normalizeContact(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return strings.ToLower(fmt.Sprint(v))
+	}
+}
+
+// checkDuplicateJoin 检查同一匹配池中是否已有相同联系方式或唯一字段取值相同的报名，
+// excludeUserID 为修改报名信息时的本人（加入时为 0）；需要在锁定匹配池的事务中调用
+func checkDuplicateJoin(tx *gorm.DB, pool *models.MatchPool, contact string, data map[string]interface{}, excludeUserID uint) error {
+	var users []models.PoolUser
+	if err := tx.Where("pool_id = ? AND id <> ?", pool.ID, excludeUserID).Find(&users).Error; err != nil {
+		return err
+	}
+
+	errs := FieldErrors{}
+	for _, user := range users {
+		if contact != "" && normalizeContact(user.ContactInfo) == contact {
+			errs["contactInfo"] = "该联系方式已报名此匹配池"
+		}
+		for i := range pool.Fields {
+			field := &pool.Fields[i]
+			if !field.Unique {
+				continue
+			}
+			key := uniqueValueKey(data[field.FieldName])
+			if key != "" && key == uniqueValueKey(user.ParsedUserData[field.FieldName]) {
+				errs[field.FieldName] = fmt.Sprintf("%s已被其他报名使用", fieldLabel(field))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return &DuplicateJoinError{Fields: errs}
+	}
+	return nil
+}
+
+// DuplicateService 重复报名检查服务
+type DuplicateService struct {
+	db *gorm.DB
+}
+
+// NewDuplicateService 创建重复报名检查服务实例
+func NewDuplicateService(db *gorm.DB) *DuplicateService {
+	return &DuplicateService{db: db}
+}
+
+// FindDuplicates 找出匹配池中疑似重复报名的用户：联系方式相同、唯一字段取值相同，以及显示名称相同（仅供参考）；
+// 取值按归一化后比较，因此也能发现归一化之前加入的重复报名
+func (s *DuplicateService) FindDuplicates(poolID uint) ([]models.DuplicateGroup, error) {
+	var pool models.MatchPool
+	if err := s.db.Preload("Fields").First(&pool, poolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+
+	var users []models.PoolUser
+	if err := s.db.Where("pool_id = ?", poolID).Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	groups := []models.DuplicateGroup{}
+	collect := func(reason, field string, key func(user *models.PoolUser) string) {
+		var order []string
+		byKey := make(map[string][]models.DuplicateUser)
+		for i := range users {
+			value := key(&users[i])
+			if value == "" {
+				continue
+			}
+			if _, ok := byKey[value]; !ok {
+				order = append(order, value)
+			}
+			byKey[value] = append(byKey[value], s.duplicateUser(&users[i]))
+		}
+		for _, value := range order {
+			if len(byKey[value]) > 1 {
+				groups = append(groups, models.DuplicateGroup{
+					Reason: reason,
+					Field:  field,
+					Value:  value,
+					Users:  byKey[value],
+				})
+			}
+		}
+	}
+
+	collect("contact", "", func(user *models.PoolUser) string {
+		return normalizeContact(user.ContactInfo)
+	})
+	for i := range pool.Fields {
+		field := pool.Fields[i]
+		if !field.Unique {
+			continue
+		}
+		collect("field", field.FieldName, func(user *models.PoolUser) string {
+			return uniqueValueKey(user.ParsedUserData[field.FieldName])
+		})
+	}
+	// 只比较名称字段，避免按其他字段误判为同名
+	collect("name", "", func(user *models.PoolUser) string {
+		return strings.ToLower(strings.Join(strings.Fields(userNameField(user.ParsedUserData)), ""))
+	})
+
+	return groups, nil
+}
+
+// duplicateUser 转换为疑似重复报名的用户信息
+func (s *DuplicateService) duplicateUser(user *models.PoolUser) models.DuplicateUser {
+	return models.DuplicateUser{
+		ID:          user.ID,
+		Name:        userDisplayName(user.ParsedUserData),
+		ContactInfo: user.ContactInfo,
+		JoinedAt:    user.JoinedAt.Format("2006-01-02 15:04:05"),
+		Waitlisted:  user.Waitlisted,
+	}
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"christmas-link-backend/cache"
+	"christmas-link-backend/models"
+	"context"
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// poolEventBuffer 每个订阅者的事件缓冲数量，缓冲满时断开该订阅者，由客户端重连后重新获取匹配池信息
+const poolEventBuffer = 32
+
+// EventBroker 匹配池实时事件分发：Redis 可用时事件经 Redis 发布订阅转发到所有实例（包括发布者自己），
+// 再由各实例分发给本地订阅者；Redis 不可用时只在本实例内分发
+type EventBroker struct {
+	mu           sync.RWMutex
+	subscribers  map[uint]map[chan models.PoolEvent]struct{}
+	cacheService *cache.CacheService
+	viaRedis     bool
+}
+
+// poolEvents 进程内唯一的事件分发实例，各服务和控制器共用
+var poolEvents = &EventBroker{
+	subscribers:  make(map[uint]map[chan models.PoolEvent]struct{}),
+	cacheService: cache.NewCacheService(),
+}
+
+// GetEventBroker 获取匹配池实时事件分发实例
+func GetEventBroker() *EventBroker {
+	return poolEvents
+}
+
+// Start 订阅 Redis 频道并在后台接收其他实例发布的事件；Redis 未连接时只使用本地分发
+func (b *EventBroker) Start() {
+	pubsub := b.cacheService.Subscribe(cache.ChannelPoolEvents)
+	if pubsub == nil {
+		log.Println("📡 实时事件仅在本实例内分发（Redis 未连接）")
+		return
+	}
+	// 等待订阅确认，确认后发布的事件才能收到
+	if _, err := pubsub.Receive(context.Background()); err != nil {
+		log.Printf("⚠️ 订阅实时事件频道失败，仅在本实例内分发: %v", err)
+		pubsub.Close()
+		return
+	}
+
+	b.mu.Lock()
+	b.viaRedis = true
+	b.mu.Unlock()
+
+	go func() {
+		for msg := range pubsub.Channel() {
+			var event models.PoolEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				log.Printf("⚠️ 解析实时事件失败: %v", err)
+				continue
+			}
+			b.dispatch(event)
+		}
+	}()
+	log.Printf("📡 实时事件经 Redis 频道 %s 分发", cache.ChannelPoolEvents)
+}
+
+// Subscribe 订阅匹配池的实时事件，返回事件通道和取消订阅函数；
+// 订阅者处理过慢导致缓冲已满时通道会被关闭
+func (b *EventBroker) Subscribe(poolID uint) (<-chan models.PoolEvent, func()) {
+	ch := make(chan models.PoolEvent, poolEventBuffer)
+
+	b.mu.Lock()
+	if b.subscribers[poolID] == nil {
+		b.subscribers[poolID] = make(map[chan models.PoolEvent]struct{})
+	}
+	b.subscribers[poolID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	cancel := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			b.remove(poolID, ch)
+		})
+	}
+	return ch, cancel
+}
+
+// remove 移除订阅者并关闭其通道，调用方需持有写锁
+func (b *EventBroker) remove(poolID uint, ch chan models.PoolEvent) {
+	subscribers := b.subscribers[poolID]
+	if _, ok := subscribers[ch]; !ok {
+		return
+	}
+	delete(subscribers, ch)
+	close(ch)
+	if len(subscribers) == 0 {
+		delete(b.subscribers, poolID)
+	}
+}
+
+// Publish 发布匹配池事件，在事件所在的事务提交后调用，失败只记录日志
+func (b *EventBroker) Publish(eventType string, poolID uint, data interface{}) {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("⚠️ 序列化实时事件失败: %v", err)
+		return
+	}
+	event := models.PoolEvent{
+		Type:      eventType,
+		PoolID:    poolID,
+		Data:      payload,
+		Timestamp: time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	b.mu.RLock()
+	viaRedis := b.viaRedis
+	b.mu.RUnlock()
+	if viaRedis {
+		published, err := b.cacheService.Publish(cache.ChannelPoolEvents, event)
+		if published {
+			return
+		}
+		log.Printf("⚠️ 通过 Redis 发布实时事件失败，仅在本实例内分发: %v", err)
+	}
+	b.dispatch(event)
+}
+
+// dispatch 把事件发送给本实例中订阅该匹配池的订阅者
+func (b *EventBroker) dispatch(event models.PoolEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.PoolID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("⚠️ 实时事件订阅者处理过慢，断开连接: Pool %d", event.PoolID)
+			b.remove(event.PoolID, ch)
+		}
+	}
+}
+
+// publishParticipantEvent 发布参与者变动事件，附带匹配池最新的人数
+func publishParticipantEvent(db *gorm.DB, eventType string, poolID, userID uint, waitlisted bool) {
+	pool := models.MatchPool{ID: poolID}
+	poolEvents.Publish(eventType, poolID, map[string]interface{}{
+		"userId":        userID,
+		"waitlisted":    waitlisted,
+		"userCount":     pool.GetUserCount(db),
+		"waitlistCount": pool.GetWaitlistCount(db),
+		"pendingCount":  pool.GetPendingCount(db),
+	})
+}
+
+// publishStatusEvent 发布匹配池状态变更事件
+func publishStatusEvent(poolID uint, from, to string) {
+	if from == to {
+		return
+	}
+	poolEvents.Publish(models.PoolEventStatusChanged, poolID, map[string]interface{}{
+		"from": from,
+		"to":   to,
+	})
+}
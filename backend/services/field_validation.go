@@ -0,0 +1,305 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// dateLayout 日期字段的格式
+const dateLayout = "2006-01-02"
+
+// FieldErrors 用户数据的逐字段校验错误，键为字段名
+type FieldErrors map[string]string
+
+// Error 实现 error 接口，按字段名排序拼接错误信息
+func (e FieldErrors) Error() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	messages := make([]string, len(names))
+	for i, name := range names {
+		messages[i] = e[name]
+	}
+	return "用户数据校验失败: " + strings.Join(messages, "；")
+}
+
+// validateFieldDefinitions 校验匹配池字段定义：字段类型、长度限制、正则表达式和可选值
+func validateFieldDefinitions(fields []models.PoolField) error {
+	for _, field := range fields {
+		switch field.FieldType {
+		case models.FieldTypeText, models.FieldTypeTextarea, models.FieldTypeNumber,
+			models.FieldTypeEmail, models.FieldTypeURL, models.FieldTypeSelect,
+			models.FieldTypeMultiSelect, models.FieldTypeBoolean, models.FieldTypeDate:
+		default:
+			return fmt.Errorf("字段 %s 的类型无效: %s", field.FieldName, field.FieldType)
+		}
+
+		if field.MinLength < 0 || field.MaxLength < 0 {
+			return fmt.Errorf("字段 %s 的长度限制不能为负数", field.FieldName)
+		}
+		if field.MaxLength > 0 && field.MinLength > field.MaxLength {
+			return fmt.Errorf("字段 %s 的最小长度不能大于最大长度", field.FieldName)
+		}
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return fmt.Errorf("字段 %s 的正则表达式无效: %v", field.FieldName, err)
+			}
+		}
+		switch field.Visibility {
+		case "", models.FieldVisibilityPublic, models.FieldVisibilityPartner, models.FieldVisibilityAdmin:
+		default:
+			return fmt.Errorf("字段 %s 的可见性无效: %s", field.FieldName, field.Visibility)
+		}
+
+		options, err := field.OptionList()
+		if err != nil {
+			return fmt.Errorf("字段 %s 的可选值必须是字符串数组", field.FieldName)
+		}
+
+		switch field.FieldType {
+		case models.FieldTypeSelect, models.FieldTypeMultiSelect:
+			if len(options) == 0 {
+				return fmt.Errorf("字段 %s 是选择类型，至少需要一个可选值", field.FieldName)
+			}
+			seen := make(map[string]bool, len(options))
+			for _, option := range options {
+				if strings.TrimSpace(option) == "" {
+					return fmt.Errorf("字段 %s 的可选值不能为空", field.FieldName)
+				}
+				if seen[option] {
+					return fmt.Errorf("字段 %s 的可选值重复: %s", field.FieldName, option)
+				}
+				seen[option] = true
+			}
+			if field.FieldType == models.FieldTypeMultiSelect && field.MinLength > len(options) {
+				return fmt.Errorf("字段 %s 的最少选择数不能大于可选值数量", field.FieldName)
+			}
+		case models.FieldTypeBoolean, models.FieldTypeDate:
+			if len(options) > 0 {
+				return fmt.Errorf("字段 %s 的类型 %s 不支持设置可选值", field.FieldName, field.FieldType)
+			}
+		}
+	}
+	return nil
+}
+
+// validateUserData 按匹配池字段定义校验用户数据，返回规范化后的数据（数字字段统一为数值）
+func validateUserData(fields []models.PoolField, data map[string]interface{}) (map[string]interface{}, error) {
+	errs := FieldErrors{}
+	cleaned := make(map[string]interface{}, len(data))
+
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field.FieldName] = true
+
+		value, present := data[field.FieldName]
+		if !present || isBlank(value) {
+			if field.IsRequired {
+				errs[field.FieldName] = fmt.Sprintf("%s为必填项", fieldLabel(&field))
+			}
+			continue
+		}
+
+		normalized, message := validateFieldValue(&field, value)
+		if message != "" {
+			errs[field.FieldName] = message
+			continue
+		}
+		// 必填的勾选项必须勾选（如同意活动规则）
+		if field.FieldType == models.FieldTypeBoolean && field.IsRequired && normalized == false {
+			errs[field.FieldName] = fmt.Sprintf("%s必须勾选", fieldLabel(&field))
+			continue
+		}
+		cleaned[field.FieldName] = normalized
+	}
+
+	// 不接受字段定义之外的数据
+	for name := range data {
+		if !known[name] {
+			errs[name] = fmt.Sprintf("%s 不是该匹配池的字段", name)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return cleaned, nil
+}
+
+// validateFieldValue 校验单个字段的取值，返回规范化后的值或错误信息
+func validateFieldValue(field *models.PoolField, value interface{}) (interface{}, string) {
+	label := fieldLabel(field)
+
+	switch field.FieldType {
+	case models.FieldTypeNumber:
+		number, ok := toNumber(value)
+		if !ok {
+			return nil, fmt.Sprintf("%s必须是数字", label)
+		}
+		return number, ""
+	case models.FieldTypeBoolean:
+		checked, ok := toBool(value)
+		if !ok {
+			return nil, fmt.Sprintf("%s必须是 true 或 false", label)
+		}
+		return checked, ""
+	case models.FieldTypeMultiSelect:
+		return validateMultiSelect(field, value)
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return nil, fmt.Sprintf("%s必须是文本", label)
+	}
+	str = strings.TrimSpace(str)
+
+	length := utf8.RuneCountInString(str)
+	if field.MinLength > 0 && length < field.MinLength {
+		return nil, fmt.Sprintf("%s至少需要 %d 个字符", label, field.MinLength)
+	}
+	if field.MaxLength > 0 && length > field.MaxLength {
+		return nil, fmt.Sprintf("%s不能超过 %d 个字符", label, field.MaxLength)
+	}
+
+	switch field.FieldType {
+	case models.FieldTypeEmail:
+		if address, err := mail.ParseAddress(str); err != nil || address.Address != str {
+			return nil, fmt.Sprintf("%s不是有效的邮箱地址", label)
+		}
+	case models.FieldTypeURL:
+		if u, err := url.ParseRequestURI(str); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Sprintf("%s不是有效的网址（需以 http:// 或 https:// 开头）", label)
+		}
+	case models.FieldTypeDate:
+		if _, err := time.Parse(dateLayout, str); err != nil {
+			return nil, fmt.Sprintf("%s不是有效的日期（格式为 YYYY-MM-DD）", label)
+		}
+	}
+
+	if field.Pattern != "" {
+		if matched, err := regexp.MatchString(field.Pattern, str); err != nil || !matched {
+			return nil, fmt.Sprintf("%s的格式不正确", label)
+		}
+	}
+
+	options, _ := field.OptionList()
+	if len(options) > 0 && !containsString(options, str) {
+		return nil, fmt.Sprintf("%s只能是以下取值之一: %s", label, strings.Join(options, "、"))
+	}
+
+	return str, ""
+}
+
+// validateMultiSelect 校验多选字段：取值为可选值组成的数组，去重后按可选值的顺序保存，
+// minLength/maxLength 表示最少、最多选择的数量
+func validateMultiSelect(field *models.PoolField, value interface{}) (interface{}, string) {
+	label := fieldLabel(field)
+
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Sprintf("%s必须是选项数组", label)
+	}
+
+	options, _ := field.OptionList()
+	selected := make(map[string]bool, len(items))
+	for _, item := range items {
+		str, ok := item.(string)
+		if !ok {
+			return nil, fmt.Sprintf("%s的选项必须是文本", label)
+		}
+		str = strings.TrimSpace(str)
+		if !containsString(options, str) {
+			return nil, fmt.Sprintf("%s只能从以下取值中选择: %s", label, strings.Join(options, "、"))
+		}
+		selected[str] = true
+	}
+
+	if field.MinLength > 0 && len(selected) < field.MinLength {
+		return nil, fmt.Sprintf("%s至少需要选择 %d 项", label, field.MinLength)
+	}
+	if field.MaxLength > 0 && len(selected) > field.MaxLength {
+		return nil, fmt.Sprintf("%s最多只能选择 %d 项", label, field.MaxLength)
+	}
+
+	// 按可选值顺序保存，使相同的选择得到相同的表示（字段相同判断、排除约束依赖于此）
+	result := make([]interface{}, 0, len(selected))
+	for _, option := range options {
+		if selected[option] {
+			result = append(result, option)
+		}
+	}
+	return result, ""
+}
+
+// fieldLabel 字段的显示名称，未设置时使用字段名
+func fieldLabel(field *models.PoolField) string {
+	if field.FieldLabel != "" {
+		return field.FieldLabel
+	}
+	return field.FieldName
+}
+
+// isBlank 判断取值是否为空
+func isBlank(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v) == ""
+	case []interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// toNumber 将 JSON 数值或数字字符串转换为 float64
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case string:
+		number, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return number, err == nil && !math.IsNaN(number) && !math.IsInf(number, 0)
+	default:
+		return 0, false
+	}
+}
+
+// toBool 将 JSON 布尔值或 "true"/"false" 字符串转换为 bool
+func toBool(value interface{}) (bool, bool) {
+	switch v := value.(type) {
+	case bool:
+		return v, true
+	case string:
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "true":
+			return true, true
+		case "false":
+			return false, true
+		}
+	}
+	return false, false
+}
+
+// containsString 判断字符串是否在列表中
+func containsString(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
@@ -3,6 +3,8 @@ package services
 import (
 	"christmas-link-backend/cache"
 	"christmas-link-backend/models"
+	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
@@ -47,8 +49,13 @@ func (s *HistoryService) GetHistory() ([]models.HistoryRecord, error) {
 			MatchDate:   record.MatchedAt.Format("2006-01-02 15:04:05"),
 			TotalUsers:  record.TotalUsers,
 			PairsCount:  record.PairsCount,
+			GroupSize:   record.GroupSize,
 			HasLoneUser: record.HasLoneUser,
+			RepeatCount: record.RepeatCount,
 			Status:      record.Status,
+			Revision:    record.Revision,
+
+			RandomSource: record.RandomSource,
 		}
 	}
 
@@ -72,31 +79,40 @@ func (s *HistoryService) GetHistoryByID(id uint) (*models.MatchResult, error) {
 
 	// 从数据库查询
 	var record models.MatchRecord
-	if err := s.db.Preload("Pairs").First(&record, id).Error; err != nil {
+	if err := s.db.Preload("Pairs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("pair_number")
+	}).Preload("Pairs.Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&record, id).Error; err != nil {
 		return nil, err
 	}
 
 	// 构建返回结果
 	result = models.MatchResult{
-		PoolName:   record.PoolName,
-		TotalUsers: record.TotalUsers,
-		Pairs:      make([]models.MatchPairResult, len(record.Pairs)),
-		Timestamp:  record.MatchedAt.Format("2006-01-02 15:04:05"),
+		RecordID:    record.ID,
+		PoolID:      record.PoolID,
+		PoolName:    record.PoolName,
+		Round:       matchRound(s.db, record.PoolID, record.ID),
+		TotalUsers:  record.TotalUsers,
+		MatchMode:   record.MatchMode,
+		GroupSize:   record.GroupSize,
+		RepeatCount: record.RepeatCount,
+		TotalScore:  record.TotalScore,
+		Revision:    record.Revision,
+		Pairs:       make([]models.MatchPairResult, len(record.Pairs)),
+		Timestamp:   record.MatchedAt.Format("2006-01-02 15:04:05"),
+
+		Seed:           record.Seed,
+		SeedCommitment: record.SeedCommitment,
+
+		RandomSource:     record.RandomSource,
+		RandomSerial:     record.RandomSerial,
+		RandomSignature:  record.RandomSignature,
+		RandomSignedData: record.RandomSignedData,
 	}
 
 	for i, pair := range record.Pairs {
-		user1Name := s.getUserDisplayName(pair.ParsedUser1Data)
-		result.Pairs[i] = models.MatchPairResult{
-			Pair:      pair.PairNumber,
-			User1:     user1Name,
-			User1Data: pair.ParsedUser1Data,
-		}
-
-		if pair.ParsedUser2Data != nil {
-			user2Name := s.getUserDisplayName(pair.ParsedUser2Data)
-			result.Pairs[i].User2 = user2Name
-			result.Pairs[i].User2Data = pair.ParsedUser2Data
-		}
+		result.Pairs[i] = newMatchPairResult(pair, s.getUserDisplayName)
 	}
 
 	// 缓存结果
@@ -106,6 +122,148 @@ func (s *HistoryService) GetHistoryByID(id uint) (*models.MatchResult, error) {
 	return &result, nil
 }
 
+// matchRound 计算匹配记录是所在匹配池的第几轮匹配
+func matchRound(db *gorm.DB, poolID, recordID uint) int {
+	var count int64
+	db.Model(&models.MatchRecord{}).Where("pool_id = ? AND id <= ?", poolID, recordID).Count(&count)
+	return int(count)
+}
+
+// VerifyRecord 验证可验证匹配的记录：检查种子与承诺是否一致，并用公开的种子复算配对
+func (s *HistoryService) VerifyRecord(id uint) (*models.VerifyResult, error) {
+	var record models.MatchRecord
+	if err := s.db.Preload("Pairs", func(db *gorm.DB) *gorm.DB {
+		return db.Order("pair_number")
+	}).Preload("Pairs.Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&record, id).Error; err != nil {
+		return nil, fmt.Errorf("历史记录不存在")
+	}
+
+	if record.Seed == "" {
+		return nil, fmt.Errorf("该匹配记录未启用可验证匹配")
+	}
+
+	var inputs models.MatchInputs
+	if err := json.Unmarshal([]byte(record.MatchInputs), &inputs); err != nil {
+		return nil, fmt.Errorf("解析匹配输入失败: %v", err)
+	}
+
+	// 早于算法版本号的记录使用的是第一个版本
+	if inputs.Algorithm == "" {
+		inputs.Algorithm = verifiableAlgorithm
+	}
+	if inputs.Pairing == "" {
+		inputs.Pairing = pairingAlgorithm
+	}
+
+	participants := sortUsersByID(inputs.Participants)
+	result := &models.VerifyResult{
+		RecordID:          record.ID,
+		Seed:              record.Seed,
+		SeedCommitment:    record.SeedCommitment,
+		ParticipantDigest: record.ParticipantDigest,
+		ParticipantIDs:    make([]uint, len(participants)),
+		Problems:          []string{},
+		Algorithm:         inputs.Algorithm,
+		Pairing:           inputs.Pairing,
+		MatchMode:         inputs.MatchMode,
+		GroupSize:         inputs.GroupSize,
+		LeftoverPolicy:    inputs.LeftoverPolicy,
+		RepeatPairs:       inputs.RepeatPairs,
+		HasConstraints:    len(inputs.Constraints) > 0,
+		PublicInputs:      len(inputs.Constraints) == 0 && inputs.MatchMode != models.MatchModeScore,
+	}
+	if result.RepeatPairs == nil {
+		result.RepeatPairs = [][2]uint{}
+	}
+	supported := inputs.Algorithm == verifiableAlgorithm && inputs.Pairing == pairingAlgorithm
+	if inputs.Algorithm != verifiableAlgorithm {
+		result.Problems = append(result.Problems, fmt.Sprintf("不支持的打乱算法版本: %s", inputs.Algorithm))
+	}
+	if inputs.Pairing != pairingAlgorithm {
+		result.Problems = append(result.Problems, fmt.Sprintf("不支持的配对算法版本: %s（当前版本 %s），无法复算配对", inputs.Pairing, pairingAlgorithm))
+	}
+	for i := range participants {
+		result.ParticipantIDs[i] = participants[i].ID
+	}
+
+	// 1. 公开的种子必须与匹配前公布的承诺一致
+	result.CommitmentValid = seedCommitment(record.Seed) == record.SeedCommitment
+	if !result.CommitmentValid {
+		result.Problems = append(result.Problems, "种子的 SHA-256 与公布的承诺不一致")
+	}
+
+	// 2. 参与者列表摘要必须与记录一致
+	result.ParticipantsValid = participantDigest(participants) == record.ParticipantDigest
+	if !result.ParticipantsValid {
+		result.Problems = append(result.Problems, "参与者列表摘要与记录不一致")
+	}
+
+	// 3. 按相同的输入复算配对，结果必须与记录逐组一致
+	shuffled := seededShuffle(participants, record.Seed, record.ParticipantDigest)
+	result.ShuffledIDs = make([]uint, len(shuffled))
+	for i, user := range shuffled {
+		result.ShuffledIDs[i] = user.ID
+	}
+
+	var history *repeatHistory
+	if inputs.AvoidRepeatRounds > 0 {
+		history = repeatHistoryFromPairs(inputs.RepeatPairs)
+	}
+	plan := newMatchPlan(&models.MatchPool{
+		MatchMode:      inputs.MatchMode,
+		GroupSize:      inputs.GroupSize,
+		LeftoverPolicy: inputs.LeftoverPolicy,
+		Fields:         inputs.Fields,
+	}, len(shuffled))
+
+	// 版本不受支持时不复算配对，避免把算法变化误报为记录被篡改
+	if supported {
+		pairs, _, err := matchShuffled(shuffled, plan, inputs.Constraints, history, s.getUserDisplayName)
+		if err != nil {
+			result.Problems = append(result.Problems, fmt.Sprintf("复算匹配失败: %v", err))
+		} else {
+			// 匹配后经过修订的记录先还原最初的配对再比较
+			recorded := record.Pairs
+			if record.Revision > 0 {
+				var revisions []models.MatchRevision
+				if err := s.db.Where("record_id = ?", record.ID).Order("revision").Find(&revisions).Error; err != nil {
+					return nil, err
+				}
+				recorded = originalPairing(record.Pairs, revisions)
+			}
+			result.PairingValid = samePairing(pairs, recorded)
+			if !result.PairingValid {
+				result.Problems = append(result.Problems, "复算的配对与记录不一致")
+			}
+		}
+	}
+
+	result.Verified = result.CommitmentValid && result.ParticipantsValid && result.PairingValid && supported
+	log.Printf("🔍 验证匹配记录 %d: %v", record.ID, result.Verified)
+	return result, nil
+}
+
+// samePairing 比较两组配对的成员及顺序是否完全一致
+func samePairing(computed, recorded []models.MatchPair) bool {
+	if len(computed) != len(recorded) {
+		return false
+	}
+	for i := range computed {
+		a, b := pairMembers(computed[i]), pairMembers(recorded[i])
+		if len(a) != len(b) || computed[i].Directed != recorded[i].Directed {
+			return false
+		}
+		for j := range a {
+			if a[j].UserID != b[j].UserID {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // getUserDisplayName 获取用户显示名称
 func (s *HistoryService) getUserDisplayName(userData map[string]interface{}) string {
 	// 按优先级查找显示名称
@@ -142,54 +300,49 @@ func (s *HistoryService) GetHistoryByIDForAdmin(id uint) (*models.MatchResult, e
 	return s.GetHistoryByID(id)
 }
 
-// GetHistoryByIDAnonymous 匿名获取历史记录详情（隐藏敏感信息）
-func (s *HistoryService) GetHistoryByIDAnonymous(id uint, userIdentifier string) (*models.MatchResult, error) {
+// GetHistoryByIDAnonymous 参与者查看某次匹配中本人的配对：按参与者（PoolUser）ID 而不是显示名称查找，
+// 只返回本人所在的配对（交换礼物模式下为送礼和收礼两条），匹配对象的字段按可见性隐藏
+func (s *HistoryService) GetHistoryByIDAnonymous(id uint, userID uint) (*models.MatchResult, error) {
 	// 获取完整结果
 	fullResult, err := s.GetHistoryByID(id)
 	if err != nil {
 		return nil, err
 	}
 
-	// 创建匿名版本
-	anonymousResult := &models.MatchResult{
-		PoolName:   fullResult.PoolName,
-		TotalUsers: fullResult.TotalUsers,
-		Pairs:      make([]models.MatchPairResult, 0),
-		Timestamp:  fullResult.Timestamp,
-	}
-
-	// 只返回与指定用户相关的配对信息，且隐藏对方信息
+	// 只保留本人所在的配对
+	ownResult := *fullResult
+	ownResult.Pairs = []models.MatchPairResult{}
 	for _, pair := range fullResult.Pairs {
-		if pair.User1 == userIdentifier {
-			// 用户是第一个用户
-			anonymousPair := models.MatchPairResult{
-				Pair:      pair.Pair,
-				User1:     pair.User1,
-				User1Data: pair.User1Data,
-			}
-			if pair.User2 != "" {
-				anonymousPair.User2 = "您的匹配对象"
-				anonymousPair.User2Data = map[string]interface{}{
-					"status": "已匹配",
-				}
-			}
-			anonymousResult.Pairs = append(anonymousResult.Pairs, anonymousPair)
-		} else if pair.User2 == userIdentifier {
-			// 用户是第二个用户
-			anonymousPair := models.MatchPairResult{
-				Pair:  pair.Pair,
-				User1: "您的匹配对象",
-				User1Data: map[string]interface{}{
-					"status": "已匹配",
-				},
-				User2:     pair.User2,
-				User2Data: pair.User2Data,
-			}
-			anonymousResult.Pairs = append(anonymousResult.Pairs, anonymousPair)
+		if pairIncludes(pair, userID) {
+			ownResult.Pairs = append(ownResult.Pairs, pair)
 		}
 	}
 
-	return anonymousResult, nil
+	return NewVisibilityService(s.db).Redact(&ownResult, Viewer{UserID: userID})
+}
+
+// GetParticipantMatches 获取参与者在各轮匹配中本人的配对，按匹配时间从新到旧排列；
+// 修复匹配记录时退出的参与者不再出现在该记录中
+func (s *HistoryService) GetParticipantMatches(userID uint) ([]models.MatchResult, error) {
+	var recordIDs []uint
+	if err := s.db.Model(&models.MatchPair{}).
+		Where("user1_id = ? OR user2_id = ? OR id IN (?)", userID, userID,
+			s.db.Model(&models.MatchGroupMember{}).Select("pair_id").Where("user_id = ?", userID)).
+		Distinct().
+		Order("record_id DESC").
+		Pluck("record_id", &recordIDs).Error; err != nil {
+		return nil, err
+	}
+
+	results := make([]models.MatchResult, 0, len(recordIDs))
+	for _, recordID := range recordIDs {
+		result, err := s.GetHistoryByIDAnonymous(recordID, userID)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, *result)
+	}
+	return results, nil
 }
 
 // GetStatistics 获取统计信息（带缓存）
@@ -244,7 +397,12 @@ func (s *HistoryService) GetFullHistory() ([]models.HistoryRecord, error) {
 			MatchDate:   record.MatchedAt.Format("2006-01-02 15:04:05"),
 			TotalUsers:  record.TotalUsers,
 			PairsCount:  record.PairsCount,
+			GroupSize:   record.GroupSize,
 			HasLoneUser: record.HasLoneUser,
+			RepeatCount: record.RepeatCount,
+			Revision:    record.Revision,
+
+			RandomSource: record.RandomSource,
 		}
 	}
 
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"christmas-link-backend/cache"
+	"christmas-link-backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 匹配池生命周期：
+//   draft → open → closed → matching → matched → (open → … 下一轮) → archived
+// open 超过截止时间后自动视为 closed；matching、matched 只能由执行匹配进入，
+// 匹配失败时回到进入 matching 前的状态。
+
+// poolTransitions 可以通过状态变更接口执行的转换
+var poolTransitions = map[string][]string{
+	models.PoolStatusDraft:    {models.PoolStatusOpen, models.PoolStatusArchived},
+	models.PoolStatusOpen:     {models.PoolStatusClosed, models.PoolStatusArchived},
+	models.PoolStatusClosed:   {models.PoolStatusOpen, models.PoolStatusArchived},
+	models.PoolStatusMatching: {models.PoolStatusClosed}, // 匹配异常中断时由管理员恢复
+	models.PoolStatusMatched:  {models.PoolStatusOpen, models.PoolStatusClosed, models.PoolStatusArchived},
+	models.PoolStatusArchived: {},
+}
+
+// poolStatusTimestamps 进入各状态时记录时间的字段
+var poolStatusTimestamps = map[string]string{
+	models.PoolStatusOpen:     "opened_at",
+	models.PoolStatusClosed:   "closed_at",
+	models.PoolStatusMatching: "matching_started_at",
+	models.PoolStatusMatched:  "last_matched_at",
+	models.PoolStatusArchived: "archived_at",
+}
+
+// matchLockTTL 单次匹配的最长执行时间，超过后 matching 状态视为异常中断
+const matchLockTTL = 2 * time.Minute
+
+// errPoolStatusChanged 匹配池状态已被其他请求修改
+var errPoolStatusChanged = errors.New("匹配池状态已被其他请求修改，请刷新后重试")
+
+// LifecycleService 匹配池生命周期服务
+type LifecycleService struct {
+	db           *gorm.DB
+	cacheService *cache.CacheService
+}
+
+// NewLifecycleService 创建匹配池生命周期服务实例
+func NewLifecycleService(db *gorm.DB) *LifecycleService {
+	return &LifecycleService{
+		db:           db,
+		cacheService: cache.NewCacheService(),
+	}
+}
+
+// TransitionPool 将匹配池变更为目标状态
+func (s *LifecycleService) TransitionPool(poolID uint, req *models.TransitionPoolRequest) (*models.PoolResponse, error) {
+	var pool models.MatchPool
+	if err := s.db.First(&pool, poolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+
+	from := pool.CurrentStatus()
+	to := req.Status
+	if _, ok := poolTransitions[to]; !ok {
+		return nil, fmt.Errorf("不支持的匹配池状态: %s", to)
+	}
+	if to == models.PoolStatusMatching || to == models.PoolStatusMatched {
+		return nil, fmt.Errorf("匹配中和已匹配状态只能通过执行匹配进入")
+	}
+	if !canTransition(from, to) {
+		return nil, fmt.Errorf("匹配池不能从 %s 变更为 %s", from, to)
+	}
+	if to == models.PoolStatusOpen && pool.IsExpired() {
+		return nil, fmt.Errorf("匹配池已超过截止时间，请先延长截止时间再开放报名")
+	}
+	if from == models.PoolStatusMatching && !matchingStale(&pool) {
+		return nil, fmt.Errorf("匹配正在进行中，请稍后再试")
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := updatePoolStatus(tx, &pool, to); err != nil {
+			return err
+		}
+
+		// 归档后不再自动匹配
+		if to == models.PoolStatusArchived {
+			return tx.Where("pool_id = ? AND status = ?", pool.ID, models.JobStatusPending).
+				Delete(&models.ScheduledMatchJob{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 清除相关缓存
+	s.cacheService.Delete(cache.CacheKeyPools)
+	s.cacheService.Delete(cache.GeneratePoolKey(int(pool.ID)))
+
+	publishStatusEvent(pool.ID, from, to)
+
+	log.Printf("🔁 匹配池 %d 状态变更: %s → %s", pool.ID, from, to)
+	return NewPoolService(s.db).GetPoolByID(pool.ID)
+}
+
+// canTransition 检查状态变更接口能否执行该转换
+func canTransition(from, to string) bool {
+	for _, next := range poolTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// updatePoolStatus 以版本号为条件保存状态变更并记录进入时间；
+// 因超过截止时间自动截止的匹配池同时补记截止时间
+func updatePoolStatus(tx *gorm.DB, pool *models.MatchPool, to string) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":  to,
+		"version": gorm.Expr("version + 1"),
+	}
+	if column, ok := poolStatusTimestamps[to]; ok {
+		updates[column] = now
+	}
+	if pool.ClosedAt == nil && pool.Status == models.PoolStatusOpen && pool.CurrentStatus() == models.PoolStatusClosed {
+		updates["closed_at"] = pool.ValidUntil
+	}
+
+	result := tx.Model(&models.MatchPool{}).
+		Where("id = ? AND version = ?", pool.ID, pool.Version).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errPoolStatusChanged
+	}
+
+	pool.Status = to
+	pool.Version++
+	return nil
+}
+
+// checkJoinable 检查匹配池当前是否接受报名
+func checkJoinable(pool *models.MatchPool) error {
+	switch pool.CurrentStatus() {
+	case models.PoolStatusOpen:
+		return nil
+	case models.PoolStatusDraft:
+		return fmt.Errorf("匹配池尚未开放报名")
+	case models.PoolStatusClosed:
+		return fmt.Errorf("匹配池已截止报名")
+	case models.PoolStatusMatching:
+		return fmt.Errorf("匹配池正在匹配中，暂不能加入")
+	case models.PoolStatusMatched:
+		return fmt.Errorf("匹配池已完成匹配，暂不能加入")
+	default:
+		return fmt.Errorf("匹配池已归档")
+	}
+}
+
+// lockOpenPool 在事务中以开放状态为条件写匹配池，与开始匹配的状态变更互斥：
+// 匹配开始后到达的报名、修改请求会看到状态已不是 open
+func lockOpenPool(tx *gorm.DB, poolID uint) error {
+	result := tx.Model(&models.MatchPool{}).
+		Where("id = ? AND status = ?", poolID, models.PoolStatusOpen).
+		Update("updated_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return errPoolStatusChanged
+	}
+	return nil
+}
+
+// checkMatchable 检查匹配池当前能否开始匹配：已截止或仍在报名中的匹配池可以匹配，
+// 已匹配的匹配池需要等待冷却时间结束，异常中断的匹配可以重新开始
+func checkMatchable(pool *models.MatchPool) error {
+	switch pool.CurrentStatus() {
+	case models.PoolStatusOpen, models.PoolStatusClosed:
+		return nil
+	case models.PoolStatusMatched:
+		if pool.LastMatchedAt != nil {
+			cooldownDuration := time.Duration(pool.CooldownTime) * time.Second
+			timeElapsed := time.Since(*pool.LastMatchedAt)
+			if timeElapsed < cooldownDuration {
+				remainingTime := cooldownDuration - timeElapsed
+				return fmt.Errorf("匹配池正在冷却中，还需等待 %.1f 秒", remainingTime.Seconds())
+			}
+		}
+		return nil
+	case models.PoolStatusMatching:
+		if !matchingStale(pool) {
+			return errConcurrentMatch
+		}
+		log.Printf("🔧 匹配池 %s 的上一次匹配未正常结束，重新开始匹配", pool.Name)
+		return nil
+	case models.PoolStatusDraft:
+		return fmt.Errorf("匹配池尚未开放，不能匹配")
+	default:
+		return fmt.Errorf("匹配池已归档，不能匹配")
+	}
+}
+
+// matchingStale 匹配中的状态是否已超过最长执行时间
+func matchingStale(pool *models.MatchPool) bool {
+	return pool.MatchingStartedAt == nil || time.Since(*pool.MatchingStartedAt) >= matchLockTTL
+}
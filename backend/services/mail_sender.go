@@ -0,0 +1,105 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// MailMessage 待发送的纯文本邮件
+type MailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender 邮件发送方式
+type MailSender interface {
+	// Name 发送方式名称，用于日志
+	Name() string
+	// Send 发送一封邮件
+	Send(msg *MailMessage) error
+}
+
+// NewMailSender 按环境变量创建邮件发送方式：设置了 SMTP_HOST 时通过 SMTP 发送
+// （SMTP_PORT 默认 25，SMTP_USERNAME/SMTP_PASSWORD 为空时不认证，发件人为 SMTP_FROM），
+// 否则只把邮件内容写入日志，便于本地开发
+func NewMailSender() MailSender {
+	host := getEnvOrDefault("SMTP_HOST", "")
+	if host == "" {
+		return &LogSender{}
+	}
+	return &SMTPSender{
+		Addr:     net.JoinHostPort(host, getEnvOrDefault("SMTP_PORT", "25")),
+		Host:     host,
+		Username: getEnvOrDefault("SMTP_USERNAME", ""),
+		Password: getEnvOrDefault("SMTP_PASSWORD", ""),
+		From:     getEnvOrDefault("SMTP_FROM", "christmas-link@localhost"),
+	}
+}
+
+// SMTPSender 通过 SMTP 服务器发送邮件，服务器支持时自动使用 STARTTLS
+type SMTPSender struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+// Name 发送方式名称
+func (s *SMTPSender) Name() string {
+	return "smtp"
+}
+
+// Send 发送一封邮件
+func (s *SMTPSender) Send(msg *MailMessage) error {
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	if err := smtp.SendMail(s.Addr, auth, s.From, []string{msg.To}, buildMailData(s.From, msg)); err != nil {
+		return fmt.Errorf("SMTP 发送失败: %v", err)
+	}
+	return nil
+}
+
+// buildMailData 生成邮件原文：UTF-8 纯文本，正文使用 base64 编码
+func buildMailData(from string, msg *MailMessage) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+
+	encoded := base64.StdEncoding.EncodeToString([]byte(msg.Body))
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return buf.Bytes()
+}
+
+// LogSender 未配置 SMTP 时使用，只把邮件内容写入日志
+type LogSender struct{}
+
+// Name 发送方式名称
+func (s *LogSender) Name() string {
+	return "log"
+}
+
+// Send 把邮件内容写入日志
+func (s *LogSender) Send(msg *MailMessage) error {
+	log.Printf("📧 未配置 SMTP，邮件未实际发送: 收件人 %s，主题 %s\n%s", msg.To, msg.Subject, strings.TrimSpace(msg.Body))
+	return nil
+}
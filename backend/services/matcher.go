@@ -0,0 +1,641 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSearchSteps 约束搜索的最大步数，防止病态输入导致搜索时间过长
+const maxSearchSteps = 200000
+
+var (
+	errNoValidMatching  = errors.New("不存在满足所有约束的匹配方案")
+	errMatchSearchLimit = errors.New("在限定步数内未找到满足约束的匹配方案")
+)
+
+// matchGraph 用户之间的可配对关系
+type matchGraph struct {
+	users       []models.PoolUser
+	names       []string
+	constraints []models.PoolConstraint
+	blocked     [][][]int // blocked[i][j] 为禁止用户 i 与 j 配对的约束下标
+	repeat      [][]bool  // repeat[i][j] 表示用户 i 与 j 在最近几轮中已配对过
+}
+
+// newMatchGraph 根据排除约束和历史配对构建可配对关系，history 可以为 nil
+func newMatchGraph(users []models.PoolUser, constraints []models.PoolConstraint, history *repeatHistory, displayName func(map[string]interface{}) string) *matchGraph {
+	n := len(users)
+	g := &matchGraph{
+		users:       users,
+		names:       make([]string, n),
+		constraints: constraints,
+		blocked:     make([][][]int, n),
+		repeat:      make([][]bool, n),
+	}
+
+	indexByID := make(map[uint]int, n)
+	for i, user := range users {
+		indexByID[user.ID] = i
+		g.names[i] = displayName(user.ParsedUserData)
+		g.blocked[i] = make([][]int, n)
+		g.repeat[i] = make([]bool, n)
+	}
+
+	if history != nil {
+		for i := 0; i < n; i++ {
+			for j := i + 1; j < n; j++ {
+				if history.seen(&users[i], &users[j]) {
+					g.repeat[i][j] = true
+					g.repeat[j][i] = true
+				}
+			}
+		}
+	}
+
+	block := func(i, j, c int) {
+		g.blocked[i][j] = append(g.blocked[i][j], c)
+		g.blocked[j][i] = append(g.blocked[j][i], c)
+	}
+
+	for c, constraint := range constraints {
+		switch constraint.Type {
+		case models.ConstraintExcludePair:
+			if constraint.User1ID == nil || constraint.User2ID == nil {
+				continue
+			}
+			i, ok1 := indexByID[*constraint.User1ID]
+			j, ok2 := indexByID[*constraint.User2ID]
+			if ok1 && ok2 && i != j {
+				block(i, j, c)
+			}
+
+		case models.ConstraintExcludeSameField:
+			values := make([]string, n)
+			for i := range users {
+				values[i] = fieldValueKey(users[i].ParsedUserData, constraint.FieldName)
+			}
+			for i := 0; i < n; i++ {
+				for j := i + 1; j < n; j++ {
+					if values[i] != "" && values[i] == values[j] {
+						block(i, j, c)
+					}
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+// fieldValueKey 获取字段值的归一化表示，空值返回空字符串
+func fieldValueKey(userData map[string]interface{}, fieldName string) string {
+	value, ok := userData[fieldName]
+	if !ok || value == nil {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(fmt.Sprint(value)))
+}
+
+// allowed 判断用户 i 与 j 能否配对，ignore 为忽略的约束下标（-1 表示不忽略）
+func (g *matchGraph) allowed(i, j, ignore int) bool {
+	if i == j {
+		return false
+	}
+	for _, c := range g.blocked[i][j] {
+		if c != ignore {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPlan 匹配方案的形态：交换礼物模式为单一有向环，否则为指定人数的分组
+type matchPlan struct {
+	mode   string
+	sizes  []int       // 各分组人数，交换礼物模式下为空
+	scorer *pairScorer // 打分匹配模式下的配对打分器
+}
+
+// newMatchPlan 根据匹配池设置和用户数量生成匹配方案形态
+func newMatchPlan(pool *models.MatchPool, userCount int) *matchPlan {
+	if pool.MatchMode == models.MatchModeGift {
+		return &matchPlan{mode: models.MatchModeGift}
+	}
+	if pool.MatchMode == models.MatchModeScore {
+		return &matchPlan{
+			mode:   models.MatchModeScore,
+			sizes:  planGroupSizes(userCount, 2, models.LeftoverSmaller),
+			scorer: newPairScorer(pool.Fields),
+		}
+	}
+	return &matchPlan{
+		mode:  models.MatchModePair,
+		sizes: planGroupSizes(userCount, pool.GroupSize, pool.LeftoverPolicy),
+	}
+}
+
+// planGroupSizes 计算各分组人数：剩余用户按策略单独成组或依次并入已有分组
+func planGroupSizes(userCount, groupSize int, policy string) []int {
+	if groupSize < 2 {
+		groupSize = 2
+	}
+
+	full := userCount / groupSize
+	rest := userCount % groupSize
+	if full == 0 {
+		return []int{userCount}
+	}
+
+	sizes := make([]int, full)
+	for i := range sizes {
+		sizes[i] = groupSize
+	}
+	if rest == 0 {
+		return sizes
+	}
+
+	if policy == models.LeftoverMerge {
+		for j := 0; j < rest; j++ {
+			sizes[j%full]++
+		}
+		return sizes
+	}
+	return append(sizes, rest)
+}
+
+// singles 方案中单人分组（轮空）的数量
+func (p *matchPlan) singles() int {
+	count := 0
+	for _, size := range p.sizes {
+		if size == 1 {
+			count++
+		}
+	}
+	return count
+}
+
+// chunk 不考虑约束，按顺序将用户切分为各分组
+func (p *matchPlan) chunk(users []models.PoolUser) [][]models.PoolUser {
+	if p.mode == models.MatchModeGift {
+		return [][]models.PoolUser{users}
+	}
+
+	groups := make([][]models.PoolUser, 0, len(p.sizes))
+	offset := 0
+	for _, size := range p.sizes {
+		groups = append(groups, users[offset:offset+size])
+		offset += size
+	}
+	return groups
+}
+
+// matchSearch 在可配对关系上进行回溯搜索
+type matchSearch struct {
+	g          *matchGraph
+	ignore     int // 忽略的约束下标，-1 表示不忽略
+	maxRepeats int // 允许的重复配对数量上限，-1 表示不限制
+	repeats    int
+	steps      int
+}
+
+// usable 判断当前搜索状态下用户 i 与 j 能否配对
+func (m *matchSearch) usable(i, j int) bool {
+	if !m.g.allowed(i, j, m.ignore) {
+		return false
+	}
+	return m.maxRepeats < 0 || !m.g.repeat[i][j] || m.repeats < m.maxRepeats
+}
+
+// fits 判断用户 j 能否加入分组：与组内每名成员都允许配对，且重复次数不超过上限
+func (m *matchSearch) fits(group []int, j int) bool {
+	added := 0
+	for _, member := range group {
+		if !m.g.allowed(member, j, m.ignore) {
+			return false
+		}
+		if m.g.repeat[member][j] {
+			added++
+		}
+	}
+	return m.maxRepeats < 0 || m.repeats+added <= m.maxRepeats
+}
+
+// join 将用户 j 加入分组并累计重复次数
+func (m *matchSearch) join(group []int, j int) {
+	for _, member := range group {
+		if m.g.repeat[member][j] {
+			m.repeats++
+		}
+	}
+}
+
+// leave 将用户 j 移出分组
+func (m *matchSearch) leave(group []int, j int) {
+	for _, member := range group {
+		if m.g.repeat[member][j] {
+			m.repeats--
+		}
+	}
+}
+
+// tick 累计搜索步数，超出上限时返回 false
+func (m *matchSearch) tick() bool {
+	m.steps++
+	return m.steps <= maxSearchSteps
+}
+
+// findGroups 搜索满足约束的分组方案，sizes 为各分组人数
+func (m *matchSearch) findGroups(sizes []int) ([][]int, error) {
+	n := len(m.g.users)
+	used := make([]bool, n)
+	groups := make([][]int, 0, len(sizes))
+
+	// 剩余待填充的分组人数，按人数从大到小尝试
+	remaining := make(map[int]int)
+	var distinct []int
+	for _, size := range sizes {
+		if remaining[size] == 0 {
+			distinct = append(distinct, size)
+		}
+		remaining[size]++
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(distinct)))
+
+	var dfs func() bool
+	var fill func(group []int, size, from int) bool
+
+	dfs = func() bool {
+		if !m.tick() {
+			return false
+		}
+
+		// 优先处理可选对象最少的用户，尽早发现无解分支
+		i, best := -1, n+1
+		for u := 0; u < n; u++ {
+			if used[u] {
+				continue
+			}
+			options := 0
+			for v := 0; v < n; v++ {
+				if !used[v] && m.usable(u, v) {
+					options++
+				}
+			}
+			if options < best {
+				i, best = u, options
+			}
+		}
+		if i == -1 {
+			return true
+		}
+
+		used[i] = true
+		for _, size := range distinct {
+			if remaining[size] == 0 || best < size-1 {
+				continue
+			}
+			remaining[size]--
+			if fill([]int{i}, size, 0) {
+				return true
+			}
+			remaining[size]++
+		}
+		used[i] = false
+		return false
+	}
+
+	fill = func(group []int, size, from int) bool {
+		if len(group) == size {
+			groups = append(groups, append([]int(nil), group...))
+			if dfs() {
+				return true
+			}
+			groups = groups[:len(groups)-1]
+			return false
+		}
+		if !m.tick() {
+			return false
+		}
+
+		// 组内成员按下标递增选取，避免重复搜索同一分组的不同排列
+		for j := from; j < n; j++ {
+			if used[j] || !m.fits(group, j) {
+				continue
+			}
+			used[j] = true
+			m.join(group, j)
+			if fill(append(group, j), size, j+1) {
+				return true
+			}
+			m.leave(group, j)
+			used[j] = false
+		}
+		return false
+	}
+
+	if !dfs() {
+		return nil, m.failure()
+	}
+	return groups, nil
+}
+
+// findGiftCycle 搜索满足约束的单一送礼环，返回环上用户下标的顺序
+func (m *matchSearch) findGiftCycle() ([]int, error) {
+	n := len(m.g.users)
+	visited := make([]bool, n)
+	order := make([]int, 1, n)
+	visited[0] = true
+
+	var dfs func() bool
+	dfs = func() bool {
+		if !m.tick() {
+			return false
+		}
+
+		last := order[len(order)-1]
+		if len(order) == n {
+			return m.usable(last, order[0])
+		}
+
+		for next := 0; next < n; next++ {
+			if visited[next] || !m.usable(last, next) {
+				continue
+			}
+			visited[next] = true
+			m.join([]int{last}, next)
+			order = append(order, next)
+			if dfs() {
+				return true
+			}
+			order = order[:len(order)-1]
+			m.leave([]int{last}, next)
+			visited[next] = false
+		}
+		return false
+	}
+
+	if !dfs() {
+		return nil, m.failure()
+	}
+	return order, nil
+}
+
+// failure 返回搜索失败的原因
+func (m *matchSearch) failure() error {
+	if m.steps > maxSearchSteps {
+		return errMatchSearchLimit
+	}
+	return errNoValidMatching
+}
+
+// search 按匹配方案执行搜索，交换礼物模式下返回只包含整个环的单一分组
+func (m *matchSearch) search(plan *matchPlan) ([][]int, error) {
+	if plan.mode == models.MatchModeGift {
+		order, err := m.findGiftCycle()
+		if err != nil {
+			return nil, err
+		}
+		return [][]int{order}, nil
+	}
+	return m.findGroups(plan.sizes)
+}
+
+// arrangeUsers 在打乱后的用户上搜索满足所有排除约束的分组，并在提供历史配对时尽量避免重复配对。
+// 返回各分组（交换礼物模式下为整个送礼环）和无法避免的重复配对数量，
+// 无解时返回说明具体是哪些约束导致无法匹配的错误
+func arrangeUsers(shuffled []models.PoolUser, plan *matchPlan, constraints []models.PoolConstraint, history *repeatHistory, displayName func(map[string]interface{}) string) ([][]models.PoolUser, int, error) {
+	g := newMatchGraph(shuffled, constraints, history, displayName)
+
+	// 先只考虑排除约束，得到一个可行方案
+	search := &matchSearch{g: g, ignore: -1, maxRepeats: -1}
+	groups, err := search.search(plan)
+	if err != nil {
+		if err == errMatchSearchLimit {
+			return nil, 0, fmt.Errorf("%v，请减少约束后重试", err)
+		}
+		return nil, 0, fmt.Errorf("%v：%s", err, g.explain(plan))
+	}
+
+	// 逐步放宽允许的重复次数，找到重复最少的方案
+	repeats := g.countRepeats(groups, plan)
+	for limit := 0; limit < repeats; limit++ {
+		search := &matchSearch{g: g, ignore: -1, maxRepeats: limit}
+		if better, err := search.search(plan); err == nil {
+			groups = better
+			repeats = g.countRepeats(groups, plan)
+			break
+		}
+	}
+
+	arranged := make([][]models.PoolUser, len(groups))
+	for i, group := range groups {
+		arranged[i] = make([]models.PoolUser, len(group))
+		for j, idx := range group {
+			arranged[i][j] = shuffled[idx]
+		}
+	}
+	return arranged, repeats, nil
+}
+
+// countRepeats 统计方案中重复配对的数量
+func (g *matchGraph) countRepeats(groups [][]int, plan *matchPlan) int {
+	count := 0
+	if plan.mode == models.MatchModeGift {
+		order := groups[0]
+		for i := range order {
+			if g.repeat[order[i]][order[(i+1)%len(order)]] {
+				count++
+			}
+		}
+		return count
+	}
+
+	for _, group := range groups {
+		for a := 0; a < len(group); a++ {
+			for b := a + 1; b < len(group); b++ {
+				if g.repeat[group[a]][group[b]] {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// explain 分析无解原因，指出导致无法匹配的约束
+func (g *matchGraph) explain(plan *matchPlan) string {
+	n := len(g.users)
+
+	// 每人至少需要的可配对对象数：送礼模式下需要一个送礼对象和一个收礼对象
+	need := 1
+	if plan.mode == models.MatchModeGift && n > 2 {
+		need = 2
+	}
+
+	var isolated []string
+	for i := 0; i < n; i++ {
+		options := 0
+		for j := 0; j < n; j++ {
+			if g.allowed(i, j, -1) {
+				options++
+			}
+		}
+		if options < need {
+			isolated = append(isolated, fmt.Sprintf("用户「%s」(ID %d) 受%s限制，可配对对象不足", g.names[i], g.users[i].ID, g.describeUserConstraints(i)))
+		}
+	}
+	if len(isolated) > 0 && (plan.mode == models.MatchModeGift || len(isolated) > plan.singles()) {
+		return strings.Join(isolated, "；")
+	}
+
+	// 逐一尝试放宽单条约束，找出导致无解的关键约束
+	var culprits []string
+	for c := range g.constraints {
+		search := &matchSearch{g: g, ignore: c, maxRepeats: -1}
+		if _, err := search.search(plan); err == nil {
+			culprits = append(culprits, g.describeConstraint(c))
+		}
+	}
+	if len(culprits) > 0 {
+		return "移除以下任一约束即可完成匹配：" + strings.Join(culprits, "；")
+	}
+
+	all := make([]string, len(g.constraints))
+	for c := range g.constraints {
+		all[c] = g.describeConstraint(c)
+	}
+	return "多条约束共同导致无解：" + strings.Join(all, "；")
+}
+
+// describeUserConstraints 描述涉及用户 i 的约束
+func (g *matchGraph) describeUserConstraints(i int) string {
+	seen := make(map[int]bool)
+	var parts []string
+	for j := range g.users {
+		for _, c := range g.blocked[i][j] {
+			if !seen[c] {
+				seen[c] = true
+				parts = append(parts, g.describeConstraint(c))
+			}
+		}
+	}
+	return strings.Join(parts, "、")
+}
+
+// describeConstraint 生成约束的可读描述
+func (g *matchGraph) describeConstraint(c int) string {
+	constraint := g.constraints[c]
+	switch constraint.Type {
+	case models.ConstraintExcludePair:
+		name := func(id *uint) string {
+			for i, user := range g.users {
+				if id != nil && user.ID == *id {
+					return g.names[i]
+				}
+			}
+			return "未知用户"
+		}
+		return fmt.Sprintf("约束#%d（%s 与 %s 不能配对）", constraint.ID, name(constraint.User1ID), name(constraint.User2ID))
+	case models.ConstraintExcludeSameField:
+		return fmt.Sprintf("约束#%d（字段 %s 相同者不能配对）", constraint.ID, constraint.FieldName)
+	}
+	return fmt.Sprintf("约束#%d", constraint.ID)
+}
+
+// repeatHistory 最近几轮匹配中出现过的配对，同时按用户ID和身份字段（如邮箱）识别
+type repeatHistory struct {
+	identityField string
+	userPairs     map[[2]uint]bool
+	identityPairs map[[2]string]bool
+}
+
+// newRepeatHistory 根据历史匹配记录构建配对历史，identityField 为空时只按用户ID识别
+func newRepeatHistory(records []models.MatchRecord, identityField string) *repeatHistory {
+	h := &repeatHistory{
+		identityField: identityField,
+		userPairs:     make(map[[2]uint]bool),
+		identityPairs: make(map[[2]string]bool),
+	}
+
+	for _, record := range records {
+		for _, pair := range record.Pairs {
+			members := pairMembers(pair)
+			for a := 0; a < len(members); a++ {
+				for b := a + 1; b < len(members); b++ {
+					h.add(members[a], members[b])
+				}
+			}
+		}
+	}
+
+	return h
+}
+
+// add 记录一次历史配对
+func (h *repeatHistory) add(a, b models.MatchGroupMember) {
+	h.userPairs[userPairKey(a.UserID, b.UserID)] = true
+
+	if h.identityField != "" {
+		id1 := fieldValueKey(a.ParsedUserData, h.identityField)
+		id2 := fieldValueKey(b.ParsedUserData, h.identityField)
+		if id1 != "" && id2 != "" {
+			h.identityPairs[identityPairKey(id1, id2)] = true
+		}
+	}
+}
+
+// seen 判断两名用户是否在历史中配对过
+func (h *repeatHistory) seen(a, b *models.PoolUser) bool {
+	if h.userPairs[userPairKey(a.ID, b.ID)] {
+		return true
+	}
+	if h.identityField == "" {
+		return false
+	}
+
+	id1 := fieldValueKey(a.ParsedUserData, h.identityField)
+	id2 := fieldValueKey(b.ParsedUserData, h.identityField)
+	return id1 != "" && id2 != "" && h.identityPairs[identityPairKey(id1, id2)]
+}
+
+// userPairKey 生成与顺序无关的用户ID配对键
+func userPairKey(a, b uint) [2]uint {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]uint{a, b}
+}
+
+// identityPairKey 生成与顺序无关的身份字段配对键
+func identityPairKey(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// pairMembers 获取分组的全部成员，兼容没有成员记录的旧数据
+func pairMembers(pair models.MatchPair) []models.MatchGroupMember {
+	if len(pair.Members) > 0 {
+		return pair.Members
+	}
+
+	members := []models.MatchGroupMember{{
+		UserID:         pair.User1ID,
+		Position:       1,
+		UserData:       pair.User1Data,
+		ParsedUserData: pair.ParsedUser1Data,
+	}}
+	if pair.User2ID != nil {
+		members = append(members, models.MatchGroupMember{
+			UserID:         *pair.User2ID,
+			Position:       2,
+			UserData:       pair.User2Data,
+			ParsedUserData: pair.ParsedUser2Data,
+		})
+	}
+	return members
+}
@@ -0,0 +1,528 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testUsers 构造 n 名用户，ID 从 1 开始，data 返回第 i 名用户的填写内容
+func testUsers(n int, data func(i int) map[string]interface{}) []models.PoolUser {
+	users := make([]models.PoolUser, n)
+	for i := range users {
+		userData := map[string]interface{}{"name": fmt.Sprintf("用户%d", i+1)}
+		if data != nil {
+			for key, value := range data(i) {
+				userData[key] = value
+			}
+		}
+		raw, _ := json.Marshal(userData)
+		users[i] = models.PoolUser{ID: uint(i + 1), UserData: raw, ParsedUserData: userData}
+	}
+	return users
+}
+
+// excludePair 构造两名用户不能配对的约束
+func excludePair(id uint, a, b uint) models.PoolConstraint {
+	return models.PoolConstraint{ID: id, Type: models.ConstraintExcludePair, User1ID: &a, User2ID: &b}
+}
+
+// blockedPair 判断两名用户是否被排除约束禁止配对
+func blockedPair(users []models.PoolUser, constraints []models.PoolConstraint, a, b uint) bool {
+	data := make(map[uint]map[string]interface{}, len(users))
+	for _, user := range users {
+		data[user.ID] = user.ParsedUserData
+	}
+	for _, c := range constraints {
+		switch c.Type {
+		case models.ConstraintExcludePair:
+			if userPairKey(*c.User1ID, *c.User2ID) == userPairKey(a, b) {
+				return true
+			}
+		case models.ConstraintExcludeSameField:
+			va, vb := fieldValueKey(data[a], c.FieldName), fieldValueKey(data[b], c.FieldName)
+			if va != "" && va == vb {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkGiftCycle 检查交换礼物的配对构成覆盖所有用户的单一有向环，且没有人送给自己或被约束禁止的对象
+func checkGiftCycle(t *testing.T, users []models.PoolUser, constraints []models.PoolConstraint, pairs []models.MatchPair) {
+	t.Helper()
+	if len(pairs) != len(users) {
+		t.Fatalf("%d 名用户生成了 %d 条送礼记录", len(users), len(pairs))
+	}
+
+	receiverOf := make(map[uint]uint, len(users))
+	received := make(map[uint]bool, len(users))
+	for _, pair := range pairs {
+		if !pair.Directed || pair.User2ID == nil {
+			t.Fatalf("送礼记录 %d 不是有向配对", pair.PairNumber)
+		}
+		giver, receiver := pair.User1ID, *pair.User2ID
+		if giver == receiver {
+			t.Errorf("用户 %d 送礼物给自己", giver)
+		}
+		if _, ok := receiverOf[giver]; ok {
+			t.Errorf("用户 %d 送出了多份礼物", giver)
+		}
+		if received[receiver] {
+			t.Errorf("用户 %d 收到了多份礼物", receiver)
+		}
+		if blockedPair(users, constraints, giver, receiver) {
+			t.Errorf("用户 %d 与 %d 被约束禁止配对", giver, receiver)
+		}
+		receiverOf[giver] = receiver
+		received[receiver] = true
+	}
+
+	// 从任一用户出发沿送礼方向走 n 步，应恰好经过每名用户一次并回到起点
+	start := users[0].ID
+	seen := map[uint]bool{}
+	current := start
+	for step := 0; step < len(users); step++ {
+		if seen[current] {
+			t.Fatalf("送礼关系在 %d 步内出现了较小的环", step)
+		}
+		seen[current] = true
+		next, ok := receiverOf[current]
+		if !ok {
+			t.Fatalf("用户 %d 没有送礼对象", current)
+		}
+		current = next
+	}
+	if current != start || len(seen) != len(users) {
+		t.Errorf("送礼关系不是覆盖全部 %d 名用户的单一环", len(users))
+	}
+}
+
+// TestGiftCycle 交换礼物模式生成覆盖所有用户的单一有向环
+func TestGiftCycle(t *testing.T) {
+	plan := &matchPlan{mode: models.MatchModeGift}
+	for n := 2; n <= 9; n++ {
+		users := testUsers(n, nil)
+		pairs, repeats, err := matchShuffled(users, plan, nil, nil, userDisplayName)
+		if err != nil {
+			t.Fatalf("n=%d: 匹配失败: %v", n, err)
+		}
+		if repeats != 0 {
+			t.Errorf("n=%d: 没有历史配对时重复数应为 0，实际为 %d", n, repeats)
+		}
+		checkGiftCycle(t, users, nil, pairs)
+	}
+}
+
+// TestGiftCycleWithConstraints 有排除约束时，送礼环仍覆盖所有用户且不包含被禁止的配对
+func TestGiftCycleWithConstraints(t *testing.T) {
+	plan := &matchPlan{mode: models.MatchModeGift}
+	rng := rand.New(rand.NewSource(2024))
+
+	for trial := 0; trial < 50; trial++ {
+		n := 4 + rng.Intn(6)
+		users := testUsers(n, func(i int) map[string]interface{} {
+			return map[string]interface{}{"team": fmt.Sprintf("T%d", i%3)}
+		})
+		rng.Shuffle(len(users), func(i, j int) { users[i], users[j] = users[j], users[i] })
+
+		constraints := []models.PoolConstraint{excludePair(1, 1, 2)}
+		if n >= 6 {
+			// 三个队伍人数相近时，同队不能互送仍然有解
+			constraints = append(constraints, models.PoolConstraint{
+				ID: 2, Type: models.ConstraintExcludeSameField, FieldName: "team",
+			})
+		}
+
+		pairs, _, err := matchShuffled(users, plan, constraints, nil, userDisplayName)
+		if err != nil {
+			t.Fatalf("n=%d: 匹配失败: %v", n, err)
+		}
+		checkGiftCycle(t, users, constraints, pairs)
+	}
+}
+
+// pairPlan 两两/分组模式的匹配方案
+func pairPlan(n, groupSize int, policy string) *matchPlan {
+	return &matchPlan{mode: models.MatchModePair, sizes: planGroupSizes(n, groupSize, policy)}
+}
+
+// canPair 穷举判断 n 名用户能否在不违反约束的前提下两两配对，奇数时恰好一人轮空
+func canPair(users []models.PoolUser, constraints []models.PoolConstraint) bool {
+	used := make([]bool, len(users))
+	var try func(singles int) bool
+	try = func(singles int) bool {
+		i := 0
+		for i < len(users) && used[i] {
+			i++
+		}
+		if i == len(users) {
+			return true
+		}
+		used[i] = true
+		defer func() { used[i] = false }()
+		if singles > 0 && try(singles-1) {
+			return true
+		}
+		for j := i + 1; j < len(users); j++ {
+			if used[j] || blockedPair(users, constraints, users[i].ID, users[j].ID) {
+				continue
+			}
+			used[j] = true
+			ok := try(singles)
+			used[j] = false
+			if ok {
+				return true
+			}
+		}
+		return false
+	}
+	return try(len(users) % 2)
+}
+
+// TestConstraintsUnsatisfiableExplained 所有人同队且同队不能配对时无解，错误信息指出该约束
+func TestConstraintsUnsatisfiableExplained(t *testing.T) {
+	users := testUsers(4, func(i int) map[string]interface{} {
+		return map[string]interface{}{"team": "红队"}
+	})
+	constraints := []models.PoolConstraint{
+		{ID: 7, Type: models.ConstraintExcludeSameField, FieldName: "team"},
+	}
+
+	_, _, err := matchShuffled(users, pairPlan(4, 2, models.LeftoverSmaller), constraints, nil, userDisplayName)
+	if err == nil {
+		t.Fatal("所有人同队时不应匹配成功")
+	}
+	for _, want := range []string{errNoValidMatching.Error(), "约束#7", "字段 team 相同者不能配对", "用户1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息应包含 %q: %v", want, err)
+		}
+	}
+}
+
+// TestConstraintsCulpritExplained 每人都有可配对对象但整体无解时，列出移除后即可匹配的约束
+func TestConstraintsCulpritExplained(t *testing.T) {
+	// 用户1只能与用户4配对，剩下的用户2和用户3又不能配对
+	users := testUsers(4, nil)
+	constraints := []models.PoolConstraint{
+		excludePair(11, 1, 2),
+		excludePair(12, 1, 3),
+		excludePair(13, 2, 3),
+	}
+
+	_, _, err := matchShuffled(users, pairPlan(4, 2, models.LeftoverSmaller), constraints, nil, userDisplayName)
+	if err == nil {
+		t.Fatal("约束无解时不应匹配成功")
+	}
+	if !strings.Contains(err.Error(), "移除以下任一约束即可完成匹配") {
+		t.Errorf("错误信息应列出关键约束: %v", err)
+	}
+	for _, want := range []string{"约束#11", "约束#12", "约束#13"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("错误信息应包含 %q: %v", want, err)
+		}
+	}
+}
+
+// TestConstraintsNeverPairExcluded 随机约束下，有解时结果不包含被禁止的配对，无解时穷举也确认无解
+func TestConstraintsNeverPairExcluded(t *testing.T) {
+	rng := rand.New(rand.NewSource(1225))
+	solved := 0
+	for trial := 0; trial < 200; trial++ {
+		n := 4 + rng.Intn(7)
+		users := testUsers(n, func(i int) map[string]interface{} {
+			return map[string]interface{}{"team": fmt.Sprintf("T%d", rng.Intn(4))}
+		})
+
+		var constraints []models.PoolConstraint
+		count := rng.Intn(2 * n)
+		for c := 0; c < count; c++ {
+			a, b := uint(1+rng.Intn(n)), uint(1+rng.Intn(n))
+			if a != b {
+				constraints = append(constraints, excludePair(uint(c+1), a, b))
+			}
+		}
+		if rng.Intn(2) == 0 {
+			constraints = append(constraints, models.PoolConstraint{
+				ID: 100, Type: models.ConstraintExcludeSameField, FieldName: "team",
+			})
+		}
+
+		pairs, _, err := matchShuffled(users, pairPlan(n, 2, models.LeftoverSmaller), constraints, nil, userDisplayName)
+		feasible := canPair(users, constraints)
+		if err != nil {
+			if feasible {
+				t.Fatalf("trial %d: 存在满足约束的方案，但匹配失败: %v", trial, err)
+			}
+			continue
+		}
+		if !feasible {
+			t.Fatalf("trial %d: 穷举确认无解，但匹配成功", trial)
+		}
+
+		solved++
+		seen := make(map[uint]int)
+		for _, pair := range pairs {
+			members := pairMembers(pair)
+			for a := range members {
+				seen[members[a].UserID]++
+				for b := a + 1; b < len(members); b++ {
+					if blockedPair(users, constraints, members[a].UserID, members[b].UserID) {
+						t.Errorf("trial %d: 用户 %d 与 %d 被约束禁止却被配对", trial, members[a].UserID, members[b].UserID)
+					}
+				}
+			}
+		}
+		if len(seen) != n {
+			t.Errorf("trial %d: %d 名用户中只有 %d 人出现在结果中", trial, n, len(seen))
+		}
+	}
+	if solved == 0 {
+		t.Fatal("没有任何一次随机约束有解，测试没有覆盖到配对结果")
+	}
+}
+
+// testHistory 构造历史配对，pairs 为上一轮配对过的用户ID
+func testHistory(pairs ...[2]uint) *repeatHistory {
+	h := &repeatHistory{userPairs: make(map[[2]uint]bool), identityPairs: make(map[[2]string]bool)}
+	for _, pair := range pairs {
+		h.userPairs[userPairKey(pair[0], pair[1])] = true
+	}
+	return h
+}
+
+// countPairRepeats 统计配对结果中与历史重复的配对数量
+func countPairRepeats(users []models.PoolUser, history *repeatHistory, pairs []models.MatchPair) int {
+	byID := make(map[uint]*models.PoolUser, len(users))
+	for i := range users {
+		byID[users[i].ID] = &users[i]
+	}
+	count := 0
+	for _, pair := range pairs {
+		members := pairMembers(pair)
+		for a := range members {
+			for b := a + 1; b < len(members); b++ {
+				if history.seen(byID[members[a].UserID], byID[members[b].UserID]) {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// minPairRepeats 穷举满足约束的两两配对方案中最少的重复配对数量，无解时返回 -1
+func minPairRepeats(users []models.PoolUser, constraints []models.PoolConstraint, history *repeatHistory) int {
+	best := -1
+	used := make([]bool, len(users))
+	var try func(singles, repeats int)
+	try = func(singles, repeats int) {
+		if best >= 0 && repeats >= best {
+			return
+		}
+		i := 0
+		for i < len(users) && used[i] {
+			i++
+		}
+		if i == len(users) {
+			best = repeats
+			return
+		}
+		used[i] = true
+		if singles > 0 {
+			try(singles-1, repeats)
+		}
+		for j := i + 1; j < len(users); j++ {
+			if used[j] || blockedPair(users, constraints, users[i].ID, users[j].ID) {
+				continue
+			}
+			added := 0
+			if history.seen(&users[i], &users[j]) {
+				added = 1
+			}
+			used[j] = true
+			try(singles, repeats+added)
+			used[j] = false
+		}
+		used[i] = false
+	}
+	try(len(users)%2, 0)
+	return best
+}
+
+// TestRepeatAvoided 可以避免时不与上一轮的对象重复配对
+func TestRepeatAvoided(t *testing.T) {
+	users := testUsers(6, nil)
+	history := testHistory([2]uint{1, 2}, [2]uint{3, 4}, [2]uint{5, 6})
+
+	for trial := 0; trial < 20; trial++ {
+		rand.New(rand.NewSource(int64(trial))).Shuffle(len(users), func(i, j int) { users[i], users[j] = users[j], users[i] })
+		pairs, repeats, err := matchShuffled(users, pairPlan(6, 2, models.LeftoverSmaller), nil, history, userDisplayName)
+		if err != nil {
+			t.Fatalf("匹配失败: %v", err)
+		}
+		if repeats != 0 || countPairRepeats(users, history, pairs) != 0 {
+			t.Fatalf("可以完全避免重复，但报告 %d 个、实际 %d 个重复配对", repeats, countPairRepeats(users, history, pairs))
+		}
+	}
+}
+
+// TestRepeatForcedMinimal 无法避免重复时，重复数量最少且与报告的数量一致
+func TestRepeatForcedMinimal(t *testing.T) {
+	// 用户1与其他所有人都配对过，至少有一个重复
+	users := testUsers(4, nil)
+	history := testHistory([2]uint{1, 2}, [2]uint{1, 3}, [2]uint{1, 4})
+	pairs, repeats, err := matchShuffled(users, pairPlan(4, 2, models.LeftoverSmaller), nil, history, userDisplayName)
+	if err != nil {
+		t.Fatalf("匹配失败: %v", err)
+	}
+	if repeats != 1 || countPairRepeats(users, history, pairs) != 1 {
+		t.Errorf("最少 1 个重复配对，报告 %d 个、实际 %d 个", repeats, countPairRepeats(users, history, pairs))
+	}
+
+	// 随机的历史配对和约束，与穷举得到的最少重复数比较
+	rng := rand.New(rand.NewSource(1226))
+	for trial := 0; trial < 200; trial++ {
+		n := 3 + rng.Intn(6)
+		users := testUsers(n, nil)
+		var seen [][2]uint
+		for a := 1; a <= n; a++ {
+			for b := a + 1; b <= n; b++ {
+				if rng.Intn(2) == 0 {
+					seen = append(seen, [2]uint{uint(a), uint(b)})
+				}
+			}
+		}
+		history := testHistory(seen...)
+		var constraints []models.PoolConstraint
+		if rng.Intn(2) == 0 {
+			constraints = append(constraints, excludePair(1, uint(1+rng.Intn(n)), uint(1+rng.Intn(n))))
+		}
+
+		want := minPairRepeats(users, constraints, history)
+		pairs, repeats, err := matchShuffled(users, pairPlan(n, 2, models.LeftoverSmaller), constraints, history, userDisplayName)
+		if want < 0 {
+			if err == nil {
+				t.Fatalf("trial %d: 穷举确认无解，但匹配成功", trial)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("trial %d: 匹配失败: %v", trial, err)
+		}
+		if actual := countPairRepeats(users, history, pairs); repeats != want || actual != want {
+			t.Errorf("trial %d: 最少 %d 个重复配对，报告 %d 个、实际 %d 个", trial, want, repeats, actual)
+		}
+	}
+}
+
+// TestRepeatByIdentityField 按身份字段识别重新报名的同一个人
+func TestRepeatByIdentityField(t *testing.T) {
+	users := testUsers(4, func(i int) map[string]interface{} {
+		return map[string]interface{}{"email": fmt.Sprintf("u%d@example.com", i+1)}
+	})
+	// 上一轮的用户ID不同，但邮箱相同
+	previous := testUsers(4, func(i int) map[string]interface{} {
+		return map[string]interface{}{"email": fmt.Sprintf("U%d@example.com ", i+1)}
+	})
+	for i := range previous {
+		previous[i].ID += 100
+	}
+	record := models.MatchRecord{Pairs: buildGroups([][]models.PoolUser{
+		{previous[0], previous[1]}, {previous[2], previous[3]},
+	})}
+	history := newRepeatHistory([]models.MatchRecord{record}, "email")
+
+	pairs, repeats, err := matchShuffled(users, pairPlan(4, 2, models.LeftoverSmaller), nil, history, userDisplayName)
+	if err != nil {
+		t.Fatalf("匹配失败: %v", err)
+	}
+	if repeats != 0 || countPairRepeats(users, history, pairs) != 0 {
+		t.Errorf("应避免与邮箱相同的上一轮对象配对，重复 %d 个", repeats)
+	}
+}
+
+// TestGroupLeftoverPolicies 分组人数为 3、4，剩余 1、2 人时两种剩余策略的分组人数，且每名用户恰好出现一次
+func TestGroupLeftoverPolicies(t *testing.T) {
+	tests := []struct {
+		users     int
+		groupSize int
+		policy    string
+		want      []int
+	}{
+		{7, 3, models.LeftoverSmaller, []int{3, 3, 1}},
+		{8, 3, models.LeftoverSmaller, []int{3, 3, 2}},
+		{9, 4, models.LeftoverSmaller, []int{4, 4, 1}},
+		{10, 4, models.LeftoverSmaller, []int{4, 4, 2}},
+		{7, 3, models.LeftoverMerge, []int{4, 3}},
+		{8, 3, models.LeftoverMerge, []int{4, 4}},
+		{9, 4, models.LeftoverMerge, []int{5, 4}},
+		{10, 4, models.LeftoverMerge, []int{5, 5}},
+		{9, 3, models.LeftoverMerge, []int{3, 3, 3}},
+		{2, 3, models.LeftoverMerge, []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d人每组%d人_%s", tt.users, tt.groupSize, tt.policy), func(t *testing.T) {
+			users := testUsers(tt.users, nil)
+			plan := pairPlan(tt.users, tt.groupSize, tt.policy)
+
+			// 不带约束时按顺序切分，带约束时经过搜索，两条路径的分组人数应一致；只有一组时约束必然无法满足
+			runs := [][]models.PoolConstraint{nil}
+			if len(tt.want) > 1 {
+				runs = append(runs, []models.PoolConstraint{excludePair(1, 1, 2)})
+			}
+			for _, constraints := range runs {
+				pairs, _, err := matchShuffled(users, plan, constraints, nil, userDisplayName)
+				if err != nil {
+					t.Fatalf("匹配失败: %v", err)
+				}
+
+				sizes := make([]int, len(pairs))
+				seen := make(map[uint]int)
+				for i, pair := range pairs {
+					members := pairMembers(pair)
+					if pair.Size != len(members) {
+						t.Errorf("分组 %d 记录的人数 %d 与成员数 %d 不一致", pair.PairNumber, pair.Size, len(members))
+					}
+					sizes[i] = len(members)
+					for _, member := range members {
+						seen[member.UserID]++
+					}
+				}
+				sort.Sort(sort.Reverse(sort.IntSlice(sizes)))
+				if fmt.Sprint(sizes) != fmt.Sprint(tt.want) {
+					t.Errorf("分组人数为 %v，期望 %v", sizes, tt.want)
+				}
+				for _, user := range users {
+					if seen[user.ID] != 1 {
+						t.Errorf("用户 %d 出现了 %d 次", user.ID, seen[user.ID])
+					}
+				}
+				if len(constraints) > 0 && blockedInGroups(users, constraints, pairs) {
+					t.Error("分组中包含被约束禁止的两人")
+				}
+			}
+		})
+	}
+}
+
+// blockedInGroups 判断分组结果中是否有同组的两人被约束禁止配对
+func blockedInGroups(users []models.PoolUser, constraints []models.PoolConstraint, pairs []models.MatchPair) bool {
+	for _, pair := range pairs {
+		members := pairMembers(pair)
+		for a := range members {
+			for b := a + 1; b < len(members); b++ {
+				if blockedPair(users, constraints, members[a].UserID, members[b].UserID) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
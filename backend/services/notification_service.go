@@ -0,0 +1,377 @@
+package services
+
+import (
+	"bytes"
+	"christmas-link-backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxNotificationAttempts 匹配结果通知的最大发送次数
+const maxNotificationAttempts = 5
+
+// defaultNotifyTemplate 默认的匹配结果通知邮件正文
+const defaultNotifyTemplate = `{{.Name}}，你好！
+
+匹配池「{{.PoolName}}」第 {{.Round}} 轮匹配已完成。
+{{if not .Partners}}
+本轮你没有匹配对象（轮空），请留意下一轮匹配。
+{{else if .Directed}}
+你需要为这位参与者准备礼物：
+{{else}}
+你的匹配对象是：
+{{end}}
+{{- range .Partners}}
+【{{.Name}}】
+{{- range .Fields}}
+  {{.Label}}：{{.Value}}
+{{- end}}
+{{end}}
+祝你圣诞快乐！🎄
+`
+
+// notificationData 通知邮件模板可使用的数据
+type notificationData struct {
+	PoolName string
+	Round    int
+	Name     string // 收件人的显示名称
+	Directed bool   // 交换礼物模式，Partners 为收礼人
+	Partners []notificationPartner
+}
+
+// notificationPartner 匹配对象，只包含收件人有权看到的字段
+type notificationPartner struct {
+	Name   string
+	Fields []notificationField
+}
+
+// notificationField 按匹配池字段顺序排列的字段标签和取值
+type notificationField struct {
+	Label string
+	Value string
+}
+
+// notificationSkip 无法发送且重试也无济于事的通知，例如收件人没有邮箱
+type notificationSkip struct {
+	reason string
+}
+
+// Error 实现 error 接口
+func (e *notificationSkip) Error() string {
+	return e.reason
+}
+
+// parseNotifyTemplate 解析通知邮件模板，为空时使用默认模板
+func parseNotifyTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = defaultNotifyTemplate
+	}
+	return template.New("notification").Option("missingkey=error").Parse(text)
+}
+
+// formatFieldValue 将字段取值格式化为邮件中显示的文本
+func formatFieldValue(value interface{}) string {
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return "是"
+		}
+		return "否"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			items[i] = formatFieldValue(item)
+		}
+		return strings.Join(items, "、")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// participantEmail 参与者的收件邮箱：匹配池设置了 notifyEmailField 时取该字段，否则取联系方式；不是邮箱时返回空
+func participantEmail(pool *models.MatchPool, user *models.PoolUser) string {
+	email := user.ContactInfo
+	if pool.NotifyEmailField != "" {
+		value, _ := user.ParsedUserData[pool.NotifyEmailField].(string)
+		email = strings.TrimSpace(value)
+	}
+	if !isEmailAddress(email) {
+		return ""
+	}
+	return email
+}
+
+// enqueuePairNotifications 为配对创建待发送的通知，已有的通知（重新发送时）被替换：
+// 交换礼物模式只通知送礼人，其余模式通知分组的每位成员（轮空的用户也会收到通知）
+func enqueuePairNotifications(tx *gorm.DB, pair *models.MatchPair) error {
+	if err := tx.Where("pair_id = ?", pair.ID).Delete(&models.MatchNotification{}).Error; err != nil {
+		return err
+	}
+
+	recipients := []uint{pair.User1ID}
+	if !pair.Directed {
+		recipients = recipients[:0]
+		for _, member := range pairMembers(*pair) {
+			recipients = append(recipients, member.UserID)
+		}
+	}
+
+	now := time.Now()
+	for _, userID := range recipients {
+		if err := tx.Create(&models.MatchNotification{
+			RecordID:      pair.RecordID,
+			PairID:        pair.ID,
+			PairNumber:    pair.PairNumber,
+			UserID:        userID,
+			Status:        models.NotificationStatusPending,
+			NextAttemptAt: now,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NotificationService 匹配结果通知服务
+// 通知保存在数据库中，由后台循环发送；失败后按尝试次数延后重试，各实例通过条件更新领取，同一通知只会被发送一次
+type NotificationService struct {
+	db                *gorm.DB
+	mailSender        MailSender
+	visibilityService *VisibilityService
+	outbox            *outbox
+	interval          time.Duration
+}
+
+// NewNotificationService 创建匹配结果通知服务实例，轮询间隔由环境变量 NOTIFICATION_INTERVAL（秒）指定，默认15秒
+func NewNotificationService(db *gorm.DB) *NotificationService {
+	return &NotificationService{
+		db:                db,
+		mailSender:        NewMailSender(),
+		visibilityService: NewVisibilityService(db),
+		outbox: newOutbox(db, &models.MatchNotification{},
+			models.NotificationStatusPending, models.NotificationStatusSending),
+		interval: time.Duration(getEnvInt64OrDefault("NOTIFICATION_INTERVAL", 15)) * time.Second,
+	}
+}
+
+// Start 在后台启动发送循环
+func (s *NotificationService) Start() {
+	log.Printf("📮 匹配结果通知服务已启动，实例: %s，轮询间隔: %v，发送方式: %s", s.outbox.instanceID, s.interval, s.mailSender.Name())
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.DeliverDue()
+			<-ticker.C
+		}
+	}()
+}
+
+// DeliverDue 发送所有到期的通知，包括锁已过期（领取实例可能已崩溃）的通知
+func (s *NotificationService) DeliverDue() {
+	s.deliverDue(s.db)
+}
+
+// DeliverPair 只发送某个配对到期的通知
+func (s *NotificationService) DeliverPair(pairID uint) {
+	s.deliverDue(s.db.Where("pair_id = ?", pairID))
+}
+
+// deliverDue 发送查询范围内到期的通知
+func (s *NotificationService) deliverDue(scope *gorm.DB) {
+	var notifications []models.MatchNotification
+	if err := s.outbox.due(scope, &notifications); err != nil {
+		log.Printf("⚠️ 查询待发送的匹配结果通知失败: %v", err)
+		return
+	}
+
+	for i := range notifications {
+		notification := &notifications[i]
+		if s.outbox.claim(notification.ID, notification.Status, &notification.Attempts) {
+			s.deliver(notification)
+		}
+	}
+}
+
+// deliver 生成并发送通知邮件
+func (s *NotificationService) deliver(notification *models.MatchNotification) {
+	msg, err := s.buildMessage(notification)
+	if err != nil {
+		var skip *notificationSkip
+		if errors.As(err, &skip) {
+			s.finish(notification, models.NotificationStatusSkipped, err.Error())
+		} else {
+			s.finish(notification, models.NotificationStatusFailed, err.Error())
+		}
+		return
+	}
+
+	notification.Email = msg.To
+	if err := s.mailSender.Send(msg); err != nil {
+		if notification.Attempts < maxNotificationAttempts {
+			s.retry(notification, err.Error())
+		} else {
+			s.finish(notification, models.NotificationStatusFailed, err.Error())
+		}
+		return
+	}
+
+	s.finish(notification, models.NotificationStatusSent, "")
+}
+
+// buildMessage 按匹配池的模板生成收件人的通知邮件，匹配对象的字段按可见性隐藏
+func (s *NotificationService) buildMessage(notification *models.MatchNotification) (*MailMessage, error) {
+	var pair models.MatchPair
+	if err := s.db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).First(&pair, notification.PairID).Error; err != nil {
+		return nil, &notificationSkip{reason: "配对已不存在"}
+	}
+
+	var record models.MatchRecord
+	if err := s.db.First(&record, notification.RecordID).Error; err != nil {
+		return nil, &notificationSkip{reason: "匹配记录已不存在"}
+	}
+
+	var pool models.MatchPool
+	if err := s.db.Preload("Fields", func(db *gorm.DB) *gorm.DB {
+		return db.Order("field_order")
+	}).First(&pool, record.PoolID).Error; err != nil {
+		return nil, &notificationSkip{reason: "匹配池已不存在"}
+	}
+
+	var user models.PoolUser
+	if err := s.db.First(&user, notification.UserID).Error; err != nil {
+		return nil, &notificationSkip{reason: "参与者已不存在"}
+	}
+
+	email := participantEmail(&pool, &user)
+	if email == "" {
+		return nil, &notificationSkip{reason: "没有可用的邮箱地址"}
+	}
+
+	redacted, err := s.visibilityService.Redact(&models.MatchResult{
+		RecordID: record.ID,
+		PoolID:   pool.ID,
+		Pairs:    []models.MatchPairResult{newMatchPairResult(pair, userDisplayName)},
+	}, Viewer{UserID: user.ID})
+	if err != nil {
+		return nil, err
+	}
+
+	data := notificationData{
+		PoolName: pool.Name,
+		Round:    matchRound(s.db, pool.ID, record.ID),
+		Name:     userDisplayName(user.ParsedUserData),
+		Directed: pair.Directed,
+		Partners: []notificationPartner{},
+	}
+	for _, member := range redacted.Pairs[0].Members {
+		if member.UserID == user.ID {
+			continue
+		}
+		partner := notificationPartner{Name: member.Name}
+		for i := range pool.Fields {
+			value, ok := member.Data[pool.Fields[i].FieldName]
+			if !ok || isBlank(value) {
+				continue
+			}
+			partner.Fields = append(partner.Fields, notificationField{
+				Label: fieldLabel(&pool.Fields[i]),
+				Value: formatFieldValue(value),
+			})
+		}
+		data.Partners = append(data.Partners, partner)
+	}
+
+	tmpl, err := parseNotifyTemplate(pool.NotifyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("通知邮件模板格式错误: %v", err)
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return nil, fmt.Errorf("生成通知邮件失败: %v", err)
+	}
+
+	return &MailMessage{
+		To:      email,
+		Subject: fmt.Sprintf("【%s】匹配结果", pool.Name),
+		Body:    body.String(),
+	}, nil
+}
+
+// retry 释放通知并在稍后重试，等待时间随尝试次数增加
+func (s *NotificationService) retry(notification *models.MatchNotification, lastError string) {
+	nextAttemptAt := time.Now().Add(time.Duration(notification.Attempts) * time.Minute)
+	s.outbox.retry(notification.ID, nextAttemptAt, lastError, map[string]interface{}{"email": notification.Email})
+	log.Printf("⚠️ 匹配结果通知 %d 发送失败，将于 %s 重试: %s", notification.ID, nextAttemptAt.Format("2006-01-02 15:04:05"), lastError)
+}
+
+// finish 记录通知的最终结果
+func (s *NotificationService) finish(notification *models.MatchNotification, status string, lastError string) {
+	extra := map[string]interface{}{"email": notification.Email}
+	if status == models.NotificationStatusSent {
+		extra["sent_at"] = time.Now()
+	}
+	s.outbox.finish(notification.ID, status, lastError, extra)
+
+	switch status {
+	case models.NotificationStatusSent:
+		log.Printf("📮 匹配结果通知 %d 已发送: 配对 %d，用户 %d", notification.ID, notification.PairID, notification.UserID)
+	case models.NotificationStatusSkipped:
+		log.Printf("⏭️ 跳过匹配结果通知 %d: 用户 %d，%s", notification.ID, notification.UserID, lastError)
+	default:
+		log.Printf("❌ 匹配结果通知 %d 发送失败: 用户 %d，%s", notification.ID, notification.UserID, lastError)
+	}
+}
+
+// GetRecordNotifications 获取匹配记录各配对的通知发送状态
+func (s *NotificationService) GetRecordNotifications(recordID uint) ([]models.MatchNotification, error) {
+	var record models.MatchRecord
+	if err := s.db.First(&record, recordID).Error; err != nil {
+		return nil, fmt.Errorf("历史记录不存在")
+	}
+
+	notifications := []models.MatchNotification{}
+	if err := s.db.Where("record_id = ?", recordID).Order("pair_number, id").Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+// ResendPair 重新发送某个配对的通知（匹配池未开启通知时也可以手动发送），之前的发送状态被替换
+func (s *NotificationService) ResendPair(recordID uint, pairNumber int) ([]models.MatchNotification, error) {
+	var pair models.MatchPair
+	if err := s.db.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("record_id = ? AND pair_number = ?", recordID, pairNumber).First(&pair).Error; err != nil {
+		return nil, fmt.Errorf("配对不存在")
+	}
+
+	if err := s.db.Transaction(func(tx *gorm.DB) error {
+		return enqueuePairNotifications(tx, &pair)
+	}); err != nil {
+		return nil, err
+	}
+	log.Printf("📮 重新发送匹配结果通知: 记录 %d，第 %d 组", recordID, pairNumber)
+
+	// 在后台只发送该配对的通知，不阻塞请求
+	go s.DeliverPair(pair.ID)
+
+	notifications := []models.MatchNotification{}
+	if err := s.db.Where("pair_id = ?", pair.ID).Order("id").Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// outbox 保存在数据库中、由后台循环发送的队列共用的领取、重试和完成逻辑。
+// 队列表需要有 status、attempts、next_attempt_at、locked_by、locked_until 和 last_error 列；
+// 各实例以状态和尝试次数作为版本条件更新来领取记录，同一条记录同时只会被一个实例处理，
+// 领取后实例崩溃时，锁过期后由其他实例重新领取
+type outbox struct {
+	db         *gorm.DB
+	model      interface{} // 队列表的模型，例如 &models.MatchNotification{}
+	pending    string      // 等待发送（含等待重试）的状态值
+	sending    string      // 发送中的状态值
+	instanceID string
+	lockTTL    time.Duration
+}
+
+// newOutbox 创建队列，pending 和 sending 为该队列表使用的状态值
+func newOutbox(db *gorm.DB, model interface{}, pending, sending string) *outbox {
+	hostname, _ := os.Hostname()
+	return &outbox{
+		db:         db,
+		model:      model,
+		pending:    pending,
+		sending:    sending,
+		instanceID: fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		lockTTL:    5 * time.Minute,
+	}
+}
+
+// due 查询范围内到期的记录，包括锁已过期（领取实例可能已崩溃）的记录，按ID顺序放入 dest
+func (o *outbox) due(scope *gorm.DB, dest interface{}) error {
+	now := time.Now()
+	return scope.Where("((status = ? AND next_attempt_at <= ?) OR (status = ? AND locked_until < ?))",
+		o.pending, now, o.sending, now).
+		Order("id").
+		Find(dest).Error
+}
+
+// claim 领取记录：以读取时的状态和尝试次数为条件更新为发送中，成功时 attempts 加一
+func (o *outbox) claim(id uint, status string, attempts *int) bool {
+	result := o.db.Model(o.model).
+		Where("id = ? AND status = ? AND attempts = ?", id, status, *attempts).
+		Updates(map[string]interface{}{
+			"status":       o.sending,
+			"locked_by":    o.instanceID,
+			"locked_until": time.Now().Add(o.lockTTL),
+			"attempts":     *attempts + 1,
+		})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false
+	}
+	*attempts++
+	return true
+}
+
+// retry 释放本实例领取的记录，在 nextAttemptAt 之后重试；extra 为需要一并保存的其他列
+func (o *outbox) retry(id uint, nextAttemptAt time.Time, lastError string, extra map[string]interface{}) {
+	updates := map[string]interface{}{
+		"status":          o.pending,
+		"next_attempt_at": nextAttemptAt,
+		"locked_by":       "",
+		"locked_until":    nil,
+		"last_error":      lastError,
+	}
+	for column, value := range extra {
+		updates[column] = value
+	}
+	o.db.Model(o.model).Where("id = ? AND locked_by = ?", id, o.instanceID).Updates(updates)
+}
+
+// finish 记录本实例领取的记录的最终结果；extra 为需要一并保存的其他列
+func (o *outbox) finish(id uint, status string, lastError string, extra map[string]interface{}) {
+	updates := map[string]interface{}{
+		"status":       status,
+		"locked_until": nil,
+		"last_error":   lastError,
+	}
+	for column, value := range extra {
+		updates[column] = value
+	}
+	o.db.Model(o.model).Where("id = ? AND locked_by = ?", id, o.instanceID).Updates(updates)
+}
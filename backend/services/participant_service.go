@@ -0,0 +1,312 @@
+package services
+
+import (
+	"christmas-link-backend/cache"
+	"christmas-link-backend/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidManageToken 管理令牌无效或缺失
+var ErrInvalidManageToken = errors.New("管理令牌无效")
+
+// ParticipantService 参与者自助服务：凭加入时获得的管理令牌查看、修改、退出报名及查询本人的匹配结果
+type ParticipantService struct {
+	db           *gorm.DB
+	cacheService *cache.CacheService
+	mailSender   MailSender
+}
+
+// NewParticipantService 创建参与者自助服务实例
+func NewParticipantService(db *gorm.DB) *ParticipantService {
+	return &ParticipantService{
+		db:           db,
+		cacheService: cache.NewCacheService(),
+		mailSender:   NewMailSender(),
+	}
+}
+
+// generateManageToken 生成随机管理令牌，返回令牌及其摘要
+func generateManageToken() (string, string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := hex.EncodeToString(buf)
+	return token, hashManageToken(token), nil
+}
+
+// hashManageToken 计算管理令牌的 SHA-256 摘要，数据库中只保存摘要
+func hashManageToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// findUserByToken 根据管理令牌查找参与者
+func findUserByToken(db *gorm.DB, token string) (*models.PoolUser, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return nil, ErrInvalidManageToken
+	}
+
+	var user models.PoolUser
+	if err := db.Where("manage_token_hash = ?", hashManageToken(token)).First(&user).Error; err != nil {
+		return nil, ErrInvalidManageToken
+	}
+	return &user, nil
+}
+
+// GetEntry 查看本人的报名信息
+func (s *ParticipantService) GetEntry(token string) (*models.ParticipantEntry, error) {
+	user, err := findUserByToken(s.db, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var pool models.MatchPool
+	if err := s.db.Preload("Fields").First(&pool, user.PoolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+
+	entry := &models.ParticipantEntry{
+		UserID:      user.ID,
+		PoolID:      pool.ID,
+		PoolName:    pool.Name,
+		PoolStatus:  pool.CurrentStatus(),
+		Status:      userStatus(user, &pool),
+		UserData:    user.ParsedUserData,
+		ContactInfo: user.ContactInfo,
+		JoinedAt:    user.JoinedAt.Format("2006-01-02 15:04:05"),
+		Waitlisted:  user.Waitlisted,
+		Editable:    checkJoinable(&pool) == nil,
+		Fields:      pool.Fields,
+
+		PendingVerification: user.PendingVerification,
+		VerifiedAt:          formatTimePtr(user.VerifiedAt),
+	}
+
+	if user.Waitlisted {
+		var position int64
+		if err := s.db.Model(&models.PoolUser{}).
+			Where("pool_id = ? AND waitlisted = ? AND id <= ?", pool.ID, true, user.ID).
+			Count(&position).Error; err != nil {
+			return nil, err
+		}
+		entry.WaitlistPosition = int(position)
+	}
+
+	return entry, nil
+}
+
+// UpdateEntry 修改本人的报名信息，只能在匹配池报名期间修改，按字段定义重新校验
+func (s *ParticipantService) UpdateEntry(token string, req *models.UpdateEntryRequest) (*models.ParticipantEntry, error) {
+	user, err := findUserByToken(s.db, token)
+	if err != nil {
+		return nil, err
+	}
+
+	var pool models.MatchPool
+	if err := s.db.Preload("Fields").First(&pool, user.PoolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+	if checkJoinable(&pool) != nil {
+		return nil, fmt.Errorf("匹配池已不在报名期间，不能修改报名信息")
+	}
+
+	cleaned, err := validateUserData(pool.Fields, req.UserData)
+	if err != nil {
+		return nil, err
+	}
+	userData, err := json.Marshal(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("用户数据格式错误")
+	}
+
+	contact := user.ContactInfo
+	if req.ContactInfo != nil {
+		contact = normalizeContact(*req.ContactInfo)
+	}
+	// 需要验证邮箱的匹配池，更换邮箱后需要重新验证
+	reverify := pool.VerifyEmail && contact != user.ContactInfo
+	if reverify && !isEmailAddress(contact) {
+		return nil, FieldErrors{"contactInfo": "该匹配池需要验证邮箱，请填写有效的邮箱地址"}
+	}
+	updates := map[string]interface{}{
+		"user_data":    userData,
+		"contact_info": contact,
+	}
+
+	var verificationCode string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 与开始匹配互斥，匹配开始后不再接受修改
+		if err := lockOpenPool(tx, pool.ID); err != nil {
+			if errors.Is(err, errPoolStatusChanged) {
+				return fmt.Errorf("匹配池状态已变更，不能修改报名信息")
+			}
+			return err
+		}
+		if err := checkDuplicateJoin(tx, &pool, normalizeContact(contact), cleaned, user.ID); err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PoolUser{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if reverify {
+			code, err := issueVerificationCode(tx, user.ID)
+			if err != nil {
+				return err
+			}
+			verificationCode = code
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 清除相关缓存
+	s.cacheService.Delete(cache.GeneratePoolUsersKey(int(pool.ID)))
+	s.cacheService.Delete(cache.GeneratePoolStatsKey(int(pool.ID)))
+
+	if verificationCode != "" {
+		s.cacheService.Delete(cache.CacheKeyPools)
+		s.cacheService.Delete(cache.GeneratePoolKey(int(pool.ID)))
+		sendVerificationEmail(s.mailSender, &pool, user.ID, contact, verificationCode)
+	}
+
+	log.Printf("✏️ 参与者修改报名信息: 用户 %d, Pool %d", user.ID, pool.ID)
+	return s.GetEntry(token)
+}
+
+// Withdraw 退出报名，规则与移除用户相同：匹配开始后不能退出
+func (s *ParticipantService) Withdraw(token string) error {
+	user, err := findUserByToken(s.db, token)
+	if err != nil {
+		return err
+	}
+	return NewUserService(s.db).RemoveUser(user.ID)
+}
+
+// GetMatches 查询本人在各轮匹配中的配对，按匹配时间从新到旧排列；只包含本人所在的配对
+// （交换礼物模式下为送礼和收礼两条），并按字段可见性隐藏匹配对象的字段
+func (s *ParticipantService) GetMatches(token string) ([]models.MatchResult, error) {
+	user, err := findUserByToken(s.db, token)
+	if err != nil {
+		return nil, err
+	}
+	return NewHistoryService(s.db).GetParticipantMatches(user.ID)
+}
+
+// findContactUsers 查找匹配池中使用该邮箱报名且不在待验证状态的参与者，按报名顺序排列
+func findContactUsers(db *gorm.DB, poolID uint, contact string) ([]models.PoolUser, error) {
+	contact = normalizeContact(contact)
+	if !isEmailAddress(contact) {
+		return nil, fmt.Errorf("请填写报名时使用的邮箱")
+	}
+
+	var users []models.PoolUser
+	if err := db.Where("pool_id = ? AND contact_info = ? AND pending_verification = ?", poolID, contact, false).
+		Order("id").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// SendLookupCode 向报名邮箱发送查询匹配结果的一次性验证码，供丢失管理令牌的参与者使用。
+// 无论邮箱是否报名都返回成功，避免通过该接口探测报名情况；同一邮箱可能报名多次，验证码保存在最早的报名上
+func (s *ParticipantService) SendLookupCode(poolID uint, contact string) error {
+	users, err := findContactUsers(s.db, poolID, contact)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		log.Printf("📭 查询匹配结果的邮箱未报名: Pool %d", poolID)
+		return nil
+	}
+
+	user := users[0]
+	if user.VerificationSentAt != nil && time.Since(*user.VerificationSentAt) < verificationResendInterval {
+		log.Printf("⚠️ 查询匹配结果的验证码发送过于频繁: 用户 %d, Pool %d", user.ID, poolID)
+		return nil
+	}
+
+	var pool models.MatchPool
+	if err := s.db.First(&pool, poolID).Error; err != nil {
+		return nil
+	}
+	code, err := storeVerificationCode(s.db, user.ID, nil)
+	if err != nil {
+		return err
+	}
+	sendLookupEmail(s.mailSender, &pool, user.ID, user.ContactInfo, code)
+	return nil
+}
+
+// GetMatchesByContact 凭报名邮箱收到的验证码查询匹配结果，验证码只能使用一次；
+// 同一邮箱在该匹配池中的所有报名都属于本人，一并返回
+func (s *ParticipantService) GetMatchesByContact(poolID uint, contact, code string) ([]models.MatchResult, error) {
+	users, err := findContactUsers(s.db, poolID, contact)
+	if err != nil {
+		return nil, err
+	}
+	if len(users) == 0 {
+		return nil, fmt.Errorf("验证码无效或已过期")
+	}
+
+	hash, err := checkVerificationCode(s.db, &users[0], code)
+	if err != nil {
+		return nil, err
+	}
+	// 只有验证码未被重新发送替换、也未被并发的请求使用时才生效
+	result := s.db.Model(&models.PoolUser{}).
+		Where("id = ? AND verification_code_hash = ?", users[0].ID, hash).
+		Updates(map[string]interface{}{
+			"verification_code_hash":  "",
+			"verification_expires_at": nil,
+			"verification_attempts":   0,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected != 1 {
+		return nil, fmt.Errorf("验证码无效或已过期")
+	}
+
+	historyService := NewHistoryService(s.db)
+	var matches []models.MatchResult
+	for _, user := range users {
+		results, err := historyService.GetParticipantMatches(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, results...)
+	}
+	// 多次报名时按匹配记录从新到旧排列
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].RecordID > matches[j].RecordID })
+
+	log.Printf("🔎 参与者凭邮箱验证码查询匹配结果: 用户 %d, Pool %d", users[0].ID, poolID)
+	return matches, nil
+}
+
+// pairIncludes 判断配对结果中是否包含指定用户
+func pairIncludes(pair models.MatchPairResult, userID uint) bool {
+	if pair.User1ID == userID || (pair.User2ID != nil && *pair.User2ID == userID) {
+		return true
+	}
+	for _, member := range pair.Members {
+		if member.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestGetMatchesByContact 丢失管理令牌的参与者凭邮箱收到的一次性验证码查询本人的匹配结果
+func TestGetMatchesByContact(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+
+	db := newTestDB(t)
+	poolService := NewPoolService(db)
+	pool, err := poolService.CreatePool(&models.CreatePoolRequest{
+		Name:         "查询测试",
+		ValidUntil:   time.Now().Add(24 * time.Hour),
+		RandomSource: models.RandomSourceCrypto,
+		Fields: []models.PoolField{
+			{FieldName: "name", FieldLabel: "姓名", FieldType: models.FieldTypeText, IsRequired: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("创建匹配池失败: %v", err)
+	}
+
+	var userIDs []uint
+	for i := 1; i <= 4; i++ {
+		joined, err := poolService.JoinPool(&models.JoinPoolRequest{
+			PoolID:      pool.ID,
+			UserData:    map[string]interface{}{"name": fmt.Sprintf("用户%d", i)},
+			ContactInfo: fmt.Sprintf("user%d@example.com", i),
+		})
+		if err != nil {
+			t.Fatalf("加入匹配池失败: %v", err)
+		}
+		userIDs = append(userIDs, joined.UserID)
+	}
+	if _, err := poolService.StartMatch(&models.StartMatchRequest{PoolID: pool.ID}); err != nil {
+		t.Fatalf("匹配失败: %v", err)
+	}
+
+	participantService := NewParticipantService(db)
+
+	// 未报名的邮箱同样返回成功，但不发送邮件
+	if err := participantService.SendLookupCode(pool.ID, "nobody@example.com"); err != nil {
+		t.Fatalf("未报名的邮箱应返回成功: %v", err)
+	}
+	if err := participantService.SendLookupCode(pool.ID, "User2@Example.com"); err != nil {
+		t.Fatalf("发送验证码失败: %v", err)
+	}
+	to, body := server.waitMessage(t, 1)
+	if to != "user2@example.com" {
+		t.Fatalf("验证码发送到了 %s", to)
+	}
+	match := verificationCodePattern.FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("邮件中没有验证码:\n%s", body)
+	}
+
+	// 发送查询验证码不影响参与者的验证状态
+	var user models.PoolUser
+	db.First(&user, userIDs[1])
+	if user.PendingVerification {
+		t.Error("发送查询验证码后参与者不应变为待验证")
+	}
+
+	if _, err := participantService.GetMatchesByContact(pool.ID, "user3@example.com", match[1]); err == nil {
+		t.Error("其他参与者的邮箱不应通过验证")
+	}
+	results, err := participantService.GetMatchesByContact(pool.ID, "user2@example.com", match[1])
+	if err != nil {
+		t.Fatalf("凭验证码查询失败: %v", err)
+	}
+	if len(results) != 1 || len(results[0].Pairs) != 1 || !pairIncludes(results[0].Pairs[0], userIDs[1]) {
+		t.Fatalf("应只返回本人所在的一组配对: %+v", results)
+	}
+
+	// 验证码只能使用一次
+	if _, err := participantService.GetMatchesByContact(pool.ID, "user2@example.com", match[1]); err == nil {
+		t.Error("验证码不应重复使用")
+	}
+}
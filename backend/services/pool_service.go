@@ -4,9 +4,10 @@ import (
 	"christmas-link-backend/cache"
 	"christmas-link-backend/models"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -17,6 +18,9 @@ type PoolService struct {
 	db            *gorm.DB
 	cacheService  *cache.CacheService
 	randomService *RandomService
+	mailSender    MailSender
+	notifier      *NotificationService
+	webhooks      *WebhookService
 }
 
 // NewPoolService 创建匹配池服务实例
@@ -25,6 +29,9 @@ func NewPoolService(db *gorm.DB) *PoolService {
 		db:            db,
 		cacheService:  cache.NewCacheService(),
 		randomService: NewRandomService(),
+		mailSender:    NewMailSender(),
+		notifier:      NewNotificationService(db),
+		webhooks:      NewWebhookService(db),
 	}
 }
 
@@ -36,13 +43,84 @@ func (s *PoolService) CreatePool(req *models.CreatePoolRequest) (*models.PoolRes
 		cooldownTime = 5 // 默认5秒
 	}
 
+	// 设置默认匹配设置
+	matchMode := req.MatchMode
+	if matchMode == "" {
+		matchMode = models.MatchModePair
+	}
+	groupSize := req.GroupSize
+	if groupSize == 0 {
+		groupSize = 2
+	}
+	leftoverPolicy := req.LeftoverPolicy
+	if leftoverPolicy == "" {
+		leftoverPolicy = models.LeftoverSmaller
+	}
+
+	// 校验自动匹配设置
+	if req.MatchAt != nil {
+		if !req.AutoMatch {
+			return nil, fmt.Errorf("设置自动匹配时间需要开启 autoMatch")
+		}
+		if req.MatchAt.Before(time.Now()) {
+			return nil, fmt.Errorf("自动匹配时间不能早于当前时间")
+		}
+	}
+
+	// 校验初始状态：可以先保存为草稿，稍后再开放报名
+	status := req.Status
+	if status == "" {
+		status = models.PoolStatusOpen
+	}
+	if status != models.PoolStatusOpen && status != models.PoolStatusDraft {
+		return nil, fmt.Errorf("新建匹配池的状态只能是 open 或 draft")
+	}
+
 	pool := &models.MatchPool{
 		Name:         req.Name,
 		Description:  req.Description,
 		ValidUntil:   req.ValidUntil,
 		CooldownTime: cooldownTime,
-		Status:       "active",
+		MatchMode:    matchMode,
+		Status:       status,
 		Fields:       req.Fields,
+
+		GroupSize:      groupSize,
+		LeftoverPolicy: leftoverPolicy,
+
+		AvoidRepeatRounds:   req.AvoidRepeatRounds,
+		RepeatIdentityField: req.RepeatIdentityField,
+
+		Verifiable:   req.Verifiable,
+		RandomSource: req.RandomSource,
+
+		AutoMatch: req.AutoMatch,
+		MatchAt:   req.MatchAt,
+
+		MaxParticipants: req.MaxParticipants,
+
+		VerifyEmail: req.VerifyEmail,
+
+		NotifyMatches:    req.NotifyMatches,
+		NotifyEmailField: req.NotifyEmailField,
+		NotifyTemplate:   req.NotifyTemplate,
+
+		OrganizerEmail:        strings.TrimSpace(req.OrganizerEmail),
+		ReminderMailingList:   encodeReminderList(req.ReminderMailingList),
+		CloseReminderHours:    encodeReminderList(req.CloseReminderHours),
+		ExchangeAt:            req.ExchangeAt,
+		ExchangeReminderHours: encodeReminderList(req.ExchangeReminderHours),
+	}
+
+	if err := validatePoolSettings(pool, pool.Fields); err != nil {
+		return nil, err
+	}
+
+	// 可验证匹配需要在报名开始前公布第一轮的种子承诺
+	if pool.Verifiable {
+		if err := commitNextSeed(pool); err != nil {
+			return nil, err
+		}
 	}
 
 	// 在事务中创建匹配池和字段
@@ -57,7 +135,11 @@ func (s *PoolService) CreatePool(req *models.CreatePoolRequest) (*models.PoolRes
 			pool.Fields[i].PoolID = pool.ID
 		}
 
-		return nil
+		// 登记自动匹配任务和提醒
+		if err := scheduleAutoMatch(tx, pool); err != nil {
+			return err
+		}
+		return scheduleReminders(tx, pool)
 	})
 
 	if err != nil {
@@ -69,19 +151,568 @@ func (s *PoolService) CreatePool(req *models.CreatePoolRequest) (*models.PoolRes
 
 	// 构建响应格式
 	response := &models.PoolResponse{
-		ID:          pool.ID,
-		Name:        pool.Name,
-		Description: pool.Description,
-		UserCount:   0, // 新创建的池用户数为0
-		ValidUntil:  pool.ValidUntil.Format("2006-01-02 15:04:05"),
-		Status:      pool.Status,
-		Fields:      pool.Fields,
+		ID:           pool.ID,
+		Name:         pool.Name,
+		Description:  pool.Description,
+		UserCount:    0, // 新创建的池用户数为0
+		ValidUntil:   pool.ValidUntil.Format("2006-01-02 15:04:05"),
+		Status:       pool.CurrentStatus(),
+		CooldownTime: pool.CooldownTime,
+		MatchMode:    pool.MatchMode,
+		Fields:       pool.Fields,
+
+		GroupSize:      pool.GroupSize,
+		LeftoverPolicy: pool.LeftoverPolicy,
+
+		AvoidRepeatRounds:   pool.AvoidRepeatRounds,
+		RepeatIdentityField: pool.RepeatIdentityField,
+
+		Verifiable:     pool.Verifiable,
+		SeedCommitment: pool.SeedCommitment,
+		RandomSource:   pool.RandomSource,
+
+		AutoMatch: pool.AutoMatch,
+		MatchAt:   formatTimePtr(pool.MatchAt),
+
+		OpenedAt: formatTimePtr(pool.OpenedAt),
+
+		MaxParticipants: pool.MaxParticipants,
+
+		VerifyEmail: pool.VerifyEmail,
+
+		NotifyMatches:    pool.NotifyMatches,
+		NotifyEmailField: pool.NotifyEmailField,
+		NotifyTemplate:   pool.NotifyTemplate,
+
+		CloseReminderHours:    pool.CloseReminders(),
+		ExchangeAt:            formatTimePtr(pool.ExchangeAt),
+		ExchangeReminderHours: pool.ExchangeReminders(),
 	}
 
+	s.webhooks.Emit(models.WebhookEventPoolCreated, pool.ID, response)
+
 	log.Printf("✅ 创建匹配池成功: %s (ID: %d)", pool.Name, pool.ID)
 	return response, nil
 }
 
+// UpdatePool 更新匹配池。已有用户加入后只能修改字段的显示名称、顺序和匹配规则、将必填字段改为选填
+// 或新增选填字段，不能删除字段、修改字段类型或增加必填要求；被排除约束引用的字段不能删除
+func (s *PoolService) UpdatePool(id uint, req *models.UpdatePoolRequest) (*models.PoolResponse, error) {
+	var pool models.MatchPool
+	if err := s.db.First(&pool, id).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+
+	switch pool.CurrentStatus() {
+	case models.PoolStatusMatching:
+		return nil, fmt.Errorf("匹配池正在匹配中，请稍后再试")
+	case models.PoolStatusArchived:
+		return nil, fmt.Errorf("匹配池已归档，不能修改")
+	}
+
+	var fields []models.PoolField
+	if err := s.db.Where("pool_id = ?", id).Order("field_order").Find(&fields).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{
+		"version": gorm.Expr("version + 1"),
+	}
+
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			return nil, fmt.Errorf("匹配池名称不能为空")
+		}
+		pool.Name = *req.Name
+		updates["name"] = pool.Name
+	}
+	if req.Description != nil {
+		pool.Description = *req.Description
+		updates["description"] = pool.Description
+	}
+	if req.ValidUntil != nil {
+		if req.ValidUntil.Before(time.Now()) {
+			return nil, fmt.Errorf("截止时间不能早于当前时间")
+		}
+		pool.ValidUntil = *req.ValidUntil
+		updates["valid_until"] = pool.ValidUntil
+	}
+	if req.CooldownTime != nil {
+		if *req.CooldownTime <= 0 {
+			return nil, fmt.Errorf("冷却时间必须大于0")
+		}
+		pool.CooldownTime = *req.CooldownTime
+		updates["cooldown_time"] = pool.CooldownTime
+	}
+	if req.MatchMode != nil {
+		pool.MatchMode = *req.MatchMode
+		updates["match_mode"] = pool.MatchMode
+	}
+	if req.GroupSize != nil {
+		pool.GroupSize = *req.GroupSize
+		updates["group_size"] = pool.GroupSize
+	}
+	if req.LeftoverPolicy != nil {
+		pool.LeftoverPolicy = *req.LeftoverPolicy
+		updates["leftover_policy"] = pool.LeftoverPolicy
+	}
+	if req.AvoidRepeatRounds != nil {
+		pool.AvoidRepeatRounds = *req.AvoidRepeatRounds
+		updates["avoid_repeat_rounds"] = pool.AvoidRepeatRounds
+	}
+	if req.RepeatIdentityField != nil {
+		pool.RepeatIdentityField = *req.RepeatIdentityField
+		updates["repeat_identity_field"] = pool.RepeatIdentityField
+	}
+	if req.RandomSource != nil {
+		pool.RandomSource = *req.RandomSource
+		updates["random_source"] = pool.RandomSource
+	}
+
+	// 自动匹配设置
+	if req.AutoMatch != nil {
+		pool.AutoMatch = *req.AutoMatch
+		updates["auto_match"] = pool.AutoMatch
+		if !pool.AutoMatch {
+			pool.MatchAt = nil
+			updates["match_at"] = nil
+		}
+	}
+	if req.MatchAt != nil {
+		if !pool.AutoMatch {
+			return nil, fmt.Errorf("设置自动匹配时间需要开启 autoMatch")
+		}
+		if req.MatchAt.Before(time.Now()) {
+			return nil, fmt.Errorf("自动匹配时间不能早于当前时间")
+		}
+		pool.MatchAt = req.MatchAt
+		updates["match_at"] = pool.MatchAt
+	}
+
+	// 人数上限不能低于当前的正式参与者人数
+	if req.MaxParticipants != nil {
+		pool.MaxParticipants = *req.MaxParticipants
+		if count := pool.GetUserCount(s.db); pool.MaxParticipants > 0 && int64(pool.MaxParticipants) < count {
+			return nil, fmt.Errorf("当前已有 %d 名参与者，人数上限不能小于此数", count)
+		}
+		updates["max_participants"] = pool.MaxParticipants
+	}
+
+	// 开启邮箱验证只对之后的报名生效，已报名的用户不受影响
+	clearPending := false
+	if req.VerifyEmail != nil {
+		clearPending = pool.VerifyEmail && !*req.VerifyEmail
+		pool.VerifyEmail = *req.VerifyEmail
+		updates["verify_email"] = pool.VerifyEmail
+	}
+	if req.NotifyMatches != nil {
+		pool.NotifyMatches = *req.NotifyMatches
+		updates["notify_matches"] = pool.NotifyMatches
+	}
+	if req.NotifyEmailField != nil {
+		pool.NotifyEmailField = *req.NotifyEmailField
+		updates["notify_email_field"] = pool.NotifyEmailField
+	}
+	if req.NotifyTemplate != nil {
+		pool.NotifyTemplate = *req.NotifyTemplate
+		updates["notify_template"] = pool.NotifyTemplate
+	}
+
+	// 提醒设置，保存后按新的设置重新计算尚未发出的提醒
+	if req.OrganizerEmail != nil {
+		pool.OrganizerEmail = strings.TrimSpace(*req.OrganizerEmail)
+		updates["organizer_email"] = pool.OrganizerEmail
+	}
+	if req.ReminderMailingList != nil {
+		pool.ReminderMailingList = encodeReminderList(*req.ReminderMailingList)
+		updates["reminder_mailing_list"] = pool.ReminderMailingList
+	}
+	if req.CloseReminderHours != nil {
+		pool.CloseReminderHours = encodeReminderList(*req.CloseReminderHours)
+		updates["close_reminder_hours"] = pool.CloseReminderHours
+	}
+	if req.ExchangeAt != nil {
+		pool.ExchangeAt = req.ExchangeAt
+		updates["exchange_at"] = pool.ExchangeAt
+	}
+	if req.ExchangeReminderHours != nil {
+		pool.ExchangeReminderHours = encodeReminderList(*req.ExchangeReminderHours)
+		updates["exchange_reminder_hours"] = pool.ExchangeReminderHours
+	}
+
+	// 校验字段变更
+	newFields := fields
+	if req.Fields != nil {
+		var constraints []models.PoolConstraint
+		if err := s.db.Where("pool_id = ?", id).Find(&constraints).Error; err != nil {
+			return nil, err
+		}
+		var userCount int64
+		if err := s.db.Model(&models.PoolUser{}).Where("pool_id = ?", id).Count(&userCount).Error; err != nil {
+			return nil, err
+		}
+		if err := validateFieldChanges(fields, req.Fields, userCount > 0, constraints); err != nil {
+			return nil, err
+		}
+		newFields = req.Fields
+	}
+
+	if err := validatePoolSettings(&pool, newFields); err != nil {
+		return nil, err
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.MatchPool{}).
+			Where("id = ? AND version = ?", pool.ID, pool.Version).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return errPoolStatusChanged
+		}
+
+		if req.Fields != nil {
+			if err := saveFields(tx, pool.ID, fields, req.Fields); err != nil {
+				return err
+			}
+		}
+
+		// 关闭邮箱验证后待验证的用户直接参与匹配
+		if clearPending {
+			if err := tx.Model(&models.PoolUser{}).
+				Where("pool_id = ? AND pending_verification = ?", pool.ID, true).
+				Updates(map[string]interface{}{
+					"pending_verification":    false,
+					"verification_code_hash":  "",
+					"verification_expires_at": nil,
+				}).Error; err != nil {
+				return err
+			}
+		}
+
+		// 提高人数上限后递补候补用户
+		if req.MaxParticipants != nil {
+			if _, err := promoteWaitlisted(tx, &pool); err != nil {
+				return err
+			}
+		}
+
+		// 调整自动匹配任务
+		if req.AutoMatch != nil || req.MatchAt != nil || req.ValidUntil != nil {
+			if err := scheduleAutoMatch(tx, &pool); err != nil {
+				return err
+			}
+		}
+		return scheduleReminders(tx, &pool)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 清除相关缓存
+	s.cacheService.Delete(cache.CacheKeyPools)
+	s.cacheService.Delete(cache.GeneratePoolKey(int(pool.ID)))
+	s.cacheService.Delete(cache.GeneratePoolFieldsKey(int(pool.ID)))
+	s.cacheService.Delete(cache.GeneratePoolUsersKey(int(pool.ID)))
+	s.cacheService.Delete(cache.GeneratePoolStatsKey(int(pool.ID)))
+
+	log.Printf("✏️ 更新匹配池成功: %s (ID: %d)", pool.Name, pool.ID)
+	response, err := s.GetPoolByID(pool.ID)
+	if err != nil {
+		return nil, err
+	}
+	poolEvents.Publish(models.PoolEventPoolUpdated, pool.ID, response)
+	return response, nil
+}
+
+// validateFieldChanges 校验字段变更，hasUsers 表示已有用户加入
+func validateFieldChanges(oldFields, newFields []models.PoolField, hasUsers bool, constraints []models.PoolConstraint) error {
+	existing := make(map[string]models.PoolField, len(oldFields))
+	for _, field := range oldFields {
+		existing[field.FieldName] = field
+	}
+
+	seen := make(map[string]bool, len(newFields))
+	for _, field := range newFields {
+		if strings.TrimSpace(field.FieldName) == "" {
+			return fmt.Errorf("字段名不能为空")
+		}
+		if seen[field.FieldName] {
+			return fmt.Errorf("字段名重复: %s", field.FieldName)
+		}
+		seen[field.FieldName] = true
+
+		old, ok := existing[field.FieldName]
+		if !hasUsers {
+			continue
+		}
+		if !ok {
+			if field.IsRequired {
+				return fmt.Errorf("已有用户加入，新增字段 %s 不能为必填", field.FieldName)
+			}
+			continue
+		}
+		if field.FieldType != old.FieldType {
+			return fmt.Errorf("已有用户加入，不能修改字段 %s 的类型", field.FieldName)
+		}
+		if field.IsRequired && !old.IsRequired {
+			return fmt.Errorf("已有用户加入，不能将字段 %s 改为必填", field.FieldName)
+		}
+		// 已有答案引用的选项不能删除，只能新增
+		if field.FieldType == models.FieldTypeSelect || field.FieldType == models.FieldTypeMultiSelect {
+			oldOptions, _ := old.OptionList()
+			newOptions, _ := field.OptionList()
+			for _, option := range oldOptions {
+				if !containsString(newOptions, option) {
+					return fmt.Errorf("已有用户加入，不能删除字段 %s 的选项 %s", field.FieldName, option)
+				}
+			}
+		}
+	}
+
+	for _, old := range oldFields {
+		if seen[old.FieldName] {
+			continue
+		}
+		if hasUsers {
+			return fmt.Errorf("已有用户加入，不能删除字段 %s", old.FieldName)
+		}
+		for _, constraint := range constraints {
+			if constraint.Type == models.ConstraintExcludeSameField && constraint.FieldName == old.FieldName {
+				return fmt.Errorf("字段 %s 被排除约束引用，不能删除", old.FieldName)
+			}
+		}
+	}
+
+	return nil
+}
+
+// saveFields 保存字段变更：按字段名更新已有字段、创建新字段、删除移除的字段
+func saveFields(tx *gorm.DB, poolID uint, oldFields, newFields []models.PoolField) error {
+	existing := make(map[string]models.PoolField, len(oldFields))
+	for _, field := range oldFields {
+		existing[field.FieldName] = field
+	}
+
+	kept := make(map[string]bool, len(newFields))
+	for _, field := range newFields {
+		kept[field.FieldName] = true
+		field.PoolID = poolID
+		if field.MatchWeight == 0 {
+			field.MatchWeight = 1 // 与创建时的默认权重一致
+		}
+		if old, ok := existing[field.FieldName]; ok {
+			field.ID = old.ID
+			field.CreatedAt = old.CreatedAt
+			if err := tx.Select("*").Omit("created_at").Save(&field).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		field.ID = 0
+		if err := tx.Create(&field).Error; err != nil {
+			return err
+		}
+	}
+
+	for _, old := range oldFields {
+		if !kept[old.FieldName] {
+			if err := tx.Delete(&models.PoolField{}, old.ID).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeletePool 删除匹配池。没有匹配记录的匹配池连同字段、用户和约束一起删除；
+// 已有匹配记录的匹配池默认归档以保留历史，purge 为 true 时连同匹配记录一起删除。
+// 返回匹配池是否被归档而不是删除
+func (s *PoolService) DeletePool(id uint, purge bool) (bool, error) {
+	var pool models.MatchPool
+	if err := s.db.First(&pool, id).Error; err != nil {
+		return false, fmt.Errorf("匹配池不存在")
+	}
+	if pool.CurrentStatus() == models.PoolStatusMatching && !matchingStale(&pool) {
+		return false, fmt.Errorf("匹配池正在匹配中，请稍后再试")
+	}
+	fromStatus := pool.CurrentStatus()
+
+	var recordIDs []uint
+	if err := s.db.Model(&models.MatchRecord{}).Where("pool_id = ?", id).Pluck("id", &recordIDs).Error; err != nil {
+		return false, err
+	}
+
+	archive := len(recordIDs) > 0 && !purge
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("pool_id = ? AND status = ?", id, models.JobStatusPending).
+			Delete(&models.ScheduledMatchJob{}).Error; err != nil {
+			return err
+		}
+
+		if archive {
+			if pool.Status == models.PoolStatusArchived {
+				return nil
+			}
+			return updatePoolStatus(tx, &pool, models.PoolStatusArchived)
+		}
+
+		if len(recordIDs) > 0 {
+			pairIDs := tx.Model(&models.MatchPair{}).Select("id").Where("record_id IN ?", recordIDs)
+			if err := tx.Where("pair_id IN (?)", pairIDs).Delete(&models.MatchGroupMember{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("record_id IN ?", recordIDs).Delete(&models.MatchPair{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("record_id IN ?", recordIDs).Delete(&models.MatchRevision{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("record_id IN ?", recordIDs).Delete(&models.MatchNotification{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.MatchRecord{}, recordIDs).Error; err != nil {
+				return err
+			}
+		}
+
+		// 只订阅该匹配池的 Webhook 随匹配池一起删除，连同其投递记录
+		subscriptionIDs := tx.Model(&models.WebhookSubscription{}).Select("id").Where("pool_id = ?", id)
+		if err := tx.Where("subscription_id IN (?)", subscriptionIDs).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []interface{}{
+			&models.WebhookSubscription{},
+			&models.ScheduledMatchJob{},
+			&models.PoolReminder{},
+			&models.ReminderDelivery{},
+			&models.PoolConstraint{},
+			&models.PoolUser{},
+			&models.PoolField{},
+		} {
+			if err := tx.Where("pool_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&models.MatchPool{}, id).Error
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// 清除相关缓存
+	s.cacheService.Delete(cache.CacheKeyPools)
+	s.cacheService.Delete(cache.GeneratePoolKey(int(id)))
+	s.cacheService.Delete(cache.GeneratePoolUsersKey(int(id)))
+	s.cacheService.Delete(cache.GeneratePoolFieldsKey(int(id)))
+	s.cacheService.Delete(cache.GeneratePoolStatsKey(int(id)))
+	if len(recordIDs) > 0 && !archive {
+		s.cacheService.Delete(cache.CacheKeyHistory)
+		s.cacheService.Delete(cache.CacheKeyStats)
+		for _, recordID := range recordIDs {
+			s.cacheService.Delete(cache.GenerateHistoryKey(int(recordID)))
+		}
+	}
+
+	if archive {
+		publishStatusEvent(pool.ID, fromStatus, models.PoolStatusArchived)
+	} else {
+		poolEvents.Publish(models.PoolEventPoolDeleted, pool.ID, map[string]interface{}{"poolId": pool.ID})
+	}
+
+	if archive {
+		log.Printf("🗄️ 匹配池已有 %d 条匹配记录，已归档: %s (ID: %d)", len(recordIDs), pool.Name, pool.ID)
+	} else {
+		log.Printf("🗑️ 删除匹配池成功: %s (ID: %d)，同时删除 %d 条匹配记录", pool.Name, pool.ID, len(recordIDs))
+	}
+	return archive, nil
+}
+
+// validatePoolSettings 校验匹配池的匹配设置
+func validatePoolSettings(pool *models.MatchPool, fields []models.PoolField) error {
+	if err := validateFieldDefinitions(fields); err != nil {
+		return err
+	}
+
+	// 校验匹配模式
+	matchMode := pool.MatchMode
+	if matchMode != models.MatchModePair && matchMode != models.MatchModeGift && matchMode != models.MatchModeScore {
+		return fmt.Errorf("不支持的匹配模式: %s", matchMode)
+	}
+	if err := validateMatchRules(fields, matchMode); err != nil {
+		return err
+	}
+
+	// 校验分组设置
+	if pool.GroupSize < 2 {
+		return fmt.Errorf("每组人数至少为2")
+	}
+	if matchMode == models.MatchModeGift && pool.GroupSize != 2 {
+		return fmt.Errorf("交换礼物模式不支持分组人数设置")
+	}
+	if matchMode == models.MatchModeScore && pool.GroupSize != 2 {
+		return fmt.Errorf("打分匹配模式仅支持两两配对")
+	}
+	if pool.LeftoverPolicy != models.LeftoverSmaller && pool.LeftoverPolicy != models.LeftoverMerge {
+		return fmt.Errorf("不支持的剩余用户处理策略: %s", pool.LeftoverPolicy)
+	}
+
+	// 校验避免重复配对的设置
+	if pool.AvoidRepeatRounds < 0 {
+		return fmt.Errorf("避免重复的轮数不能为负数")
+	}
+	if pool.RepeatIdentityField != "" {
+		found := false
+		for _, field := range fields {
+			if field.FieldName == pool.RepeatIdentityField {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("身份识别字段不存在: %s", pool.RepeatIdentityField)
+		}
+	}
+
+	if pool.MaxParticipants < 0 {
+		return fmt.Errorf("人数上限不能为负数")
+	}
+
+	// 校验匹配结果通知设置
+	if pool.NotifyEmailField != "" {
+		found := false
+		for _, field := range fields {
+			if field.FieldName == pool.NotifyEmailField {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("通知邮箱字段不存在: %s", pool.NotifyEmailField)
+		}
+	}
+	if _, err := parseNotifyTemplate(pool.NotifyTemplate); err != nil {
+		return fmt.Errorf("通知邮件模板格式错误: %v", err)
+	}
+
+	// 校验提醒设置
+	if err := validateReminderSettings(pool); err != nil {
+		return err
+	}
+
+	// 校验随机数来源
+	if !IsValidRandomSource(pool.RandomSource) {
+		return fmt.Errorf("不支持的随机数来源: %s", pool.RandomSource)
+	}
+	if pool.Verifiable && pool.RandomSource != "" {
+		return fmt.Errorf("可验证匹配使用事先承诺的种子，不能同时指定随机数来源")
+	}
+
+	return nil
+}
+
 // GetPools 获取所有匹配池（带缓存）
 func (s *PoolService) GetPools() ([]models.PoolResponse, error) {
 	// 尝试从缓存获取
@@ -114,10 +745,42 @@ func (s *PoolService) GetPools() ([]models.PoolResponse, error) {
 			Description:   pool.Description,
 			UserCount:     userCount,
 			ValidUntil:    pool.ValidUntil.Format("2006-01-02 15:04:05"),
-			Status:        s.getPoolStatus(&pool),
+			Status:        pool.CurrentStatus(),
 			CooldownTime:  pool.CooldownTime,
+			MatchMode:     pool.MatchMode,
 			LastMatchedAt: lastMatchedAtStr,
 			Fields:        pool.Fields,
+
+			GroupSize:      pool.GroupSize,
+			LeftoverPolicy: pool.LeftoverPolicy,
+
+			AvoidRepeatRounds:   pool.AvoidRepeatRounds,
+			RepeatIdentityField: pool.RepeatIdentityField,
+
+			Verifiable:     pool.Verifiable,
+			SeedCommitment: pool.SeedCommitment,
+			RandomSource:   pool.RandomSource,
+
+			AutoMatch: pool.AutoMatch,
+			MatchAt:   formatTimePtr(pool.MatchAt),
+
+			OpenedAt:          formatTimePtr(pool.OpenedAt),
+			ClosedAt:          formatTimePtr(closedAt(&pool)),
+			MatchingStartedAt: formatTimePtr(pool.MatchingStartedAt),
+			ArchivedAt:        formatTimePtr(pool.ArchivedAt),
+
+			MaxParticipants: pool.MaxParticipants,
+			WaitlistCount:   pool.GetWaitlistCount(s.db),
+
+			VerifyEmail:  pool.VerifyEmail,
+			PendingCount: pool.GetPendingCount(s.db),
+
+			NotifyMatches:    pool.NotifyMatches,
+			NotifyEmailField: pool.NotifyEmailField,
+
+			CloseReminderHours:    pool.CloseReminders(),
+			ExchangeAt:            formatTimePtr(pool.ExchangeAt),
+			ExchangeReminderHours: pool.ExchangeReminders(),
 		}
 	}
 
@@ -159,10 +822,43 @@ func (s *PoolService) GetPoolByID(id uint) (*models.PoolResponse, error) {
 		Description:   dbPool.Description,
 		UserCount:     userCount,
 		ValidUntil:    dbPool.ValidUntil.Format("2006-01-02 15:04:05"),
-		Status:        s.getPoolStatus(&dbPool),
+		Status:        dbPool.CurrentStatus(),
 		CooldownTime:  dbPool.CooldownTime,
+		MatchMode:     dbPool.MatchMode,
 		LastMatchedAt: lastMatchedAtStr,
 		Fields:        dbPool.Fields,
+
+		GroupSize:      dbPool.GroupSize,
+		LeftoverPolicy: dbPool.LeftoverPolicy,
+
+		AvoidRepeatRounds:   dbPool.AvoidRepeatRounds,
+		RepeatIdentityField: dbPool.RepeatIdentityField,
+
+		Verifiable:     dbPool.Verifiable,
+		SeedCommitment: dbPool.SeedCommitment,
+		RandomSource:   dbPool.RandomSource,
+
+		AutoMatch: dbPool.AutoMatch,
+		MatchAt:   formatTimePtr(dbPool.MatchAt),
+
+		OpenedAt:          formatTimePtr(dbPool.OpenedAt),
+		ClosedAt:          formatTimePtr(closedAt(&dbPool)),
+		MatchingStartedAt: formatTimePtr(dbPool.MatchingStartedAt),
+		ArchivedAt:        formatTimePtr(dbPool.ArchivedAt),
+
+		MaxParticipants: dbPool.MaxParticipants,
+		WaitlistCount:   dbPool.GetWaitlistCount(s.db),
+
+		VerifyEmail:  dbPool.VerifyEmail,
+		PendingCount: dbPool.GetPendingCount(s.db),
+
+		NotifyMatches:    dbPool.NotifyMatches,
+		NotifyEmailField: dbPool.NotifyEmailField,
+		NotifyTemplate:   dbPool.NotifyTemplate,
+
+		CloseReminderHours:    dbPool.CloseReminders(),
+		ExchangeAt:            formatTimePtr(dbPool.ExchangeAt),
+		ExchangeReminderHours: dbPool.ExchangeReminders(),
 	}
 
 	// 缓存结果
@@ -172,184 +868,659 @@ func (s *PoolService) GetPoolByID(id uint) (*models.PoolResponse, error) {
 	return &pool, nil
 }
 
+// GetPoolStats 统计匹配池正式参与者对选择类字段（单选、多选、勾选）的作答情况，
+// 每个选项都会列出（包括无人选择的选项），候补用户不计入；仅管理员可见的字段只对管理员统计
+func (s *PoolService) GetPoolStats(poolID uint, admin bool) (*models.PoolStatsResponse, error) {
+	stats, err := s.getPoolStats(poolID)
+	if err != nil || admin {
+		return stats, err
+	}
+
+	visible := *stats
+	visible.Fields = make([]models.FieldStats, 0, len(stats.Fields))
+	for _, field := range stats.Fields {
+		if field.Visibility != models.FieldVisibilityAdmin {
+			visible.Fields = append(visible.Fields, field)
+		}
+	}
+	return &visible, nil
+}
+
+// getPoolStats 统计全部选择类字段（带缓存）
+func (s *PoolService) getPoolStats(poolID uint) (*models.PoolStatsResponse, error) {
+	cacheKey := cache.GeneratePoolStatsKey(int(poolID))
+
+	// 尝试从缓存获取
+	var stats models.PoolStatsResponse
+	if s.cacheService.GetJSON(cacheKey, &stats) {
+		log.Printf("📊 从缓存获取匹配池统计: %d", poolID)
+		return &stats, nil
+	}
+
+	var pool models.MatchPool
+	if err := s.db.Preload("Fields", func(db *gorm.DB) *gorm.DB {
+		return db.Order("field_order")
+	}).First(&pool, poolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+
+	var users []models.PoolUser
+	if err := s.db.Where("pool_id = ? AND waitlisted = ? AND pending_verification = ?", poolID, false, false).Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	stats = models.PoolStatsResponse{
+		PoolID:        pool.ID,
+		PoolName:      pool.Name,
+		UserCount:     int64(len(users)),
+		WaitlistCount: pool.GetWaitlistCount(s.db),
+		Fields:        []models.FieldStats{},
+		GeneratedAt:   time.Now().Format("2006-01-02 15:04:05"),
+	}
+
+	for i := range pool.Fields {
+		field := &pool.Fields[i]
+
+		var options []string
+		switch field.FieldType {
+		case models.FieldTypeSelect, models.FieldTypeMultiSelect:
+			options, _ = field.OptionList()
+		case models.FieldTypeBoolean:
+			options = []string{"true", "false"}
+		default:
+			continue
+		}
+
+		counts := make(map[string]int64, len(options))
+		fieldStats := models.FieldStats{
+			Name:       field.FieldName,
+			Label:      field.FieldLabel,
+			Type:       field.FieldType,
+			Visibility: field.EffectiveVisibility(),
+			Options:    make([]models.OptionCount, 0, len(options)),
+		}
+		for _, user := range users {
+			value, ok := user.ParsedUserData[field.FieldName]
+			if !ok || isBlank(value) {
+				continue
+			}
+			fieldStats.Answered++
+
+			switch v := value.(type) {
+			case []interface{}:
+				for _, item := range v {
+					counts[fmt.Sprint(item)]++
+				}
+			default:
+				counts[fmt.Sprint(v)]++
+			}
+		}
+		for _, option := range options {
+			fieldStats.Options = append(fieldStats.Options, models.OptionCount{Option: option, Count: counts[option]})
+		}
+		stats.Fields = append(stats.Fields, fieldStats)
+	}
+
+	// 缓存结果
+	s.cacheService.SetWithJSON(cacheKey, stats, cache.CacheExpireShort)
+	log.Printf("📊 从数据库获取匹配池统计: %d，已缓存", poolID)
+
+	return &stats, nil
+}
+
 // JoinPool 加入匹配池
-func (s *PoolService) JoinPool(req *models.JoinPoolRequest) error {
+// 匹配池设置了人数上限且已满员时，用户进入候补名单
+func (s *PoolService) JoinPool(req *models.JoinPoolRequest) (*models.JoinPoolResponse, error) {
 	// 检查匹配池是否存在
 	var pool models.MatchPool
-	if err := s.db.First(&pool, req.PoolID).Error; err != nil {
-		return fmt.Errorf("匹配池不存在")
+	if err := s.db.Preload("Fields").First(&pool, req.PoolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
 	}
 
 	// 检查匹配池状态
-	if pool.IsExpired() {
-		return fmt.Errorf("匹配池已过期")
+	if err := checkJoinable(&pool); err != nil {
+		return nil, err
+	}
+
+	// 按字段定义校验用户数据
+	cleaned, err := validateUserData(pool.Fields, req.UserData)
+	if err != nil {
+		return nil, err
 	}
 
 	// 将用户数据转换为JSON
-	userData, err := json.Marshal(req.UserData)
+	userData, err := json.Marshal(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("用户数据格式错误")
+	}
+
+	// 需要验证邮箱的匹配池，联系方式必须是邮箱
+	contact := normalizeContact(req.ContactInfo)
+	if pool.VerifyEmail && !isEmailAddress(contact) {
+		return nil, FieldErrors{"contactInfo": "该匹配池需要验证邮箱，请填写有效的邮箱地址"}
+	}
+
+	// 生成自助管理令牌，只保存摘要
+	manageToken, tokenHash, err := generateManageToken()
 	if err != nil {
-		return fmt.Errorf("用户数据格式错误")
+		return nil, fmt.Errorf("生成管理令牌失败: %v", err)
 	}
 
 	// 创建用户记录
 	poolUser := &models.PoolUser{
-		PoolID:      req.PoolID,
-		UserData:    userData,
-		ContactInfo: req.ContactInfo,
+		PoolID:          req.PoolID,
+		UserData:        userData,
+		ContactInfo:     contact,
+		ManageTokenHash: tokenHash,
 	}
 
-	if err := s.db.Create(poolUser).Error; err != nil {
-		return err
+	response := &models.JoinPoolResponse{ManageToken: manageToken}
+	var verificationCode string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 同一匹配池的报名请求在此排队，之后统计的人数不会被并发的报名绕过
+		if err := lockOpenPool(tx, pool.ID); err != nil {
+			if errors.Is(err, errPoolStatusChanged) {
+				return fmt.Errorf("匹配池状态已变更，暂不能加入")
+			}
+			return err
+		}
+
+		// 拒绝重复报名：联系方式或唯一字段与已有报名相同
+		if err := checkDuplicateJoin(tx, &pool, poolUser.ContactInfo, cleaned, 0); err != nil {
+			return err
+		}
+
+		// 检查人数上限
+		if pool.MaxParticipants > 0 {
+			var count int64
+			if err := tx.Model(&models.PoolUser{}).
+				Where("pool_id = ? AND waitlisted = ?", pool.ID, false).
+				Count(&count).Error; err != nil {
+				return err
+			}
+			poolUser.Waitlisted = count >= int64(pool.MaxParticipants)
+		}
+
+		if err := tx.Create(poolUser).Error; err != nil {
+			return err
+		}
+
+		response.UserID = poolUser.ID
+		response.Waitlisted = poolUser.Waitlisted
+
+		// 待验证邮箱的用户占用名额，但验证通过前不参与匹配
+		if pool.VerifyEmail {
+			code, err := issueVerificationCode(tx, poolUser.ID)
+			if err != nil {
+				return err
+			}
+			verificationCode = code
+			response.PendingVerification = true
+		}
+		if poolUser.Waitlisted {
+			var position int64
+			if err := tx.Model(&models.PoolUser{}).
+				Where("pool_id = ? AND waitlisted = ? AND id <= ?", pool.ID, true, poolUser.ID).
+				Count(&position).Error; err != nil {
+				return err
+			}
+			response.WaitlistPosition = int(position)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// 清除相关缓存
 	s.cacheService.Delete(cache.CacheKeyPools)
 	s.cacheService.Delete(cache.GeneratePoolKey(int(req.PoolID)))
 	s.cacheService.Delete(cache.GeneratePoolUsersKey(int(req.PoolID)))
+	s.cacheService.Delete(cache.GeneratePoolStatsKey(int(req.PoolID)))
 
-	log.Printf("✅ 用户加入匹配池成功: Pool %d", req.PoolID)
-	return nil
+	// 发送失败不影响报名，参与者可以凭管理令牌重新发送
+	if verificationCode != "" {
+		sendVerificationEmail(s.mailSender, &pool, poolUser.ID, contact, verificationCode)
+	}
+
+	publishParticipantEvent(s.db, models.PoolEventParticipantJoined, pool.ID, poolUser.ID, poolUser.Waitlisted)
+	s.webhooks.Emit(models.WebhookEventParticipantJoined, pool.ID, map[string]interface{}{
+		"userId":              poolUser.ID,
+		"userData":            webhookUserData(s.db, pool.ID, poolUser.UserData),
+		"waitlisted":          response.Waitlisted,
+		"pendingVerification": response.PendingVerification,
+	})
+
+	if response.Waitlisted {
+		log.Printf("🕒 匹配池已满，用户进入候补名单: Pool %d，第 %d 位", req.PoolID, response.WaitlistPosition)
+	} else {
+		log.Printf("✅ 用户加入匹配池成功: Pool %d", req.PoolID)
+	}
+	return response, nil
 }
 
+// promoteWaitlisted 匹配池有空位时按加入顺序将候补用户转为正式参与者，返回递补的用户ID
+func promoteWaitlisted(tx *gorm.DB, pool *models.MatchPool) ([]uint, error) {
+	// 先写匹配池，与并发的报名请求互斥
+	if err := tx.Model(&models.MatchPool{}).Where("id = ?", pool.ID).
+		Update("updated_at", time.Now()).Error; err != nil {
+		return nil, err
+	}
+
+	query := tx.Model(&models.PoolUser{}).
+		Where("pool_id = ? AND waitlisted = ?", pool.ID, true).
+		Order("id")
+	if pool.MaxParticipants > 0 {
+		var count int64
+		if err := tx.Model(&models.PoolUser{}).
+			Where("pool_id = ? AND waitlisted = ?", pool.ID, false).
+			Count(&count).Error; err != nil {
+			return nil, err
+		}
+		seats := pool.MaxParticipants - int(count)
+		if seats <= 0 {
+			return nil, nil
+		}
+		query = query.Limit(seats)
+	}
+
+	var promoted []uint
+	if err := query.Pluck("id", &promoted).Error; err != nil {
+		return nil, err
+	}
+	if len(promoted) == 0 {
+		return nil, nil
+	}
+	if err := tx.Model(&models.PoolUser{}).Where("id IN ?", promoted).
+		Update("waitlisted", false).Error; err != nil {
+		return nil, err
+	}
+
+	log.Printf("🎟️ 匹配池 %d 有空位，候补用户 %v 递补为正式参与者", pool.ID, promoted)
+	return promoted, nil
+}
+
+// errConcurrentMatch 匹配池已被其他请求抢先匹配
+var errConcurrentMatch = errors.New("匹配池正在被其他请求匹配，请稍后重试")
+
 // StartMatch 开始匹配
+// 同一匹配池的匹配互斥执行：Redis 可用时使用分布式锁，并始终以匹配池的版本号做乐观锁兜底；
+// 匹配池先进入 matching 状态停止报名，再读取参与者快照，匹配进行中加入的用户不会被部分包含。
+// 携带 IdempotencyKey 的重复请求直接返回首次匹配的结果
 func (s *PoolService) StartMatch(req *models.StartMatchRequest) (*models.MatchResult, error) {
+	if result, err := s.findIdempotentMatch(req); result != nil || err != nil {
+		return result, err
+	}
+
+	release, ok, err := s.cacheService.AcquireLock(cache.GeneratePoolMatchLockKey(int(req.PoolID)), matchLockTTL)
+	if err != nil {
+		return nil, fmt.Errorf("获取匹配锁失败: %v", err)
+	}
+	if !ok {
+		return nil, errConcurrentMatch
+	}
+	defer release()
+
+	// 获取锁后再检查一次，同一 Idempotency-Key 的并发请求只有一个会真正执行匹配
+	if result, err := s.findIdempotentMatch(req); result != nil || err != nil {
+		return result, err
+	}
+
 	// 获取匹配池信息
 	var pool models.MatchPool
-	if err := s.db.First(&pool, req.PoolID).Error; err != nil {
+	if err := s.db.Preload("Fields").First(&pool, req.PoolID).Error; err != nil {
 		return nil, fmt.Errorf("匹配池不存在")
 	}
 
 	// 检查匹配池状态和冷却时间
-	if pool.Status == "matched" {
-		if pool.LastMatchedAt != nil {
-			// 正常的冷却时间检查
-			cooldownDuration := time.Duration(pool.CooldownTime) * time.Second
-			timeElapsed := time.Since(*pool.LastMatchedAt)
-
-			if timeElapsed < cooldownDuration {
-				remainingTime := cooldownDuration - timeElapsed
-				return nil, fmt.Errorf("匹配池正在冷却中，还需等待 %.1f 秒", remainingTime.Seconds())
+	if err := checkMatchable(&pool); err != nil {
+		if errors.Is(err, errConcurrentMatch) {
+			if result, findErr := s.findIdempotentMatch(req); result != nil {
+				return result, findErr
 			}
-
-			// 冷却时间结束，重置状态
-			log.Printf("🔄 匹配池 %s 冷却时间结束，重置为active状态", pool.Name)
-		} else {
-			// 处理遗留数据：status为matched但LastMatchedAt为null的情况
-			log.Printf("🔧 发现遗留数据：匹配池 %s 状态为matched但LastMatchedAt为null，重置为active状态", pool.Name)
 		}
+		return nil, err
+	}
 
-		pool.Status = "active"
-		if err := s.db.Save(&pool).Error; err != nil {
-			return nil, fmt.Errorf("重置匹配池状态失败: %v", err)
+	// 进入匹配中状态：版本号已变化说明其他请求已开始匹配
+	previousStatus := pool.Status
+	if previousStatus == models.PoolStatusMatching {
+		previousStatus = models.PoolStatusClosed
+	}
+	fromStatus := pool.CurrentStatus()
+	if err := updatePoolStatus(s.db, &pool, models.PoolStatusMatching); err != nil {
+		if errors.Is(err, errPoolStatusChanged) {
+			err = errConcurrentMatch
+			if result, findErr := s.findIdempotentMatch(req); result != nil {
+				return result, findErr
+			}
 		}
+		return nil, err
 	}
+	publishStatusEvent(pool.ID, fromStatus, models.PoolStatusMatching)
 
-	// 检查匹配池是否可用
-	if pool.Status != "active" {
-		return nil, fmt.Errorf("匹配池当前状态不可用: %s", pool.Status)
+	matchMode := pool.MatchMode
+	if matchMode == "" {
+		matchMode = models.MatchModePair
+	}
+	groupSize := pool.GroupSize
+	if groupSize < 2 {
+		groupSize = 2
 	}
 
-	// 获取所有用户
 	var users []models.PoolUser
-	if err := s.db.Where("pool_id = ?", req.PoolID).Find(&users).Error; err != nil {
-		return nil, err
-	}
+	var pairs []models.MatchPair
+	var repeatCount int
+	var reveal *seedReveal
+	var draw *RandomDraw
+	record := &models.MatchRecord{}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// 获取所有正式参与者（参与者快照），候补用户和未验证邮箱的用户不参与匹配
+		if err := tx.Where("pool_id = ? AND waitlisted = ? AND pending_verification = ?", req.PoolID, false, false).Find(&users).Error; err != nil {
+			return err
+		}
 
-	if len(users) < 2 {
-		return nil, fmt.Errorf("用户数量不足，至少需要2个用户")
-	}
+		if len(users) < 2 {
+			return fmt.Errorf("用户数量不足，至少需要2个用户")
+		}
 
-	// 执行随机匹配
-	pairs := s.performMatching(users)
+		// 获取排除约束
+		var constraints []models.PoolConstraint
+		if err := tx.Where("pool_id = ?", req.PoolID).Find(&constraints).Error; err != nil {
+			return err
+		}
 
-	// 保存匹配记录
-	record := &models.MatchRecord{
-		PoolID:      req.PoolID,
-		PoolName:    pool.Name,
-		TotalUsers:  len(users),
-		PairsCount:  len(pairs),
-		HasLoneUser: len(users)%2 == 1,
-		Status:      "completed",
-	}
+		// 获取最近几轮的配对历史
+		history, err := s.loadRepeatHistory(tx, &pool)
+		if err != nil {
+			return err
+		}
+
+		// 执行随机匹配，可验证匹配使用事先承诺的种子确定性地打乱参与者
+		plan := newMatchPlan(&pool, len(users))
+		if pool.Verifiable {
+			reveal, err = newSeedReveal(&pool, users)
+			if err != nil {
+				return err
+			}
+			pairs, repeatCount, err = matchShuffled(reveal.shuffled, plan, constraints, history, s.getUserDisplayName)
+			if err != nil {
+				return fmt.Errorf("无法完成匹配: %v", err)
+			}
+			log.Printf("🔐 使用已承诺的种子完成可验证匹配，承诺: %s", reveal.commitment)
+		} else {
+			pairs, repeatCount, draw, err = s.performMatching(users, plan, constraints, history, pool.RandomSource)
+			if err != nil {
+				return err
+			}
+		}
+
+		hasLoneUser := false
+		totalScore := 0.0
+		for _, pair := range pairs {
+			if pair.Size == 1 {
+				hasLoneUser = true
+			}
+			totalScore += pair.Score
+		}
+
+		// 保存匹配记录
+		*record = models.MatchRecord{
+			PoolID:      req.PoolID,
+			PoolName:    pool.Name,
+			TotalUsers:  len(users),
+			PairsCount:  len(pairs),
+			HasLoneUser: hasLoneUser,
+			MatchMode:   matchMode,
+			GroupSize:   groupSize,
+			RepeatCount: repeatCount,
+			TotalScore:  totalScore,
+			Status:      "completed",
+		}
+		if req.IdempotencyKey != "" {
+			record.IdempotencyKey = &req.IdempotencyKey
+		}
+		if reveal != nil {
+			inputs, err := reveal.inputs(&pool, constraints, history)
+			if err != nil {
+				return err
+			}
+			record.Seed = reveal.seed
+			record.SeedCommitment = reveal.commitment
+			record.ParticipantDigest = reveal.digest
+			record.MatchInputs = inputs
+			record.RandomSource = models.RandomSourceCommitReveal
+		}
+		if draw != nil {
+			record.RandomSource = draw.Source
+			record.RandomSerial = draw.Serial
+			record.RandomSignature = draw.Signature
+			record.RandomSignedData = draw.SignedData
+		}
 
-	now := time.Now()
-	err := s.db.Transaction(func(tx *gorm.DB) error {
 		// 创建匹配记录
 		if err := tx.Create(record).Error; err != nil {
-			return err
+			return fmt.Errorf("保存匹配记录失败: %v", err)
 		}
 
 		// 创建配对记录
-		for _, pair := range pairs {
-			pair.RecordID = record.ID
-			if err := tx.Create(&pair).Error; err != nil {
+		for i := range pairs {
+			pairs[i].RecordID = record.ID
+			if err := tx.Create(&pairs[i]).Error; err != nil {
+				return fmt.Errorf("保存匹配记录失败: %v", err)
+			}
+		}
+
+		// 与匹配记录一起保存待发送的匹配结果通知
+		if pool.NotifyMatches {
+			for i := range pairs {
+				if err := enqueuePairNotifications(tx, &pairs[i]); err != nil {
+					return fmt.Errorf("保存匹配结果通知失败: %v", err)
+				}
+			}
+		}
+
+		// 可验证匹配同时公布下一轮的种子承诺
+		if pool.Verifiable {
+			if err := commitNextSeed(&pool); err != nil {
 				return err
 			}
+			if err := tx.Model(&models.MatchPool{}).Where("id = ?", pool.ID).Updates(map[string]interface{}{
+				"pending_seed":      pool.PendingSeed,
+				"seed_commitment":   pool.SeedCommitment,
+				"seed_committed_at": pool.SeedCommittedAt,
+			}).Error; err != nil {
+				return fmt.Errorf("保存匹配记录失败: %v", err)
+			}
 		}
 
 		// 更新匹配池状态和最后匹配时间
-		pool.Status = "matched"
-		pool.LastMatchedAt = &now
-		if err := tx.Save(&pool).Error; err != nil {
-			return err
+		if err := updatePoolStatus(tx, &pool, models.PoolStatusMatched); err != nil {
+			if errors.Is(err, errPoolStatusChanged) {
+				return errConcurrentMatch
+			}
+			return fmt.Errorf("保存匹配记录失败: %v", err)
 		}
 
 		return nil
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("保存匹配记录失败: %v", err)
+		// 匹配失败时回到进入匹配中之前的状态
+		restore := s.db.Model(&models.MatchPool{}).
+			Where("id = ? AND version = ?", pool.ID, pool.Version).
+			Updates(map[string]interface{}{
+				"status":  previousStatus,
+				"version": gorm.Expr("version + 1"),
+			})
+		if restore.Error != nil || restore.RowsAffected != 1 {
+			log.Printf("⚠️ 恢复匹配池 %d 状态失败: %v", pool.ID, restore.Error)
+		} else {
+			restored := models.MatchPool{Status: previousStatus, ValidUntil: pool.ValidUntil}
+			publishStatusEvent(pool.ID, models.PoolStatusMatching, restored.CurrentStatus())
+		}
+
+		// 同一 Idempotency-Key 的请求被其他实例抢先完成时返回其结果
+		if errors.Is(err, errConcurrentMatch) {
+			if result, findErr := s.findIdempotentMatch(req); result != nil {
+				return result, findErr
+			}
+		}
+		return nil, err
 	}
 
 	// 构建返回结果
 	result := &models.MatchResult{
-		PoolName:   pool.Name,
-		TotalUsers: len(users),
-		Pairs:      make([]models.MatchPairResult, len(pairs)),
-		Timestamp:  time.Now().Format("2006-01-02 15:04:05"),
+		RecordID:    record.ID,
+		PoolID:      pool.ID,
+		PoolName:    pool.Name,
+		Round:       matchRound(s.db, pool.ID, record.ID),
+		TotalUsers:  len(users),
+		MatchMode:   matchMode,
+		GroupSize:   groupSize,
+		RepeatCount: repeatCount,
+		TotalScore:  record.TotalScore,
+		Pairs:       make([]models.MatchPairResult, len(pairs)),
+		Timestamp:   time.Now().Format("2006-01-02 15:04:05"),
 	}
 
 	for i, pair := range pairs {
-		user1Name := s.getUserDisplayName(pair.ParsedUser1Data)
-		result.Pairs[i] = models.MatchPairResult{
-			Pair:      pair.PairNumber,
-			User1:     user1Name,
-			User1Data: pair.ParsedUser1Data,
-		}
-
-		if pair.ParsedUser2Data != nil {
-			user2Name := s.getUserDisplayName(pair.ParsedUser2Data)
-			result.Pairs[i].User2 = user2Name
-			result.Pairs[i].User2Data = pair.ParsedUser2Data
-		}
+		result.Pairs[i] = newMatchPairResult(pair, s.getUserDisplayName)
+	}
+	if reveal != nil {
+		result.Seed = reveal.seed
+		result.SeedCommitment = reveal.commitment
 	}
+	result.RandomSource = record.RandomSource
+	result.RandomSerial = record.RandomSerial
+	result.RandomSignature = record.RandomSignature
+	result.RandomSignedData = record.RandomSignedData
 
 	// 清除相关缓存
 	s.cacheService.Delete(cache.CacheKeyPools)
 	s.cacheService.Delete(cache.CacheKeyHistory)
 	s.cacheService.Delete(cache.GeneratePoolKey(int(req.PoolID)))
 
+	// 立即发送匹配结果通知，失败的由后台通知服务重试
+	if pool.NotifyMatches {
+		go s.notifier.DeliverDue()
+	}
+	publishStatusEvent(pool.ID, models.PoolStatusMatching, models.PoolStatusMatched)
+	poolEvents.Publish(models.PoolEventMatchCompleted, pool.ID, result)
+	// 发送给第三方的匹配结果按匿名访问者隐藏字段
+	if public, err := NewVisibilityService(s.db).Redact(result, Viewer{}); err == nil {
+		s.webhooks.Emit(models.WebhookEventMatchCompleted, pool.ID, public)
+	} else {
+		log.Printf("⚠️ 隐藏匹配结果字段失败，未发送 Webhook: Pool %d: %v", pool.ID, err)
+	}
+
 	log.Printf("✅ 匹配完成: Pool %d, %d个用户, %d对配对", req.PoolID, len(users), len(pairs))
 	return result, nil
 }
 
-// performMatching 执行随机匹配算法（使用 random.org）
-func (s *PoolService) performMatching(users []models.PoolUser) []models.MatchPair {
-	// 使用真随机数打乱用户列表
+// findIdempotentMatch 查找使用同一 Idempotency-Key 完成的匹配，未携带或未找到时返回 nil
+func (s *PoolService) findIdempotentMatch(req *models.StartMatchRequest) (*models.MatchResult, error) {
+	if req.IdempotencyKey == "" {
+		return nil, nil
+	}
+
+	var record models.MatchRecord
+	if err := s.db.Where("idempotency_key = ?", req.IdempotencyKey).First(&record).Error; err != nil {
+		return nil, nil
+	}
+	if record.PoolID != req.PoolID {
+		return nil, fmt.Errorf("Idempotency-Key 已用于其他匹配池的匹配")
+	}
+
+	log.Printf("♻️ Idempotency-Key 重复请求，返回已有匹配记录: %d", record.ID)
+	return NewHistoryService(s.db).GetHistoryByID(record.ID)
+}
+
+// performMatching 执行随机匹配算法，sourceName 为匹配池指定的随机数来源（为空时使用全局默认来源），
+// 返回配对（分组）结果、无法避免的重复配对数量和实际使用的随机数抽取信息
+func (s *PoolService) performMatching(users []models.PoolUser, plan *matchPlan, constraints []models.PoolConstraint, history *repeatHistory, sourceName string) ([]models.MatchPair, int, *RandomDraw, error) {
+	shuffled, draw, err := s.shuffleUsers(users, sourceName)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	pairs, repeatCount, err := matchShuffled(shuffled, plan, constraints, history, s.getUserDisplayName)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("无法完成匹配: %v", err)
+	}
+
+	log.Printf("🎲 使用 %s 随机数完成用户匹配，模式: %s，总用户: %d，分组数: %d，约束: %d，重复配对: %d", draw.Source, plan.mode, len(users), len(pairs), len(constraints), repeatCount)
+	return pairs, repeatCount, draw, nil
+}
+
+// matchShuffled 根据打乱后的用户顺序生成配对。存在排除约束或配对历史时在打乱结果上搜索满足约束、
+// 重复最少的方案；打分匹配模式下求总分最高的两两配对，同分方案由打乱顺序决定。
+// 结果只取决于输入，可验证匹配据此复算配对
+func matchShuffled(shuffled []models.PoolUser, plan *matchPlan, constraints []models.PoolConstraint, history *repeatHistory, displayName func(map[string]interface{}) string) ([]models.MatchPair, int, error) {
+	groups := plan.chunk(shuffled)
+	repeatCount := 0
+	if plan.mode == models.MatchModeScore {
+		arranged, repeats, err := arrangeByScore(shuffled, plan, constraints, history, plan.scorer, displayName)
+		if err != nil {
+			return nil, 0, err
+		}
+		groups = arranged
+		repeatCount = repeats
+	} else if len(constraints) > 0 || history != nil {
+		arranged, repeats, err := arrangeUsers(shuffled, plan, constraints, history, displayName)
+		if err != nil {
+			return nil, 0, err
+		}
+		groups = arranged
+		repeatCount = repeats
+	}
+
+	var pairs []models.MatchPair
+	if plan.mode == models.MatchModeGift {
+		pairs = buildGiftCycle(groups[0])
+	} else {
+		pairs = buildGroups(groups)
+	}
+	if plan.mode == models.MatchModeScore {
+		for i, group := range groups {
+			if len(group) == 2 {
+				pairs[i].Score = plan.scorer.score(&group[0], &group[1])
+			}
+		}
+	}
+
+	return pairs, repeatCount, nil
+}
+
+// loadRepeatHistory 读取匹配池最近 N 轮的配对历史，未开启避免重复时返回 nil
+func (s *PoolService) loadRepeatHistory(db *gorm.DB, pool *models.MatchPool) (*repeatHistory, error) {
+	if pool.AvoidRepeatRounds <= 0 {
+		return nil, nil
+	}
+
+	var records []models.MatchRecord
+	if err := db.Preload("Pairs").Preload("Pairs.Members").
+		Where("pool_id = ?", pool.ID).
+		Order("matched_at DESC").
+		Limit(pool.AvoidRepeatRounds).
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询历史配对失败: %v", err)
+	}
+
+	return newRepeatHistory(records, pool.RepeatIdentityField), nil
+}
+
+// shuffleUsers 使用指定的随机数来源打乱用户列表
+func (s *PoolService) shuffleUsers(users []models.PoolUser, sourceName string) ([]models.PoolUser, *RandomDraw, error) {
 	userInterfaces := make([]interface{}, len(users))
 	for i, user := range users {
 		userInterfaces[i] = user
 	}
 
-	// 使用 random.org 提供的真随机数进行打乱
-	if err := s.randomService.ShuffleSlice(userInterfaces); err != nil {
-		log.Printf("⚠️ 使用 random.org 打乱失败，使用本地随机数: %v", err)
-		// 如果 random.org 失败，使用本地随机数作为备选
-		rand.Seed(time.Now().UnixNano())
-		for i := len(userInterfaces) - 1; i > 0; i-- {
-			j := rand.Intn(i + 1)
-			userInterfaces[i], userInterfaces[j] = userInterfaces[j], userInterfaces[i]
-		}
+	draw, err := s.randomService.ShuffleSlice(userInterfaces, sourceName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("打乱用户列表失败: %v", err)
 	}
 
 	// 转换回用户列表
@@ -357,55 +1528,95 @@ func (s *PoolService) performMatching(users []models.PoolUser) []models.MatchPai
 	for i, userInterface := range userInterfaces {
 		shuffled[i] = userInterface.(models.PoolUser)
 	}
+	return shuffled, draw, nil
+}
 
-	var pairs []models.MatchPair
-	pairNumber := 1
+// buildGroups 将分组结果转换为配对记录，单人分组即为轮空
+func buildGroups(groups [][]models.PoolUser) []models.MatchPair {
+	pairs := make([]models.MatchPair, 0, len(groups))
+	for i, group := range groups {
+		pairs = append(pairs, newMatchPair(i+1, group, false))
+	}
+	return pairs
+}
 
-	// 两两配对
-	for i := 0; i < len(shuffled)-1; i += 2 {
-		user1 := shuffled[i]
-		user2 := shuffled[i+1]
+// buildGiftCycle 按打乱后的顺序构建单一有向环：第 i 人送礼物给第 i+1 人，最后一人送给第一人
+// 随机排列首尾相连即得到一个随机错排，保证每人恰好送出并收到一份礼物，且不会送给自己
+func buildGiftCycle(shuffled []models.PoolUser) []models.MatchPair {
+	pairs := make([]models.MatchPair, 0, len(shuffled))
+	for i := range shuffled {
+		giver := shuffled[i]
+		receiver := shuffled[(i+1)%len(shuffled)]
+		pairs = append(pairs, newMatchPair(i+1, []models.PoolUser{giver, receiver}, true))
+	}
+	return pairs
+}
+
+// newMatchPair 构建配对（分组）记录，前两名成员同时写入 User1/User2，只有一名成员时表示轮空
+func newMatchPair(pairNumber int, members []models.PoolUser, directed bool) models.MatchPair {
+	pair := models.MatchPair{
+		PairNumber: pairNumber,
+		Directed:   directed,
+		Size:       len(members),
+		Members:    make([]models.MatchGroupMember, len(members)),
+	}
 
-		// 解析用户数据
-		var user1Data, user2Data map[string]interface{}
-		json.Unmarshal(user1.UserData, &user1Data)
-		json.Unmarshal(user2.UserData, &user2Data)
+	for i, member := range members {
+		var userData map[string]interface{}
+		json.Unmarshal(member.UserData, &userData)
 
-		pair := models.MatchPair{
-			PairNumber:      pairNumber,
-			User1ID:         user1.ID,
-			User2ID:         &user2.ID,
-			User1Data:       user1.UserData,
-			User2Data:       user2.UserData,
-			ParsedUser1Data: user1Data,
-			ParsedUser2Data: user2Data,
+		pair.Members[i] = models.MatchGroupMember{
+			UserID:         member.ID,
+			Position:       i + 1,
+			UserData:       member.UserData,
+			ParsedUserData: userData,
 		}
 
-		pairs = append(pairs, pair)
-		pairNumber++
+		switch i {
+		case 0:
+			pair.User1ID = member.ID
+			pair.User1Data = member.UserData
+			pair.ParsedUser1Data = userData
+		case 1:
+			user2ID := member.ID
+			pair.User2ID = &user2ID
+			pair.User2Data = member.UserData
+			pair.ParsedUser2Data = userData
+		}
 	}
 
-	// 处理轮空用户
-	if len(shuffled)%2 == 1 {
-		loneUser := shuffled[len(shuffled)-1]
-		var userData map[string]interface{}
-		json.Unmarshal(loneUser.UserData, &userData)
+	return pair
+}
+
+// newMatchPairResult 将配对记录转换为响应格式
+func newMatchPairResult(pair models.MatchPair, displayName func(map[string]interface{}) string) models.MatchPairResult {
+	result := models.MatchPairResult{
+		Pair:      pair.PairNumber,
+		User1ID:   pair.User1ID,
+		User1:     displayName(pair.ParsedUser1Data),
+		User1Data: pair.ParsedUser1Data,
+		Directed:  pair.Directed,
+		Score:     pair.Score,
+	}
 
-		pair := models.MatchPair{
-			PairNumber:      pairNumber,
-			User1ID:         loneUser.ID,
-			User2ID:         nil,
-			User1Data:       loneUser.UserData,
-			User2Data:       nil,
-			ParsedUser1Data: userData,
-			ParsedUser2Data: nil,
+	if pair.ParsedUser2Data != nil {
+		result.User2ID = pair.User2ID
+		result.User2 = displayName(pair.ParsedUser2Data)
+		result.User2Data = pair.ParsedUser2Data
+		if pair.Directed {
+			result.Description = fmt.Sprintf("%s 送礼物给 %s", result.User1, result.User2)
 		}
+	}
 
-		pairs = append(pairs, pair)
+	for _, member := range pairMembers(pair) {
+		result.Members = append(result.Members, models.MatchMemberResult{
+			UserID: member.UserID,
+			Name:   displayName(member.ParsedUserData),
+			Data:   member.ParsedUserData,
+		})
 	}
 
-	log.Printf("🎲 使用真随机数完成用户匹配，总用户: %d，配对数: %d", len(users), len(pairs))
-	return pairs
+	return result
 }
 
 // getUserDisplayName 获取用户显示名称
@@ -431,30 +1642,24 @@ func (s *PoolService) getUserDisplayName(userData map[string]interface{}) string
 	return "匿名用户"
 }
 
-// getPoolStatus 获取匹配池状态
-func (s *PoolService) getPoolStatus(pool *models.MatchPool) string {
-	if pool.IsExpired() {
-		return "expired"
+// formatTimePtr 格式化可为空的时间
+func formatTimePtr(t *time.Time) *string {
+	if t == nil {
+		return nil
 	}
+	str := t.Format("2006-01-02 15:04:05")
+	return &str
+}
 
-	// 如果状态是matched，检查冷却时间
-	if pool.Status == "matched" {
-		// 如果没有lastMatchedAt记录，说明是旧数据，应该重置为active
-		if pool.LastMatchedAt == nil {
-			return "active"
-		}
-
-		cooldownDuration := time.Duration(pool.CooldownTime) * time.Second
-		timeElapsed := time.Since(*pool.LastMatchedAt)
-
-		// 如果冷却时间已过，应该返回active状态
-		if timeElapsed >= cooldownDuration {
-			return "active"
-		}
+// closedAt 返回匹配池的截止时间，因超过截止时间自动截止时为 ValidUntil
+func closedAt(pool *models.MatchPool) *time.Time {
+	if pool.ClosedAt == nil && pool.Status == models.PoolStatusOpen && pool.CurrentStatus() == models.PoolStatusClosed {
+		return &pool.ValidUntil
 	}
+	return pool.ClosedAt
+}
 
-	return pool.Status
-} // GetPoolUsers 获取匹配池用户列表（带缓存）
+// GetPoolUsers 获取匹配池用户列表（带缓存）
 func (s *PoolService) GetPoolUsers(poolID uint) ([]models.PoolUser, error) {
 	cacheKey := cache.GeneratePoolUsersKey(int(poolID))
 
@@ -486,23 +1691,3 @@ func (s *PoolService) SearchUserByContact(contactInfo string) ([]models.PoolUser
 
 	return users, err
 }
-
-// RemoveUser 移除用户
-func (s *PoolService) RemoveUser(userID uint) error {
-	var user models.PoolUser
-	if err := s.db.First(&user, userID).Error; err != nil {
-		return fmt.Errorf("用户不存在")
-	}
-
-	if err := s.db.Delete(&user).Error; err != nil {
-		return err
-	}
-
-	// 清除相关缓存
-	s.cacheService.Delete(cache.CacheKeyPools)
-	s.cacheService.Delete(cache.GeneratePoolKey(int(user.PoolID)))
-	s.cacheService.Delete(cache.GeneratePoolUsersKey(int(user.PoolID)))
-
-	log.Printf("✅ 移除用户成功: %d", userID)
-	return nil
-}
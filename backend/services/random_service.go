@@ -2,102 +2,280 @@ package services
 
 import (
 	"bytes"
+	"christmas-link-backend/models"
+	crand "crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"log"
 	"math/rand"
 	"net/http"
+	"os"
+	"strconv"
+	"sync"
 	"time"
 )
 
-// RandomService 随机数服务
+// RandomSource 随机数来源
+type RandomSource interface {
+	// Name 来源名称，记录在匹配记录中
+	Name() string
+	// UniformInts 生成 len(maxes) 个随机整数，第 k 个均匀分布在 [0, maxes[k]] 范围内
+	UniformInts(maxes []int) (*RandomDraw, error)
+}
+
+// RandomDraw 一次随机数抽取的结果及来源信息
+type RandomDraw struct {
+	Data       []int
+	Source     string // 实际使用的随机数来源
+	Serial     int64  // random.org 签名序列号；seeded 来源为所用种子
+	Signature  string // random.org 对 SignedData 的签名
+	SignedData string // random.org 签名覆盖的 random 对象原文，可用于 verifySignature
+}
+
+// RandomService 随机数服务，按匹配池设置或全局默认选择随机数来源
 type RandomService struct {
-	client   *http.Client
-	apiKey   string
-	fallback *rand.Rand
+	sources       map[string]RandomSource
+	defaultSource string
+}
+
+// NewRandomService 创建随机数服务实例
+// 全局默认来源由环境变量 RANDOM_SOURCE 指定（random_org、crypto 或 seeded，默认 random_org），
+// random.org 的 API Key 由 RANDOM_ORG_API_KEY 指定，seeded 来源的种子由 RANDOM_SEED 指定
+func NewRandomService() *RandomService {
+	rs := &RandomService{
+		sources: map[string]RandomSource{
+			models.RandomSourceRandomOrg: NewRandomOrgSource(getEnvOrDefault("RANDOM_ORG_API_KEY", "")),
+			models.RandomSourceCrypto:    NewCryptoSource(),
+			models.RandomSourceSeeded:    NewSeededSource(getEnvInt64OrDefault("RANDOM_SEED", time.Now().UnixNano())),
+		},
+		defaultSource: getEnvOrDefault("RANDOM_SOURCE", models.RandomSourceRandomOrg),
+	}
+
+	if _, ok := rs.sources[rs.defaultSource]; !ok {
+		log.Printf("⚠️ 未知的随机数来源 %s，使用 %s", rs.defaultSource, models.RandomSourceRandomOrg)
+		rs.defaultSource = models.RandomSourceRandomOrg
+	}
+	return rs
+}
+
+// IsValidRandomSource 判断随机数来源名称是否有效（空字符串表示使用全局默认来源）
+func IsValidRandomSource(name string) bool {
+	switch name {
+	case "", models.RandomSourceRandomOrg, models.RandomSourceCrypto, models.RandomSourceSeeded:
+		return true
+	}
+	return false
+}
+
+// GenerateRandomIntegers 从指定来源生成 count 个 [min, max] 范围内的随机整数，sourceName 为空时使用全局默认来源
+func (rs *RandomService) GenerateRandomIntegers(sourceName string, count, min, max int) (*RandomDraw, error) {
+	maxes := make([]int, count)
+	for i := range maxes {
+		maxes[i] = max - min
+	}
+
+	draw, err := rs.uniformInts(sourceName, maxes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range draw.Data {
+		draw.Data[i] += min
+	}
+	return draw, nil
+}
+
+// uniformInts 从指定来源按各位置的范围生成随机整数，并校验结果均落在范围内；
+// random.org 不可用时回退到 crypto/rand，返回结果中记录实际使用的来源
+func (rs *RandomService) uniformInts(sourceName string, maxes []int) (*RandomDraw, error) {
+	sourceName = rs.sourceName(sourceName)
+	source, ok := rs.sources[sourceName]
+	if !ok {
+		return nil, fmt.Errorf("未知的随机数来源: %s", sourceName)
+	}
+
+	draw, err := source.UniformInts(maxes)
+	if err == nil {
+		err = checkDrawRanges(draw, maxes)
+	}
+	if err == nil {
+		log.Printf("🎲 从 %s 获取 %d 个随机数", draw.Source, len(maxes))
+		return draw, nil
+	}
+	if sourceName != models.RandomSourceRandomOrg {
+		return nil, err
+	}
+
+	// random.org 失败时使用 crypto/rand 作为备选
+	log.Printf("⚠️ random.org 不可用，使用 crypto/rand: %v", err)
+	return rs.sources[models.RandomSourceCrypto].UniformInts(maxes)
+}
+
+// checkDrawRanges 校验随机数的数量和范围，防止外部来源返回异常数据
+func checkDrawRanges(draw *RandomDraw, maxes []int) error {
+	if len(draw.Data) != len(maxes) {
+		return fmt.Errorf("%s 返回的随机数数量不正确: %d", draw.Source, len(draw.Data))
+	}
+	for i, v := range draw.Data {
+		if v < 0 || v > maxes[i] {
+			return fmt.Errorf("%s 返回的第 %d 个随机数超出范围: %d", draw.Source, i+1, v)
+		}
+	}
+	return nil
+}
+
+// ShuffleSlice 使用 Fisher-Yates 算法打乱切片，返回本次使用的随机数抽取信息。
+// 第 i 步（i 从 n-1 递减到 1）所需的交换位置直接按 [0, i] 范围抽取，
+// 每个位置的随机数都是均匀的，因此 n! 种排列出现的概率完全相同（不做取模，避免取模偏差）
+func (rs *RandomService) ShuffleSlice(slice []interface{}, sourceName string) (*RandomDraw, error) {
+	n := len(slice)
+	if n <= 1 {
+		return &RandomDraw{Source: rs.sourceName(sourceName)}, nil
+	}
+
+	// 按位置获取交换下标：第 k 个随机数对应 i = n-1-k，范围为 [0, i]
+	maxes := make([]int, n-1)
+	for k := range maxes {
+		maxes[k] = n - 1 - k
+	}
+	draw, err := rs.uniformInts(sourceName, maxes)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, j := range draw.Data {
+		i := n - 1 - k
+		slice[i], slice[j] = slice[j], slice[i]
+	}
+
+	return draw, nil
+}
+
+// GenerateRandomOrder 使用全局默认来源生成随机排序的索引数组
+func (rs *RandomService) GenerateRandomOrder(length int) ([]int, error) {
+	if length <= 0 {
+		return []int{}, nil
+	}
+
+	// 创建有序索引数组
+	indices := make([]interface{}, length)
+	for i := 0; i < length; i++ {
+		indices[i] = i
+	}
+
+	// 打乱数组
+	if _, err := rs.ShuffleSlice(indices, ""); err != nil {
+		return nil, err
+	}
+
+	// 转换回 int 数组
+	result := make([]int, length)
+	for i, v := range indices {
+		result[i] = v.(int)
+	}
+
+	return result, nil
+}
+
+// sourceName 解析实际使用的来源名称
+func (rs *RandomService) sourceName(name string) string {
+	if name == "" {
+		return rs.defaultSource
+	}
+	return name
+}
+
+// RandomOrgSource random.org 签名随机数来源
+type RandomOrgSource struct {
+	client *http.Client
+	apiKey string
 }
 
 // RandomOrgResponse random.org API 响应结构
 type RandomOrgResponse struct {
 	Result struct {
-		Random struct {
-			Data           []int  `json:"data"`
-			CompletionTime string `json:"completionTime"`
-		} `json:"random"`
-		BitsUsed      int `json:"bitsUsed"`
-		BitsLeft      int `json:"bitsLeft"`
-		RequestsLeft  int `json:"requestsLeft"`
-		AdvisoryDelay int `json:"advisoryDelay"`
+		Random        json.RawMessage `json:"random"`
+		Signature     string          `json:"signature"`
+		BitsUsed      int             `json:"bitsUsed"`
+		BitsLeft      int             `json:"bitsLeft"`
+		RequestsLeft  int             `json:"requestsLeft"`
+		AdvisoryDelay int             `json:"advisoryDelay"`
 	} `json:"result"`
 	Error *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-		Data    string `json:"data"`
+		Code    int         `json:"code"`
+		Message string      `json:"message"`
+		Data    interface{} `json:"data"`
 	} `json:"error"`
 }
 
-// RandomOrgRequest random.org API 请求结构
+// RandomOrgRandom random.org 签名响应中的 random 对象
+type RandomOrgRandom struct {
+	Data           [][]int `json:"data"`
+	CompletionTime string  `json:"completionTime"`
+	SerialNumber   int64   `json:"serialNumber"`
+}
+
+// RandomOrgRequest random.org API 请求结构（generateSignedIntegerSequences）
 type RandomOrgRequest struct {
 	JSONRPC string `json:"jsonrpc"`
 	Method  string `json:"method"`
 	Params  struct {
-		APIKey                    string      `json:"apiKey"`
-		N                         int         `json:"n"`
-		Min                       int         `json:"min"`
-		Max                       int         `json:"max"`
-		Replacement               bool        `json:"replacement"`
-		Base                      int         `json:"base"`
-		PregeneratedRandomization interface{} `json:"pregeneratedRandomization"`
+		APIKey      string `json:"apiKey"`
+		N           int    `json:"n"`
+		Length      []int  `json:"length"`
+		Min         []int  `json:"min"`
+		Max         []int  `json:"max"`
+		Replacement bool   `json:"replacement"`
+		Base        int    `json:"base"`
 	} `json:"params"`
 	ID int `json:"id"`
 }
 
-// NewRandomService 创建随机数服务实例
-func NewRandomService() *RandomService {
-	return &RandomService{
+// NewRandomOrgSource 创建 random.org 随机数来源
+func NewRandomOrgSource(apiKey string) *RandomOrgSource {
+	return &RandomOrgSource{
 		client: &http.Client{
 			Timeout: 10 * time.Second,
 		},
-		apiKey:   "", // 可以从环境变量获取
-		fallback: rand.New(rand.NewSource(time.Now().UnixNano())),
+		apiKey: apiKey,
 	}
 }
 
-// GenerateRandomIntegers 生成随机整数序列
-func (rs *RandomService) GenerateRandomIntegers(count, min, max int) ([]int, error) {
-	// 先尝试使用 random.org
-	if integers, err := rs.fetchFromRandomOrg(count, min, max); err == nil {
-		log.Printf("🎲 从 random.org 获取 %d 个随机数", count)
-		return integers, nil
-	}
-
-	// 如果 random.org 失败，使用本地随机数生成器
-	log.Printf("⚠️ random.org 不可用，使用本地随机数生成器")
-	return rs.generateLocalRandom(count, min, max), nil
+// Name 来源名称
+func (s *RandomOrgSource) Name() string {
+	return models.RandomSourceRandomOrg
 }
 
-// fetchFromRandomOrg 从 random.org 获取随机数
-func (rs *RandomService) fetchFromRandomOrg(count, min, max int) ([]int, error) {
+// UniformInts 调用 random.org 的 generateSignedIntegerSequences，为每个位置请求一个长度为 1、
+// 范围为 [0, maxes[k]] 的序列，由 random.org 直接生成各位置的均匀随机数，返回随机数及其序列号和签名
+func (s *RandomOrgSource) UniformInts(maxes []int) (*RandomDraw, error) {
+	if s.apiKey == "" {
+		return nil, fmt.Errorf("未配置 RANDOM_ORG_API_KEY")
+	}
+
 	request := RandomOrgRequest{
 		JSONRPC: "2.0",
-		Method:  "generateIntegers",
+		Method:  "generateSignedIntegerSequences",
 		ID:      1,
 	}
 
-	request.Params.APIKey = rs.apiKey
-	request.Params.N = count
-	request.Params.Min = min
-	request.Params.Max = max
+	request.Params.APIKey = s.apiKey
+	request.Params.N = len(maxes)
+	request.Params.Length = make([]int, len(maxes))
+	request.Params.Min = make([]int, len(maxes))
+	request.Params.Max = maxes
 	request.Params.Replacement = true
 	request.Params.Base = 10
+	for i := range request.Params.Length {
+		request.Params.Length[i] = 1
+	}
 
 	jsonData, err := json.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("序列化请求失败: %v", err)
 	}
 
-	// 修复：使用 bytes.NewBuffer 创建 io.Reader
-	resp, err := rs.client.Post("https://api.random.org/json-rpc/2/invoke", "application/json",
+	resp, err := s.client.Post("https://api.random.org/json-rpc/4/invoke", "application/json",
 		bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("请求 random.org 失败: %v", err)
@@ -117,62 +295,132 @@ func (rs *RandomService) fetchFromRandomOrg(count, min, max int) ([]int, error)
 		return nil, fmt.Errorf("random.org 错误: %s", response.Error.Message)
 	}
 
-	return response.Result.Random.Data, nil
-}
+	var random RandomOrgRandom
+	if err := json.Unmarshal(response.Result.Random, &random); err != nil {
+		return nil, fmt.Errorf("解析 random.org 随机数失败: %v", err)
+	}
 
-// generateLocalRandom 生成本地随机数
-func (rs *RandomService) generateLocalRandom(count, min, max int) []int {
-	integers := make([]int, count)
-	for i := 0; i < count; i++ {
-		integers[i] = rs.fallback.Intn(max-min+1) + min
+	integers := make([]int, len(random.Data))
+	for i, sequence := range random.Data {
+		if len(sequence) != 1 {
+			return nil, fmt.Errorf("random.org 返回的序列长度不正确: %d", len(sequence))
+		}
+		integers[i] = sequence[0]
 	}
-	return integers
+
+	return &RandomDraw{
+		Data:       integers,
+		Source:     s.Name(),
+		Serial:     random.SerialNumber,
+		Signature:  response.Result.Signature,
+		SignedData: string(response.Result.Random),
+	}, nil
 }
 
-// ShuffleSlice 使用 Fisher-Yates 算法打乱切片
-func (rs *RandomService) ShuffleSlice(slice []interface{}) error {
-	n := len(slice)
-	if n <= 1 {
-		return nil
+// uniformIntn 使用拒绝采样从 64 位随机数流中得到 [0, n) 内的均匀随机整数：
+// 落在 n 的最大整数倍之外的取值被丢弃重抽，避免直接取模造成的偏差
+func uniformIntn(next func() (uint64, error), n int) (int, error) {
+	bound := uint64(n)
+	limit := ^uint64(0) - (^uint64(0) % bound)
+	for {
+		v, err := next()
+		if err != nil {
+			return 0, err
+		}
+		if v < limit {
+			return int(v % bound), nil
+		}
 	}
+}
 
-	// 获取随机索引序列
-	randomIndices, err := rs.GenerateRandomIntegers(n, 0, n-1)
-	if err != nil {
-		return err
+// CryptoSource 操作系统密码学安全随机数来源（crypto/rand）
+type CryptoSource struct{}
+
+// NewCryptoSource 创建 crypto/rand 随机数来源
+func NewCryptoSource() *CryptoSource {
+	return &CryptoSource{}
+}
+
+// Name 来源名称
+func (s *CryptoSource) Name() string {
+	return models.RandomSourceCrypto
+}
+
+// UniformInts 使用 crypto/rand 生成各位置的均匀随机整数
+func (s *CryptoSource) UniformInts(maxes []int) (*RandomDraw, error) {
+	next := func() (uint64, error) {
+		var buf [8]byte
+		if _, err := crand.Read(buf[:]); err != nil {
+			return 0, fmt.Errorf("crypto/rand 生成随机数失败: %v", err)
+		}
+		return binary.BigEndian.Uint64(buf[:]), nil
 	}
 
-	// 使用 Fisher-Yates 算法打乱
-	for i := n - 1; i > 0; i-- {
-		j := randomIndices[i] % (i + 1)
-		slice[i], slice[j] = slice[j], slice[i]
+	integers := make([]int, len(maxes))
+	for i, max := range maxes {
+		v, err := uniformIntn(next, max+1)
+		if err != nil {
+			return nil, err
+		}
+		integers[i] = v
 	}
+	return &RandomDraw{Data: integers, Source: s.Name()}, nil
+}
 
-	return nil
+// SeededSource 固定种子的确定性随机数来源，用于测试和复现
+type SeededSource struct {
+	mu   sync.Mutex
+	seed int64
+	rng  *rand.Rand
 }
 
-// GenerateRandomOrder 生成随机排序的索引数组
-func (rs *RandomService) GenerateRandomOrder(length int) ([]int, error) {
-	if length <= 0 {
-		return []int{}, nil
+// NewSeededSource 创建固定种子的随机数来源
+func NewSeededSource(seed int64) *SeededSource {
+	return &SeededSource{
+		seed: seed,
+		rng:  rand.New(rand.NewSource(seed)),
 	}
+}
 
-	// 创建有序索引数组
-	indices := make([]interface{}, length)
-	for i := 0; i < length; i++ {
-		indices[i] = i
+// Name 来源名称
+func (s *SeededSource) Name() string {
+	return models.RandomSourceSeeded
+}
+
+// UniformInts 从固定种子的伪随机数序列中依次取出各位置的均匀随机整数
+func (s *SeededSource) UniformInts(maxes []int) (*RandomDraw, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := func() (uint64, error) {
+		return s.rng.Uint64(), nil
 	}
 
-	// 打乱数组
-	if err := rs.ShuffleSlice(indices); err != nil {
-		return nil, err
+	integers := make([]int, len(maxes))
+	for i, max := range maxes {
+		v, err := uniformIntn(next, max+1)
+		if err != nil {
+			return nil, err
+		}
+		integers[i] = v
 	}
+	return &RandomDraw{Data: integers, Source: s.Name(), Serial: s.seed}, nil
+}
 
-	// 转换回 int 数组
-	result := make([]int, length)
-	for i, v := range indices {
-		result[i] = v.(int)
+// getEnvOrDefault 获取环境变量或默认值
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
 	}
+	return defaultValue
+}
 
-	return result, nil
+// getEnvInt64OrDefault 获取环境变量整数值或默认值
+func getEnvInt64OrDefault(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
 }
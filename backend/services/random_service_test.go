@@ -0,0 +1,142 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// newSeededRandomService 创建只使用固定种子来源的随机数服务，测试结果可复现
+func newSeededRandomService(seed int64) *RandomService {
+	return &RandomService{
+		sources: map[string]RandomSource{
+			models.RandomSourceSeeded: NewSeededSource(seed),
+		},
+		defaultSource: models.RandomSourceSeeded,
+	}
+}
+
+// chiSquareCritical 卡方分布在显著性水平 0.001 下的临界值（Wilson-Hilferty 近似）
+func chiSquareCritical(df int) float64 {
+	const z = 3.0902 // 标准正态分布的 0.999 分位数
+	k := float64(df)
+	t := 1 - 2/(9*k) + z*math.Sqrt(2/(9*k))
+	return k * t * t * t
+}
+
+// factorial 计算 n!
+func factorial(n int) int {
+	result := 1
+	for i := 2; i <= n; i++ {
+		result *= i
+	}
+	return result
+}
+
+// TestShuffleSliceUniform 对小规模切片多次打乱，统计 n! 种排列的出现次数并做卡方检验
+func TestShuffleSliceUniform(t *testing.T) {
+	for n := 3; n <= 5; n++ {
+		n := n
+		t.Run(fmt.Sprintf("n=%d", n), func(t *testing.T) {
+			rs := newSeededRandomService(int64(20231224 + n))
+			permutations := factorial(n)
+			trials := permutations * 500
+
+			counts := make(map[string]int, permutations)
+			for trial := 0; trial < trials; trial++ {
+				slice := make([]interface{}, n)
+				for i := range slice {
+					slice[i] = i
+				}
+				if _, err := rs.ShuffleSlice(slice, ""); err != nil {
+					t.Fatalf("打乱失败: %v", err)
+				}
+
+				key := make([]string, n)
+				for i, v := range slice {
+					key[i] = fmt.Sprint(v)
+				}
+				counts[strings.Join(key, ",")]++
+			}
+
+			if len(counts) != permutations {
+				t.Fatalf("出现了 %d 种排列，期望 %d 种", len(counts), permutations)
+			}
+
+			expected := float64(trials) / float64(permutations)
+			chiSquare := 0.0
+			for _, observed := range counts {
+				diff := float64(observed) - expected
+				chiSquare += diff * diff / expected
+			}
+			if critical := chiSquareCritical(permutations - 1); chiSquare > critical {
+				t.Errorf("卡方统计量 %.2f 超过临界值 %.2f（自由度 %d），排列分布不均匀", chiSquare, critical, permutations-1)
+			}
+		})
+	}
+}
+
+// TestShuffleSliceKeepsElements 打乱后元素不丢失、不重复
+func TestShuffleSliceKeepsElements(t *testing.T) {
+	rs := newSeededRandomService(1)
+	slice := []interface{}{"a", "b", "c", "d", "e", "f", "g"}
+	draw, err := rs.ShuffleSlice(slice, "")
+	if err != nil {
+		t.Fatalf("打乱失败: %v", err)
+	}
+	if draw.Source != models.RandomSourceSeeded || len(draw.Data) != len(slice)-1 {
+		t.Errorf("抽取信息不正确: 来源 %s，%d 个随机数", draw.Source, len(draw.Data))
+	}
+
+	seen := make(map[interface{}]bool)
+	for _, v := range slice {
+		seen[v] = true
+	}
+	if len(seen) != 7 {
+		t.Errorf("打乱后元素不完整: %v", slice)
+	}
+}
+
+// TestUniformIntnRejectsOutOfRange 落在 n 的最大整数倍之外的取值必须丢弃重抽，而不是取模
+func TestUniformIntnRejectsOutOfRange(t *testing.T) {
+	const n = 10
+	max := ^uint64(0)
+	limit := max - max%n
+
+	// [limit, max] 内的每个取值都应被丢弃，随后的 7 才是结果
+	for v := limit; ; v++ {
+		values := []uint64{v, 7}
+		calls := 0
+		next := func() (uint64, error) {
+			calls++
+			return values[calls-1], nil
+		}
+
+		got, err := uniformIntn(next, n)
+		if err != nil {
+			t.Fatalf("uniformIntn 返回错误: %v", err)
+		}
+		if got != 7 || calls != 2 {
+			t.Errorf("取值 %d 应被丢弃: 结果 %d，抽取 %d 次（取模结果为 %d）", v, got, calls, v%n)
+		}
+		if v == max {
+			break
+		}
+	}
+
+	// 最大整数倍以内的取值直接取模
+	next := func() (uint64, error) { return limit - 1, nil }
+	if got, _ := uniformIntn(next, n); got != int((limit-1)%n) {
+		t.Errorf("范围内的取值应直接使用: 结果 %d", got)
+	}
+}
+
+// TestUniformIntnPropagatesError 随机数流出错时返回错误
+func TestUniformIntnPropagatesError(t *testing.T) {
+	next := func() (uint64, error) { return 0, fmt.Errorf("来源不可用") }
+	if _, err := uniformIntn(next, 3); err == nil {
+		t.Error("随机数流出错时应返回错误")
+	}
+}
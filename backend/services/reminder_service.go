@@ -0,0 +1,480 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxReminderAttempts 提醒邮件的最大发送次数
+const maxReminderAttempts = 5
+
+// maxReminderHours 提醒最多提前的小时数（30天）
+const maxReminderHours = 24 * 30
+
+// encodeReminderList 将提醒设置中的列表保存为 JSON，空列表保存为空值
+func encodeReminderList(list interface{}) json.RawMessage {
+	switch v := list.(type) {
+	case []int:
+		if len(v) == 0 {
+			return nil
+		}
+	case []string:
+		if len(v) == 0 {
+			return nil
+		}
+	}
+	data, _ := json.Marshal(list)
+	return data
+}
+
+// validateReminderSettings 校验提醒设置：邮箱格式、提醒小时数在 1 到 maxReminderHours 之间且不重复，
+// 交换日期提醒需要设置交换日期
+func validateReminderSettings(pool *models.MatchPool) error {
+	if pool.OrganizerEmail != "" && !isEmailAddress(pool.OrganizerEmail) {
+		return fmt.Errorf("组织者邮箱格式错误: %s", pool.OrganizerEmail)
+	}
+	for _, email := range pool.MailingList() {
+		if !isEmailAddress(email) {
+			return fmt.Errorf("提醒邮件列表中的邮箱格式错误: %s", email)
+		}
+	}
+
+	checkHours := func(hours []int, name string) error {
+		seen := make(map[int]bool, len(hours))
+		for _, h := range hours {
+			if h < 1 || h > maxReminderHours {
+				return fmt.Errorf("%s必须在 1 到 %d 小时之间", name, maxReminderHours)
+			}
+			if seen[h] {
+				return fmt.Errorf("%s重复: %d 小时", name, h)
+			}
+			seen[h] = true
+		}
+		return nil
+	}
+	if err := checkHours(pool.CloseReminders(), "截止提醒时间"); err != nil {
+		return err
+	}
+	if err := checkHours(pool.ExchangeReminders(), "交换日期提醒时间"); err != nil {
+		return err
+	}
+	if len(pool.ExchangeReminders()) > 0 && pool.ExchangeAt == nil {
+		return fmt.Errorf("设置交换日期提醒需要先设置交换日期 exchangeAt")
+	}
+	return nil
+}
+
+// scheduleReminders 根据匹配池的提醒设置登记、调整或取消提醒（在保存匹配池的事务中调用）：
+// 尚未发出的提醒按当前的截止时间和交换日期重新计算，登记时提醒时间已过的标记为跳过；
+// 已发出的提醒在目标时间变更后重新登记，目标时间不变时不会重复发出
+func scheduleReminders(tx *gorm.DB, pool *models.MatchPool) error {
+	var existing []models.PoolReminder
+	if err := tx.Where("pool_id = ?", pool.ID).Find(&existing).Error; err != nil {
+		return err
+	}
+	byKey := make(map[string]*models.PoolReminder, len(existing))
+	for i := range existing {
+		byKey[fmt.Sprintf("%s:%d", existing[i].Kind, existing[i].Hours)] = &existing[i]
+	}
+
+	type target struct {
+		kind  string
+		at    time.Time
+		hours []int
+	}
+	targets := []target{{models.ReminderKindClose, pool.ValidUntil, pool.CloseReminders()}}
+	if pool.ExchangeAt != nil {
+		targets = append(targets, target{models.ReminderKindExchange, *pool.ExchangeAt, pool.ExchangeReminders()})
+	}
+
+	now := time.Now()
+	wanted := make(map[string]bool)
+	for _, t := range targets {
+		for _, hours := range t.hours {
+			key := fmt.Sprintf("%s:%d", t.kind, hours)
+			wanted[key] = true
+
+			runAt := t.at.Add(-time.Duration(hours) * time.Hour)
+			status, note := models.ReminderStatusPending, ""
+			if !runAt.After(now) {
+				status, note = models.ReminderStatusSkipped, "登记时提醒时间已过"
+			}
+
+			reminder, ok := byKey[key]
+			if !ok {
+				if err := tx.Create(&models.PoolReminder{
+					PoolID:   pool.ID,
+					Kind:     t.kind,
+					Hours:    hours,
+					TargetAt: t.at,
+					RunAt:    runAt,
+					Status:   status,
+					Note:     note,
+				}).Error; err != nil {
+					return err
+				}
+				continue
+			}
+
+			// 目标时间未变时保持原状：已发出的不再重复发出，已跳过的不会因为重新保存而补发
+			if reminder.TargetAt.Unix() == t.at.Unix() {
+				continue
+			}
+			if err := tx.Model(reminder).Updates(map[string]interface{}{
+				"target_at":  t.at,
+				"run_at":     runAt,
+				"status":     status,
+				"note":       note,
+				"recipients": 0,
+				"fired_at":   nil,
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	// 取消不再需要的提醒，已发出的保留作为记录
+	for key, reminder := range byKey {
+		if wanted[key] || reminder.Status == models.ReminderStatusFired {
+			continue
+		}
+		if err := tx.Delete(reminder).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReminderService 提醒服务
+// 提醒时间点保存在数据库中，到期后在一个事务内标记为已发出并生成每位收件人的邮件，服务重启不会丢失或重复；
+// 邮件由后台循环发送，失败后按尝试次数延后重试，各实例通过条件更新领取
+type ReminderService struct {
+	db         *gorm.DB
+	mailSender MailSender
+	outbox     *outbox
+	interval   time.Duration
+}
+
+// NewReminderService 创建提醒服务实例，轮询间隔由环境变量 REMINDER_INTERVAL（秒）指定，默认15秒
+func NewReminderService(db *gorm.DB) *ReminderService {
+	return &ReminderService{
+		db:         db,
+		mailSender: NewMailSender(),
+		outbox: newOutbox(db, &models.ReminderDelivery{},
+			models.NotificationStatusPending, models.NotificationStatusSending),
+		interval: time.Duration(getEnvInt64OrDefault("REMINDER_INTERVAL", 15)) * time.Second,
+	}
+}
+
+// Start 在后台启动提醒循环
+func (s *ReminderService) Start() {
+	log.Printf("🔔 提醒服务已启动，实例: %s，轮询间隔: %v，发送方式: %s", s.outbox.instanceID, s.interval, s.mailSender.Name())
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.fireDueReminders()
+			s.DeliverDue()
+			<-ticker.C
+		}
+	}()
+}
+
+// fireDueReminders 处理所有到达提醒时间的提醒
+func (s *ReminderService) fireDueReminders() {
+	var reminders []models.PoolReminder
+	if err := s.db.Where("status = ? AND run_at <= ?", models.ReminderStatusPending, time.Now()).
+		Order("run_at").
+		Find(&reminders).Error; err != nil {
+		log.Printf("⚠️ 查询到期的提醒失败: %v", err)
+		return
+	}
+
+	for i := range reminders {
+		if err := s.fire(&reminders[i]); err != nil {
+			log.Printf("⚠️ 处理提醒 %d 失败，稍后重试: %v", reminders[i].ID, err)
+		}
+	}
+}
+
+// fire 在一个事务内领取提醒并生成邮件：以 pending 状态为条件更新，只有一个实例能领取成功；
+// 不再需要提醒时（匹配池已截止、尚未匹配、没有收件人等）标记为跳过
+func (s *ReminderService) fire(reminder *models.PoolReminder) error {
+	now := time.Now()
+	var messages []reminderMessage
+	var skipReason string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.PoolReminder{}).
+			Where("id = ? AND status = ?", reminder.ID, models.ReminderStatusPending).
+			Updates(map[string]interface{}{
+				"status":   models.ReminderStatusFired,
+				"fired_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			return nil
+		}
+
+		var pool models.MatchPool
+		if err := tx.Preload("Fields").First(&pool, reminder.PoolID).Error; err != nil {
+			skipReason = "匹配池已不存在"
+		} else if reminder.Kind == models.ReminderKindClose {
+			messages, skipReason = s.closeMessages(tx, &pool, reminder)
+		} else {
+			messages, skipReason = s.exchangeMessages(tx, &pool, reminder)
+		}
+		if skipReason == "" && len(messages) == 0 {
+			skipReason = "没有可用的收件邮箱"
+		}
+		if skipReason != "" {
+			return tx.Model(&models.PoolReminder{}).Where("id = ?", reminder.ID).Updates(map[string]interface{}{
+				"status": models.ReminderStatusSkipped,
+				"note":   skipReason,
+			}).Error
+		}
+
+		for _, msg := range messages {
+			if err := tx.Create(&models.ReminderDelivery{
+				ReminderID:    reminder.ID,
+				PoolID:        reminder.PoolID,
+				UserID:        msg.userID,
+				Email:         msg.To,
+				Subject:       msg.Subject,
+				Body:          msg.Body,
+				Status:        models.NotificationStatusPending,
+				NextAttemptAt: now,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.PoolReminder{}).Where("id = ?", reminder.ID).
+			Update("recipients", len(messages)).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if skipReason != "" {
+		log.Printf("⏭️ 跳过提醒 %d: Pool %d，%s", reminder.ID, reminder.PoolID, skipReason)
+	} else if len(messages) > 0 {
+		log.Printf("🔔 提醒 %d 已触发: Pool %d，%s 前 %d 小时，%d 封邮件", reminder.ID, reminder.PoolID, reminder.Kind, reminder.Hours, len(messages))
+	}
+	return nil
+}
+
+// reminderMessage 待保存的提醒邮件
+type reminderMessage struct {
+	MailMessage
+	userID *uint
+}
+
+// closeMessages 生成截止报名提醒：发给组织者和邮件列表，附带当前人数
+func (s *ReminderService) closeMessages(tx *gorm.DB, pool *models.MatchPool, reminder *models.PoolReminder) ([]reminderMessage, string) {
+	if pool.CurrentStatus() != models.PoolStatusOpen {
+		return nil, fmt.Sprintf("匹配池状态为 %s，不在报名中", pool.CurrentStatus())
+	}
+
+	var recipients []string
+	seen := make(map[string]bool)
+	for _, email := range append([]string{pool.OrganizerEmail}, pool.MailingList()...) {
+		key := strings.ToLower(email)
+		if email == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		recipients = append(recipients, email)
+	}
+
+	body := fmt.Sprintf("你好！\n\n匹配池「%s」将于 %s 截止报名（还有约 %d 小时）。\n\n当前正式参与者：%d 人\n",
+		pool.Name, pool.ValidUntil.Format("2006-01-02 15:04"), reminder.Hours, pool.GetUserCount(tx))
+	if count := pool.GetWaitlistCount(tx); count > 0 {
+		body += fmt.Sprintf("候补名单：%d 人\n", count)
+	}
+	if count := pool.GetPendingCount(tx); count > 0 {
+		body += fmt.Sprintf("待验证邮箱：%d 人\n", count)
+	}
+	body += "\n还没有报名的朋友请抓紧时间，截止后将无法加入。🎄\n"
+
+	messages := make([]reminderMessage, 0, len(recipients))
+	for _, email := range recipients {
+		messages = append(messages, reminderMessage{MailMessage: MailMessage{
+			To:      email,
+			Subject: fmt.Sprintf("【%s】还有 %d 小时截止报名", pool.Name, reminder.Hours),
+			Body:    body,
+		}})
+	}
+	return messages, ""
+}
+
+// exchangeMessages 生成交换日期提醒：发给最近一轮匹配的每位参与者，附带其匹配对象的显示名称（按可见性隐藏后生成）
+func (s *ReminderService) exchangeMessages(tx *gorm.DB, pool *models.MatchPool, reminder *models.PoolReminder) ([]reminderMessage, string) {
+	if pool.CurrentStatus() == models.PoolStatusArchived {
+		return nil, "匹配池已归档"
+	}
+
+	var record models.MatchRecord
+	if err := tx.Where("pool_id = ?", pool.ID).Order("id DESC").First(&record).Error; err != nil {
+		return nil, "匹配池尚未匹配"
+	}
+
+	var pairs []models.MatchPair
+	if err := tx.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("position")
+	}).Where("record_id = ?", record.ID).Order("pair_number").Find(&pairs).Error; err != nil {
+		return nil, "读取匹配结果失败"
+	}
+
+	visibilityService := NewVisibilityService(tx)
+	exchangeAt := reminder.TargetAt.Format("2006-01-02 15:04")
+	var messages []reminderMessage
+	for _, pair := range pairs {
+		for _, member := range pairMembers(pair) {
+			// 交换礼物模式下每位参与者都是某一组的送礼人，只在该组提醒，避免重复
+			if pair.Directed && member.UserID != pair.User1ID {
+				continue
+			}
+
+			var user models.PoolUser
+			if err := tx.First(&user, member.UserID).Error; err != nil {
+				continue
+			}
+			email := participantEmail(pool, &user)
+			if email == "" {
+				continue
+			}
+
+			redacted, err := visibilityService.Redact(&models.MatchResult{
+				RecordID: record.ID,
+				PoolID:   pool.ID,
+				Pairs:    []models.MatchPairResult{newMatchPairResult(pair, userDisplayName)},
+			}, Viewer{UserID: user.ID})
+			if err != nil {
+				continue
+			}
+
+			var partners []string
+			for _, m := range redacted.Pairs[0].Members {
+				if m.UserID == user.ID {
+					continue
+				}
+				partners = append(partners, m.Name)
+			}
+
+			body := fmt.Sprintf("%s，你好！\n\n匹配池「%s」的交换日期是 %s（还有约 %d 小时）。\n",
+				userDisplayName(user.ParsedUserData), pool.Name, exchangeAt, reminder.Hours)
+			switch {
+			case pair.Directed && len(partners) > 0:
+				body += fmt.Sprintf("别忘了为 %s 准备礼物！\n", strings.Join(partners, "、"))
+			case len(partners) > 0:
+				body += fmt.Sprintf("你的匹配对象是：%s\n", strings.Join(partners, "、"))
+			default:
+				body += "本轮你没有匹配对象（轮空），欢迎一起参加交换。\n"
+			}
+			body += "\n祝你圣诞快乐！🎄\n"
+
+			userID := user.ID
+			messages = append(messages, reminderMessage{
+				MailMessage: MailMessage{
+					To:      email,
+					Subject: fmt.Sprintf("【%s】还有 %d 小时到交换日期", pool.Name, reminder.Hours),
+					Body:    body,
+				},
+				userID: &userID,
+			})
+		}
+	}
+	return messages, ""
+}
+
+// DeliverDue 发送所有到期的提醒邮件，包括锁已过期（领取实例可能已崩溃）的邮件
+func (s *ReminderService) DeliverDue() {
+	var deliveries []models.ReminderDelivery
+	if err := s.outbox.due(s.db, &deliveries); err != nil {
+		log.Printf("⚠️ 查询待发送的提醒邮件失败: %v", err)
+		return
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		if s.outbox.claim(delivery.ID, delivery.Status, &delivery.Attempts) {
+			s.deliver(delivery)
+		}
+	}
+}
+
+// deliver 发送提醒邮件
+func (s *ReminderService) deliver(delivery *models.ReminderDelivery) {
+	err := s.mailSender.Send(&MailMessage{
+		To:      delivery.Email,
+		Subject: delivery.Subject,
+		Body:    delivery.Body,
+	})
+	if err == nil {
+		s.finish(delivery, models.NotificationStatusSent, "")
+		return
+	}
+	if delivery.Attempts < maxReminderAttempts {
+		s.retry(delivery, err.Error())
+	} else {
+		s.finish(delivery, models.NotificationStatusFailed, err.Error())
+	}
+}
+
+// retry 释放提醒邮件并在稍后重试，等待时间随尝试次数增加
+func (s *ReminderService) retry(delivery *models.ReminderDelivery, lastError string) {
+	nextAttemptAt := time.Now().Add(time.Duration(delivery.Attempts) * time.Minute)
+	s.outbox.retry(delivery.ID, nextAttemptAt, lastError, nil)
+	log.Printf("⚠️ 提醒邮件 %d 发送失败，将于 %s 重试: %s", delivery.ID, nextAttemptAt.Format("2006-01-02 15:04:05"), lastError)
+}
+
+// finish 记录提醒邮件的最终结果
+func (s *ReminderService) finish(delivery *models.ReminderDelivery, status string, lastError string) {
+	var extra map[string]interface{}
+	if status == models.NotificationStatusSent {
+		extra = map[string]interface{}{"sent_at": time.Now()}
+	}
+	s.outbox.finish(delivery.ID, status, lastError, extra)
+
+	if status == models.NotificationStatusSent {
+		log.Printf("🔔 提醒邮件 %d 已发送: %s", delivery.ID, delivery.Email)
+	} else {
+		log.Printf("❌ 提醒邮件 %d 发送失败: %s，%s", delivery.ID, delivery.Email, lastError)
+	}
+}
+
+// GetPoolReminders 获取匹配池的提醒设置、提醒时间点和最近的提醒邮件
+func (s *ReminderService) GetPoolReminders(poolID uint) (*models.PoolRemindersResponse, error) {
+	var pool models.MatchPool
+	if err := s.db.First(&pool, poolID).Error; err != nil {
+		return nil, fmt.Errorf("匹配池不存在")
+	}
+
+	response := &models.PoolRemindersResponse{
+		PoolID:                pool.ID,
+		OrganizerEmail:        pool.OrganizerEmail,
+		ReminderMailingList:   pool.MailingList(),
+		CloseReminderHours:    pool.CloseReminders(),
+		ExchangeAt:            formatTimePtr(pool.ExchangeAt),
+		ExchangeReminderHours: pool.ExchangeReminders(),
+		Reminders:             []models.PoolReminder{},
+		Deliveries:            []models.ReminderDelivery{},
+	}
+	if err := s.db.Where("pool_id = ?", poolID).Order("run_at").Find(&response.Reminders).Error; err != nil {
+		return nil, err
+	}
+	if err := s.db.Where("pool_id = ?", poolID).Order("id DESC").Limit(200).Find(&response.Deliveries).Error; err != nil {
+		return nil, err
+	}
+	return response, nil
+}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"christmas-link-backend/cache"
+	"christmas-link-backend/models"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// RepairService 匹配记录修复服务：参与者在匹配后退出时只对受影响的人重新配对
+type RepairService struct {
+	db           *gorm.DB
+	cacheService *cache.CacheService
+	webhooks     *WebhookService
+}
+
+// NewRepairService 创建匹配记录修复服务实例
+func NewRepairService(db *gorm.DB) *RepairService {
+	return &RepairService{
+		db:           db,
+		cacheService: cache.NewCacheService(),
+		webhooks:     NewWebhookService(db),
+	}
+}
+
+// errRecordRevisionChanged 修复期间匹配记录已被其他修复请求修改
+var errRecordRevisionChanged = errors.New("匹配记录已被其他修复请求修改，请刷新后重试")
+
+// matchRepair 一次修复的计算结果
+type matchRepair struct {
+	removed []models.MatchPair // 被拆除的配对
+	added   []models.MatchPair // 新增的配对
+}
+
+// RepairRecord 从匹配记录中移除退出的参与者并局部重新配对：
+// 两两/分组模式下，其同组成员保留为较小的分组，只剩一人时与已有的轮空用户配对（不违反排除约束时）；
+// 交换礼物模式下，送礼给他的人改为送给他原本的收礼人，环中其他人不受影响。
+// 修复作为匹配记录的新修订保存，并记录配对变更；退出的参与者同时从匹配池中移除
+func (s *RepairService) RepairRecord(recordID uint, req *models.RepairMatchRequest) (*models.MatchRevision, error) {
+	var record models.MatchRecord
+	var pool models.MatchPool
+	var repair *matchRepair
+	var revision *models.MatchRevision
+	userRemoved := false
+
+	// 配对在事务内读取和计算，并以修订号作为版本条件更新匹配记录，
+	// 同一记录的并发修复只有一个能成功，另一个不会拆除已被重新配对的组
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Preload("Pairs", func(db *gorm.DB) *gorm.DB {
+			return db.Order("pair_number")
+		}).Preload("Pairs.Members", func(db *gorm.DB) *gorm.DB {
+			return db.Order("position")
+		}).First(&record, recordID).Error; err != nil {
+			return fmt.Errorf("匹配记录不存在")
+		}
+
+		if err := tx.Preload("Fields").First(&pool, record.PoolID).Error; err != nil {
+			return fmt.Errorf("匹配池不存在")
+		}
+		if pool.CurrentStatus() == models.PoolStatusArchived {
+			return fmt.Errorf("匹配池已归档，不能修复匹配记录")
+		}
+
+		var constraints []models.PoolConstraint
+		if err := tx.Where("pool_id = ?", record.PoolID).Find(&constraints).Error; err != nil {
+			return err
+		}
+
+		var removedMember *models.MatchGroupMember
+		for _, pair := range record.Pairs {
+			for _, member := range pairMembers(pair) {
+				if member.UserID == req.UserID {
+					m := member
+					removedMember = &m
+				}
+			}
+		}
+		if removedMember == nil {
+			return fmt.Errorf("该用户不在此匹配记录中")
+		}
+
+		var err error
+		if record.MatchMode == models.MatchModeGift {
+			repair, err = repairGiftCycle(record.Pairs, req.UserID, constraints)
+		} else {
+			repair, err = repairGroups(record.Pairs, req.UserID, constraints)
+		}
+		if err != nil {
+			return err
+		}
+
+		// score 模式下重新计算新配对的分数
+		if record.MatchMode == models.MatchModeScore {
+			scorer := newPairScorer(pool.Fields)
+			for i := range repair.added {
+				members := repair.added[i].Members
+				if len(members) == 2 {
+					a, b := memberUser(members[0]), memberUser(members[1])
+					repair.added[i].Score = scorer.score(&a, &b)
+				}
+			}
+		}
+
+		diff := make([]models.PairChange, 0, len(repair.removed)+len(repair.added))
+		for _, pair := range repair.removed {
+			diff = append(diff, newPairChange(models.PairChangeRemoved, pair, userDisplayName))
+		}
+		for _, pair := range repair.added {
+			diff = append(diff, newPairChange(models.PairChangeAdded, pair, userDisplayName))
+		}
+		diffData, err := json.Marshal(diff)
+		if err != nil {
+			return fmt.Errorf("序列化配对变更失败: %v", err)
+		}
+
+		revision = &models.MatchRevision{
+			RecordID:        record.ID,
+			Revision:        record.Revision + 1,
+			RemovedUserID:   req.UserID,
+			RemovedUserData: removedMember.UserData,
+			Note:            req.Note,
+			Diff:            diffData,
+
+			ParsedRemovedUserData: removedMember.ParsedUserData,
+			ParsedDiff:            diff,
+		}
+
+		// 先以读取时的修订号为条件占用新的修订号，记录已被其他修复修改时放弃
+		result := tx.Model(&models.MatchRecord{}).
+			Where("id = ? AND revision = ?", record.ID, record.Revision).
+			Update("revision", revision.Revision)
+		if result.Error != nil {
+			return fmt.Errorf("保存修复结果失败: %v", result.Error)
+		}
+		if result.RowsAffected != 1 {
+			return errRecordRevisionChanged
+		}
+
+		removed, err := s.saveRepair(tx, &record, &pool, repair, revision)
+		if err != nil {
+			return fmt.Errorf("保存修复结果失败: %v", err)
+		}
+		userRemoved = removed
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 清除相关缓存
+	s.cacheService.Delete(cache.CacheKeyPools)
+	s.cacheService.Delete(cache.CacheKeyHistory)
+	s.cacheService.Delete(cache.CacheKeyStats)
+	s.cacheService.Delete(cache.GeneratePoolKey(int(record.PoolID)))
+	s.cacheService.Delete(cache.GeneratePoolUsersKey(int(record.PoolID)))
+	s.cacheService.Delete(cache.GeneratePoolStatsKey(int(record.PoolID)))
+	s.cacheService.Delete(cache.GenerateHistoryKey(int(record.ID)))
+
+	if userRemoved {
+		publishParticipantEvent(s.db, models.PoolEventParticipantLeft, record.PoolID, req.UserID, false)
+		s.webhooks.Emit(models.WebhookEventParticipantRemoved, record.PoolID, map[string]interface{}{
+			"userId":   req.UserID,
+			"recordId": record.ID,
+			"revision": revision.Revision,
+		})
+	}
+
+	log.Printf("🩹 修复匹配记录 %d（修订 %d）: 移除用户 %d，拆除 %d 组，新增 %d 组",
+		record.ID, revision.Revision, req.UserID, len(repair.removed), len(repair.added))
+	return revision, nil
+}
+
+// saveRepair 在修复事务中拆除和创建配对、更新匹配记录的统计并保存修订，
+// 退出的参与者同时从匹配池中移除，返回是否移除了参与者
+func (s *RepairService) saveRepair(tx *gorm.DB, record *models.MatchRecord, pool *models.MatchPool, repair *matchRepair, revision *models.MatchRevision) (bool, error) {
+	// 拆除受影响的配对
+	for _, pair := range repair.removed {
+		if err := tx.Where("pair_id = ?", pair.ID).Delete(&models.MatchGroupMember{}).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Delete(&models.MatchPair{}, pair.ID).Error; err != nil {
+			return false, err
+		}
+		if err := tx.Where("pair_id = ?", pair.ID).Delete(&models.MatchNotification{}).Error; err != nil {
+			return false, err
+		}
+	}
+
+	// 创建新的配对
+	for i := range repair.added {
+		repair.added[i].RecordID = record.ID
+		if err := tx.Create(&repair.added[i]).Error; err != nil {
+			return false, err
+		}
+		// 通知新配对的成员
+		if pool.NotifyMatches {
+			if err := enqueuePairNotifications(tx, &repair.added[i]); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	// 重新统计匹配记录
+	var pairs []models.MatchPair
+	if err := tx.Where("record_id = ?", record.ID).Find(&pairs).Error; err != nil {
+		return false, err
+	}
+	hasLoneUser := false
+	totalScore := 0.0
+	for _, pair := range pairs {
+		if pair.User2ID == nil {
+			hasLoneUser = true
+		}
+		totalScore += pair.Score
+	}
+	if err := tx.Model(&models.MatchRecord{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"total_users":   record.TotalUsers - 1,
+		"pairs_count":   len(pairs),
+		"has_lone_user": hasLoneUser,
+		"total_score":   totalScore,
+	}).Error; err != nil {
+		return false, err
+	}
+
+	if err := tx.Create(revision).Error; err != nil {
+		return false, err
+	}
+
+	// 退出的参与者同时从匹配池中移除
+	result := tx.Where("pool_id = ?", record.PoolID).Delete(&models.PoolUser{}, revision.RemovedUserID)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	if err := deleteUserConstraints(tx, revision.RemovedUserID); err != nil {
+		return false, err
+	}
+	// 空出的名额由候补用户递补，参加下一轮匹配
+	_, err := promoteWaitlisted(tx, pool)
+	return true, err
+}
+
+// GetRevisions 获取匹配记录的全部修订
+func (s *RepairService) GetRevisions(recordID uint) ([]models.MatchRevision, error) {
+	var record models.MatchRecord
+	if err := s.db.First(&record, recordID).Error; err != nil {
+		return nil, fmt.Errorf("匹配记录不存在")
+	}
+
+	revisions := []models.MatchRevision{}
+	if err := s.db.Where("record_id = ?", recordID).Order("revision").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// repairGroups 两两/分组模式的修复
+func repairGroups(pairs []models.MatchPair, userID uint, constraints []models.PoolConstraint) (*matchRepair, error) {
+	repair := &matchRepair{}
+
+	var affected *models.MatchPair
+	for i := range pairs {
+		for _, member := range pairMembers(pairs[i]) {
+			if member.UserID == userID {
+				affected = &pairs[i]
+			}
+		}
+	}
+
+	var remaining []models.MatchGroupMember
+	for _, member := range pairMembers(*affected) {
+		if member.UserID != userID {
+			remaining = append(remaining, member)
+		}
+	}
+	repair.removed = append(repair.removed, *affected)
+
+	if len(remaining) != 1 {
+		// 同组仍有两人以上时保留为较小的分组；退出者原本轮空时直接移除
+		if len(remaining) > 1 {
+			repair.added = append(repair.added, newRepairedPair(affected.PairNumber, remaining, false))
+		}
+		return repair, nil
+	}
+
+	// 同组只剩一人时，优先与已有的轮空用户配对
+	partner := remaining[0]
+	for i := range pairs {
+		lone := &pairs[i]
+		if lone.ID == affected.ID || len(pairMembers(*lone)) != 1 {
+			continue
+		}
+		loneMember := pairMembers(*lone)[0]
+		if ok, _ := membersAllowed(partner, loneMember, constraints); ok {
+			repair.removed = append(repair.removed, *lone)
+			repair.added = append(repair.added, newRepairedPair(affected.PairNumber, []models.MatchGroupMember{partner, loneMember}, false))
+			return repair, nil
+		}
+	}
+
+	repair.added = append(repair.added, newRepairedPair(affected.PairNumber, remaining, false))
+	return repair, nil
+}
+
+// repairGiftCycle 交换礼物模式的修复：giver → 退出者 → receiver 改为 giver → receiver
+func repairGiftCycle(pairs []models.MatchPair, userID uint, constraints []models.PoolConstraint) (*matchRepair, error) {
+	repair := &matchRepair{}
+
+	var incoming, outgoing *models.MatchPair
+	for i := range pairs {
+		members := pairMembers(pairs[i])
+		if len(members) != 2 {
+			continue
+		}
+		if members[1].UserID == userID {
+			incoming = &pairs[i]
+		}
+		if members[0].UserID == userID {
+			outgoing = &pairs[i]
+		}
+	}
+	if incoming == nil || outgoing == nil {
+		return nil, fmt.Errorf("匹配记录中找不到该用户的送礼关系")
+	}
+
+	giver := pairMembers(*incoming)[0]
+	receiver := pairMembers(*outgoing)[1]
+	repair.removed = append(repair.removed, *incoming, *outgoing)
+
+	if giver.UserID == receiver.UserID {
+		// 只有两人互送时，剩下的一人无人可送
+		repair.added = append(repair.added, newRepairedPair(incoming.PairNumber, []models.MatchGroupMember{giver}, false))
+		return repair, nil
+	}
+
+	if ok, reason := membersAllowed(giver, receiver, constraints); !ok {
+		return nil, fmt.Errorf("修复后送礼关系违反排除约束（%s），请重新匹配", reason)
+	}
+
+	repair.added = append(repair.added, newRepairedPair(incoming.PairNumber, []models.MatchGroupMember{giver, receiver}, true))
+	return repair, nil
+}
+
+// membersAllowed 检查两名成员能否配对，不能配对时返回违反的约束说明
+func membersAllowed(a, b models.MatchGroupMember, constraints []models.PoolConstraint) (bool, string) {
+	g := newMatchGraph([]models.PoolUser{memberUser(a), memberUser(b)}, constraints, nil, func(map[string]interface{}) string { return "" })
+	if g.allowed(0, 1, -1) {
+		return true, ""
+	}
+	return false, g.describeConstraint(g.blocked[0][1][0])
+}
+
+// memberUser 将分组成员还原为用户，用于约束检查和打分
+func memberUser(member models.MatchGroupMember) models.PoolUser {
+	return models.PoolUser{
+		ID:             member.UserID,
+		UserData:       member.UserData,
+		ParsedUserData: member.ParsedUserData,
+	}
+}
+
+// newRepairedPair 用已有的分组成员构建新的配对记录
+func newRepairedPair(pairNumber int, members []models.MatchGroupMember, directed bool) models.MatchPair {
+	users := make([]models.PoolUser, len(members))
+	for i, member := range members {
+		users[i] = memberUser(member)
+	}
+	return newMatchPair(pairNumber, users, directed)
+}
+
+// newPairChange 构建配对变更描述
+func newPairChange(change string, pair models.MatchPair, displayName func(map[string]interface{}) string) models.PairChange {
+	pc := models.PairChange{
+		Change:   change,
+		Pair:     pair.PairNumber,
+		Directed: pair.Directed,
+	}
+	for _, member := range pairMembers(pair) {
+		pc.UserIDs = append(pc.UserIDs, member.UserID)
+		pc.Names = append(pc.Names, displayName(member.ParsedUserData))
+	}
+	return pc
+}
+
+// originalPairing 按修订记录倒序撤销配对变更，还原匹配记录最初的配对（按配对序号排列）
+func originalPairing(pairs []models.MatchPair, revisions []models.MatchRevision) []models.MatchPair {
+	byNumber := make(map[int]models.MatchPair, len(pairs))
+	for _, pair := range pairs {
+		byNumber[pair.PairNumber] = pair
+	}
+
+	for i := len(revisions) - 1; i >= 0; i-- {
+		changes := revisions[i].ParsedDiff
+		for _, change := range changes {
+			if change.Change == models.PairChangeAdded {
+				delete(byNumber, change.Pair)
+			}
+		}
+		for _, change := range changes {
+			if change.Change != models.PairChangeRemoved {
+				continue
+			}
+			pair := models.MatchPair{PairNumber: change.Pair, Directed: change.Directed, Size: len(change.UserIDs)}
+			for j, userID := range change.UserIDs {
+				pair.Members = append(pair.Members, models.MatchGroupMember{UserID: userID, Position: j + 1})
+			}
+			byNumber[change.Pair] = pair
+		}
+	}
+
+	original := make([]models.MatchPair, 0, len(byNumber))
+	for _, pair := range byNumber {
+		original = append(original, pair)
+	}
+	sort.Slice(original, func(i, j int) bool { return original[i].PairNumber < original[j].PairNumber })
+	return original
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxJobAttempts 定时匹配任务的最大尝试次数
+const maxJobAttempts = 5
+
+// SchedulerService 定时匹配调度服务
+// 任务保存在数据库中，服务重启后继续执行；各实例通过条件更新领取任务，同一任务同一时间只会被一个实例执行
+type SchedulerService struct {
+	db          *gorm.DB
+	poolService *PoolService
+	instanceID  string
+	interval    time.Duration
+	lockTTL     time.Duration
+}
+
+// NewSchedulerService 创建定时匹配调度服务实例，轮询间隔由环境变量 SCHEDULER_INTERVAL（秒）指定，默认15秒
+func NewSchedulerService(db *gorm.DB) *SchedulerService {
+	hostname, _ := os.Hostname()
+	return &SchedulerService{
+		db:          db,
+		poolService: NewPoolService(db),
+		instanceID:  fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+		interval:    time.Duration(getEnvInt64OrDefault("SCHEDULER_INTERVAL", 15)) * time.Second,
+		lockTTL:     5 * time.Minute,
+	}
+}
+
+// Start 在后台启动调度循环
+func (s *SchedulerService) Start() {
+	log.Printf("⏰ 定时匹配调度器已启动，实例: %s，轮询间隔: %v", s.instanceID, s.interval)
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.runDueJobs()
+			<-ticker.C
+		}
+	}()
+}
+
+// runDueJobs 执行所有到期的任务，包括锁已过期（领取实例可能已崩溃）的任务
+func (s *SchedulerService) runDueJobs() {
+	now := time.Now()
+
+	var jobs []models.ScheduledMatchJob
+	if err := s.db.Where("(status = ? AND run_at <= ?) OR (status = ? AND locked_until < ?)",
+		models.JobStatusPending, now, models.JobStatusRunning, now).
+		Order("run_at").
+		Find(&jobs).Error; err != nil {
+		log.Printf("⚠️ 查询定时匹配任务失败: %v", err)
+		return
+	}
+
+	for i := range jobs {
+		if s.claim(&jobs[i]) {
+			s.runJob(&jobs[i])
+		}
+	}
+}
+
+// claim 领取任务：以状态和尝试次数作为版本条件更新，只有一个实例能更新成功
+func (s *SchedulerService) claim(job *models.ScheduledMatchJob) bool {
+	lockedUntil := time.Now().Add(s.lockTTL)
+	result := s.db.Model(&models.ScheduledMatchJob{}).
+		Where("id = ? AND status = ? AND attempts = ?", job.ID, job.Status, job.Attempts).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusRunning,
+			"locked_by":    s.instanceID,
+			"locked_until": lockedUntil,
+			"attempts":     job.Attempts + 1,
+		})
+	if result.Error != nil || result.RowsAffected != 1 {
+		return false
+	}
+
+	job.Status = models.JobStatusRunning
+	job.LockedBy = s.instanceID
+	job.LockedUntil = &lockedUntil
+	job.Attempts++
+	return true
+}
+
+// runJob 执行定时匹配
+func (s *SchedulerService) runJob(job *models.ScheduledMatchJob) {
+	log.Printf("⏰ 执行定时匹配任务 %d: Pool %d（第 %d 次尝试）", job.ID, job.PoolID, job.Attempts)
+
+	// 实例可能在匹配完成后、任务标记完成前崩溃：计划时间之后已有匹配记录时直接标记完成，避免重复匹配
+	var record models.MatchRecord
+	if err := s.db.Where("pool_id = ? AND matched_at >= ?", job.PoolID, job.RunAt).Order("id").First(&record).Error; err == nil {
+		s.finish(job, models.JobStatusDone, &record.ID, "")
+		return
+	}
+
+	var pool models.MatchPool
+	if err := s.db.First(&pool, job.PoolID).Error; err != nil || !pool.AutoMatch {
+		s.finish(job, models.JobStatusFailed, nil, "匹配池不存在或已关闭自动匹配")
+		return
+	}
+	if status := pool.CurrentStatus(); status == models.PoolStatusDraft || status == models.PoolStatusArchived {
+		s.finish(job, models.JobStatusFailed, nil, fmt.Sprintf("匹配池状态为 %s，不能自动匹配", status))
+		return
+	}
+
+	result, err := s.poolService.StartMatch(&models.StartMatchRequest{PoolID: job.PoolID})
+	if err != nil {
+		if job.Attempts < maxJobAttempts {
+			s.retry(job, err.Error())
+		} else {
+			s.finish(job, models.JobStatusFailed, nil, err.Error())
+		}
+		return
+	}
+
+	s.finish(job, models.JobStatusDone, &result.RecordID, "")
+}
+
+// retry 释放任务并在稍后重试，等待时间随尝试次数增加
+func (s *SchedulerService) retry(job *models.ScheduledMatchJob, lastError string) {
+	runAt := time.Now().Add(time.Duration(job.Attempts) * time.Minute)
+	s.db.Model(&models.ScheduledMatchJob{}).
+		Where("id = ? AND locked_by = ?", job.ID, s.instanceID).
+		Updates(map[string]interface{}{
+			"status":       models.JobStatusPending,
+			"run_at":       runAt,
+			"locked_by":    "",
+			"locked_until": nil,
+			"last_error":   lastError,
+		})
+	log.Printf("⚠️ 定时匹配任务 %d 失败，将于 %s 重试: %s", job.ID, runAt.Format("2006-01-02 15:04:05"), lastError)
+}
+
+// finish 记录任务的最终结果
+func (s *SchedulerService) finish(job *models.ScheduledMatchJob, status string, recordID *uint, lastError string) {
+	s.db.Model(&models.ScheduledMatchJob{}).
+		Where("id = ? AND locked_by = ?", job.ID, s.instanceID).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"record_id":    recordID,
+			"locked_until": nil,
+			"last_error":   lastError,
+		})
+
+	if status == models.JobStatusDone {
+		log.Printf("✅ 定时匹配任务 %d 完成: Pool %d", job.ID, job.PoolID)
+	} else {
+		log.Printf("❌ 定时匹配任务 %d 失败: Pool %d, %s", job.ID, job.PoolID, lastError)
+	}
+}
+
+// scheduleAutoMatch 根据匹配池的自动匹配设置创建、调整或取消定时匹配任务
+func scheduleAutoMatch(tx *gorm.DB, pool *models.MatchPool) error {
+	if !pool.AutoMatch {
+		return tx.Where("pool_id = ? AND status = ?", pool.ID, models.JobStatusPending).
+			Delete(&models.ScheduledMatchJob{}).Error
+	}
+
+	runAt := pool.ValidUntil
+	if pool.MatchAt != nil {
+		runAt = *pool.MatchAt
+	}
+
+	var job models.ScheduledMatchJob
+	err := tx.Where("pool_id = ?", pool.ID).First(&job).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(&models.ScheduledMatchJob{
+			PoolID: pool.ID,
+			RunAt:  runAt,
+			Status: models.JobStatusPending,
+		}).Error
+	}
+	if err != nil {
+		return err
+	}
+
+	switch job.Status {
+	case models.JobStatusPending:
+		return tx.Model(&job).Update("run_at", runAt).Error
+	case models.JobStatusRunning:
+		if runAt.Equal(job.RunAt) {
+			return nil
+		}
+		return fmt.Errorf("自动匹配正在执行，请稍后再修改匹配时间")
+	}
+
+	// 已完成或失败的任务：时间未变化时保持不变，保证同一时间只自动匹配一次；
+	// 设置了晚于上次执行的新时间时重新安排，早于上次执行的时间不会再被执行，直接拒绝
+	if runAt.Equal(job.RunAt) {
+		return nil
+	}
+	if !runAt.After(job.UpdatedAt) {
+		return fmt.Errorf("自动匹配已于 %s 执行，新的匹配时间需晚于该时间", job.UpdatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return tx.Model(&job).Updates(map[string]interface{}{
+		"run_at":       runAt,
+		"status":       models.JobStatusPending,
+		"attempts":     0,
+		"locked_by":    "",
+		"locked_until": nil,
+		"last_error":   "",
+		"record_id":    nil,
+	}).Error
+}
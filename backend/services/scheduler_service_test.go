@@ -0,0 +1,98 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestScheduleAutoMatchAfterRun 自动匹配执行后设置新的匹配时间会重新安排任务，不能执行的修改返回错误
+func TestScheduleAutoMatchAfterRun(t *testing.T) {
+	db := newTestDB(t)
+	poolService := NewPoolService(db)
+
+	pool, err := poolService.CreatePool(&models.CreatePoolRequest{
+		Name:       "定时匹配测试",
+		ValidUntil: time.Now().Add(time.Hour),
+		AutoMatch:  true,
+		Fields: []models.PoolField{
+			{FieldName: "name", FieldLabel: "姓名", FieldType: models.FieldTypeText, IsRequired: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("创建匹配池失败: %v", err)
+	}
+
+	var job models.ScheduledMatchJob
+	loadJob := func() {
+		t.Helper()
+		if err := db.Where("pool_id = ?", pool.ID).First(&job).Error; err != nil {
+			t.Fatalf("查询定时匹配任务失败: %v", err)
+		}
+	}
+	// setJob 模拟调度器更新任务，同时更新 updated_at
+	setJob := func(updates map[string]interface{}) {
+		t.Helper()
+		if err := db.Model(&models.ScheduledMatchJob{}).Where("pool_id = ?", pool.ID).Updates(updates).Error; err != nil {
+			t.Fatalf("更新定时匹配任务失败: %v", err)
+		}
+	}
+	recordID := uint(1)
+
+	// 已完成的任务在设置新的截止时间后重新安排
+	setJob(map[string]interface{}{"status": models.JobStatusDone, "attempts": 1, "record_id": recordID})
+	validUntil := time.Now().Add(2 * time.Hour)
+	if _, err := poolService.UpdatePool(pool.ID, &models.UpdatePoolRequest{ValidUntil: &validUntil}); err != nil {
+		t.Fatalf("更新匹配池失败: %v", err)
+	}
+	loadJob()
+	if job.Status != models.JobStatusPending || !job.RunAt.Equal(validUntil) || job.Attempts != 0 || job.RecordID != nil {
+		t.Errorf("已完成的任务未重新安排: status %s, runAt %v, attempts %d, recordId %v", job.Status, job.RunAt, job.Attempts, job.RecordID)
+	}
+
+	// 失败的任务在设置新的匹配时间后重新安排
+	setJob(map[string]interface{}{"status": models.JobStatusFailed, "attempts": maxJobAttempts, "last_error": "匹配失败"})
+	matchAt := time.Now().Add(90 * time.Minute)
+	if _, err := poolService.UpdatePool(pool.ID, &models.UpdatePoolRequest{MatchAt: &matchAt}); err != nil {
+		t.Fatalf("更新匹配池失败: %v", err)
+	}
+	loadJob()
+	if job.Status != models.JobStatusPending || !job.RunAt.Equal(matchAt) || job.Attempts != 0 || job.LastError != "" {
+		t.Errorf("失败的任务未重新安排: status %s, runAt %v, attempts %d, lastError %q", job.Status, job.RunAt, job.Attempts, job.LastError)
+	}
+
+	// 时间未变化时已完成的任务保持不变，不会再次匹配
+	setJob(map[string]interface{}{"status": models.JobStatusDone})
+	if _, err := poolService.UpdatePool(pool.ID, &models.UpdatePoolRequest{MatchAt: &matchAt}); err != nil {
+		t.Fatalf("更新匹配池失败: %v", err)
+	}
+	loadJob()
+	if job.Status != models.JobStatusDone {
+		t.Errorf("时间未变化时任务状态变为 %s", job.Status)
+	}
+
+	// 早于上次执行的时间不会再被执行，返回错误且不修改匹配池
+	db.Model(&models.ScheduledMatchJob{}).Where("pool_id = ?", pool.ID).
+		UpdateColumn("updated_at", time.Now().Add(3*time.Hour))
+	earlier := time.Now().Add(150 * time.Minute)
+	if _, err := poolService.UpdatePool(pool.ID, &models.UpdatePoolRequest{MatchAt: &earlier}); err == nil || !strings.Contains(err.Error(), "新的匹配时间需晚于") {
+		t.Errorf("早于上次执行的匹配时间应返回错误: %v", err)
+	}
+	var stored models.MatchPool
+	db.First(&stored, pool.ID)
+	if stored.MatchAt == nil || !stored.MatchAt.Equal(matchAt) {
+		t.Errorf("被拒绝的修改不应保存: matchAt %v", stored.MatchAt)
+	}
+
+	// 正在执行的任务不能修改匹配时间
+	setJob(map[string]interface{}{"status": models.JobStatusRunning})
+	later := time.Now().Add(4 * time.Hour)
+	if _, err := poolService.UpdatePool(pool.ID, &models.UpdatePoolRequest{MatchAt: &later}); err == nil || !strings.Contains(err.Error(), "正在执行") {
+		t.Errorf("任务正在执行时修改匹配时间应返回错误: %v", err)
+	}
+	loadJob()
+	if job.Status != models.JobStatusRunning || !job.RunAt.Equal(matchAt) {
+		t.Errorf("正在执行的任务被修改: status %s, runAt %v", job.Status, job.RunAt)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// scoreScale 分数转换为整数权重时的精度（保留三位小数）
+const scoreScale = 1000
+
+// pairScorer 根据字段匹配规则计算两名用户的匹配分数
+type pairScorer struct {
+	fields []models.PoolField
+}
+
+// newPairScorer 创建打分器，只保留设置了匹配规则的字段
+func newPairScorer(fields []models.PoolField) *pairScorer {
+	scorer := &pairScorer{}
+	for _, field := range fields {
+		if field.MatchRule != "" {
+			scorer.fields = append(scorer.fields, field)
+		}
+	}
+	return scorer
+}
+
+// validateMatchRules 校验字段匹配规则，score 模式下至少需要一个参与打分的字段
+func validateMatchRules(fields []models.PoolField, matchMode string) error {
+	scored := 0
+	for _, field := range fields {
+		switch field.MatchRule {
+		case "":
+			continue
+		case models.MatchRuleSame, models.MatchRuleDifferent, models.MatchRuleSimilar:
+			scored++
+		default:
+			return fmt.Errorf("字段 %s 的匹配规则无效: %s", field.FieldName, field.MatchRule)
+		}
+		if field.MatchWeight < 0 {
+			return fmt.Errorf("字段 %s 的匹配权重不能为负数", field.FieldName)
+		}
+	}
+
+	if matchMode == models.MatchModeScore && scored == 0 {
+		return fmt.Errorf("打分匹配模式至少需要一个设置了匹配规则的字段")
+	}
+	return nil
+}
+
+// score 计算两名用户的匹配分数，各字段得分在 0 到字段权重之间
+func (s *pairScorer) score(a, b *models.PoolUser) float64 {
+	total := 0.0
+	for _, field := range s.fields {
+		weight := field.MatchWeight
+		if weight <= 0 {
+			weight = 1
+		}
+
+		switch field.MatchRule {
+		case models.MatchRuleSame:
+			va := fieldValueKey(a.ParsedUserData, field.FieldName)
+			vb := fieldValueKey(b.ParsedUserData, field.FieldName)
+			if va != "" && va == vb {
+				total += weight
+			}
+		case models.MatchRuleDifferent:
+			va := fieldValueKey(a.ParsedUserData, field.FieldName)
+			vb := fieldValueKey(b.ParsedUserData, field.FieldName)
+			if va != "" && vb != "" && va != vb {
+				total += weight
+			}
+		case models.MatchRuleSimilar:
+			ta := fieldTokens(a.ParsedUserData[field.FieldName])
+			tb := fieldTokens(b.ParsedUserData[field.FieldName])
+			total += weight * jaccard(ta, tb)
+		}
+	}
+	return math.Round(total*scoreScale) / scoreScale
+}
+
+// fieldTokens 将字段值拆分为小写标签集合，支持数组和以逗号、顿号、空白等分隔的文本
+func fieldTokens(value interface{}) map[string]bool {
+	tokens := make(map[string]bool)
+
+	var add func(v interface{})
+	add = func(v interface{}) {
+		switch val := v.(type) {
+		case nil:
+		case []interface{}:
+			for _, item := range val {
+				add(item)
+			}
+		default:
+			parts := strings.FieldsFunc(fmt.Sprint(val), func(r rune) bool {
+				return unicode.IsSpace(r) || unicode.IsPunct(r)
+			})
+			for _, part := range parts {
+				tokens[strings.ToLower(part)] = true
+			}
+		}
+	}
+	add(value)
+
+	return tokens
+}
+
+// jaccard 计算两个集合的 Jaccard 相似度
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	common := 0
+	for token := range a {
+		if b[token] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// arrangeByScore 求满足排除约束、重复配对最少，并在此前提下总分最高的两两配对（最大权匹配），
+// 返回各分组（人数为奇数时包含一个轮空分组）和无法避免的重复配对数量
+func arrangeByScore(shuffled []models.PoolUser, plan *matchPlan, constraints []models.PoolConstraint, history *repeatHistory, scorer *pairScorer, displayName func(map[string]interface{}) string) ([][]models.PoolUser, int, error) {
+	g := newMatchGraph(shuffled, constraints, history, displayName)
+	n := len(shuffled)
+
+	scores := make([][]int64, n)
+	var maxScore int64
+	for i := range shuffled {
+		scores[i] = make([]int64, n)
+		for j := range shuffled {
+			if i < j {
+				scores[i][j] = int64(math.Round(scorer.score(&shuffled[i], &shuffled[j]) * scoreScale))
+				if scores[i][j] > maxScore {
+					maxScore = scores[i][j]
+				}
+			}
+		}
+	}
+
+	// 重复配对的惩罚大于任意配对方案的总分，保证优先减少重复；
+	// 所有边再加上同一常数使权重为正，最大基数匹配下不影响最优解
+	penalty := (maxScore + 1) * int64(n/2+1)
+	base := penalty + 1
+
+	var edges []weightedEdge
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if !g.allowed(i, j, -1) {
+				continue
+			}
+			weight := base + scores[i][j]
+			if g.repeat[i][j] {
+				weight -= penalty
+			}
+			edges = append(edges, weightedEdge{u: i, v: j, weight: weight})
+		}
+	}
+
+	mate := maxWeightMatching(n, edges, true)
+
+	var groups [][]int
+	var lone []int
+	for i, j := range mate {
+		if j == -1 {
+			lone = append(lone, i)
+		} else if i < j {
+			groups = append(groups, []int{i, j})
+		}
+	}
+	if len(lone) > n%2 {
+		return nil, 0, fmt.Errorf("%v：%s", errNoValidMatching, g.explain(plan))
+	}
+	for _, i := range lone {
+		groups = append(groups, []int{i})
+	}
+
+	arranged := make([][]models.PoolUser, len(groups))
+	for i, group := range groups {
+		arranged[i] = make([]models.PoolUser, len(group))
+		for j, idx := range group {
+			arranged[i][j] = shuffled[idx]
+		}
+	}
+	return arranged, g.countRepeats(groups, plan), nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"testing"
+	"time"
+)
+
+// TestStartMatchScoreMode 打分模式保存每组的分数，且避免重复配对优先于分数
+func TestStartMatchScoreMode(t *testing.T) {
+	db := newTestDB(t)
+	poolService := NewPoolService(db)
+
+	pool, err := poolService.CreatePool(&models.CreatePoolRequest{
+		Name:              "打分测试",
+		ValidUntil:        time.Now().Add(24 * time.Hour),
+		MatchMode:         models.MatchModeScore,
+		AvoidRepeatRounds: 1,
+		RandomSource:      models.RandomSourceCrypto,
+		Fields: []models.PoolField{
+			{FieldName: "name", FieldLabel: "姓名", FieldType: models.FieldTypeText, IsRequired: true},
+			{FieldName: "team", FieldLabel: "队伍", FieldType: models.FieldTypeText, MatchRule: models.MatchRuleSame, MatchWeight: 2},
+		},
+	})
+	if err != nil {
+		t.Fatalf("创建匹配池失败: %v", err)
+	}
+
+	teams := map[string]string{"甲": "红", "乙": "红", "丙": "蓝", "丁": "蓝"}
+	ids := make(map[string]uint)
+	for _, name := range []string{"甲", "乙", "丙", "丁"} {
+		joined, err := poolService.JoinPool(&models.JoinPoolRequest{
+			PoolID:   pool.ID,
+			UserData: map[string]interface{}{"name": name, "team": teams[name]},
+		})
+		if err != nil {
+			t.Fatalf("加入匹配池失败: %v", err)
+		}
+		ids[name] = joined.UserID
+	}
+	teamOf := make(map[uint]string)
+	for name, id := range ids {
+		teamOf[id] = teams[name]
+	}
+
+	// storedPairs 读取数据库中保存的分组
+	storedPairs := func(recordID uint) []models.MatchPair {
+		var pairs []models.MatchPair
+		if err := db.Where("record_id = ?", recordID).Find(&pairs).Error; err != nil {
+			t.Fatalf("查询配对失败: %v", err)
+		}
+		if len(pairs) != 2 {
+			t.Fatalf("应保存 2 组配对，实际 %d 组", len(pairs))
+		}
+		return pairs
+	}
+
+	// 第一轮：同队配对得分最高
+	first, err := poolService.StartMatch(&models.StartMatchRequest{PoolID: pool.ID})
+	if err != nil {
+		t.Fatalf("第一轮匹配失败: %v", err)
+	}
+	if first.TotalScore != 4 || first.RepeatCount != 0 {
+		t.Errorf("第一轮总分 %v、重复 %d，期望 4、0", first.TotalScore, first.RepeatCount)
+	}
+	for _, pair := range storedPairs(first.RecordID) {
+		if pair.Score != 2 {
+			t.Errorf("第 %d 组保存的分数为 %v，期望 2", pair.PairNumber, pair.Score)
+		}
+		if teamOf[pair.User1ID] != teamOf[*pair.User2ID] {
+			t.Errorf("第 %d 组不是同队配对", pair.PairNumber)
+		}
+	}
+	var record models.MatchRecord
+	db.First(&record, first.RecordID)
+	if record.TotalScore != 4 {
+		t.Errorf("匹配记录保存的总分为 %v，期望 4", record.TotalScore)
+	}
+
+	// 第二轮：再次同队配对会与上一轮重复，即使分数为 0 也应选择跨队配对
+	db.Model(&models.MatchPool{}).Where("id = ?", pool.ID).
+		Update("last_matched_at", time.Now().Add(-time.Hour))
+	second, err := poolService.StartMatch(&models.StartMatchRequest{PoolID: pool.ID})
+	if err != nil {
+		t.Fatalf("第二轮匹配失败: %v", err)
+	}
+	if second.TotalScore != 0 || second.RepeatCount != 0 {
+		t.Errorf("第二轮总分 %v、重复 %d，期望 0、0", second.TotalScore, second.RepeatCount)
+	}
+	for _, pair := range storedPairs(second.RecordID) {
+		if pair.Score != 0 {
+			t.Errorf("第 %d 组保存的分数为 %v，期望 0", pair.PairNumber, pair.Score)
+		}
+		if teamOf[pair.User1ID] == teamOf[*pair.User2ID] {
+			t.Errorf("第 %d 组与上一轮重复配对", pair.PairNumber)
+		}
+	}
+}
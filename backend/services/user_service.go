@@ -14,6 +14,7 @@ import (
 type UserService struct {
 	db           *gorm.DB
 	cacheService *cache.CacheService
+	webhooks     *WebhookService
 }
 
 // NewUserService 创建用户服务实例
@@ -21,6 +22,7 @@ func NewUserService(db *gorm.DB) *UserService {
 	return &UserService{
 		db:           db,
 		cacheService: cache.NewCacheService(),
+		webhooks:     NewWebhookService(db),
 	}
 }
 
@@ -78,24 +80,16 @@ func (us *UserService) SearchUsers(req *SearchUserRequest) ([]UserInfo, error) {
 			continue
 		}
 
-		name := us.getUserDisplayName(userData)
+		name := userDisplayName(userData)
 		pool := poolMap[user.PoolID]
 
-		// 确定用户状态
-		status := "active"
-		if pool.Status == "matched" {
-			status = "matched"
-		} else if pool.IsExpired() {
-			status = "expired"
-		}
-
 		userInfo := UserInfo{
 			ID:          user.ID,
 			Name:        name,
 			ContactInfo: user.ContactInfo,
 			PoolName:    pool.Name,
 			JoinedDate:  user.JoinedAt.Format("2006-01-02 15:04:05"),
-			Status:      status,
+			Status:      userStatus(&user, &pool),
 		}
 
 		userInfos = append(userInfos, userInfo)
@@ -122,26 +116,65 @@ func (us *UserService) RemoveUser(userID uint) error {
 		return fmt.Errorf("查询匹配池失败: %v", err)
 	}
 
-	if pool.Status == "matched" {
-		return fmt.Errorf("该匹配池已完成匹配，无法移除用户")
+	switch pool.CurrentStatus() {
+	case models.PoolStatusMatching:
+		return fmt.Errorf("该匹配池正在匹配中，请稍后再试")
+	case models.PoolStatusMatched:
+		return fmt.Errorf("该匹配池已完成匹配，无法移除用户，请通过匹配记录的修复功能移除")
+	case models.PoolStatusArchived:
+		return fmt.Errorf("该匹配池已归档，无法移除用户")
 	}
 
-	// 删除用户
-	if err := us.db.Delete(&user).Error; err != nil {
+	// 删除用户，正式参与者移除后由候补用户递补
+	err := us.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&user).Error; err != nil {
+			return err
+		}
+		// 删除引用该用户的排除约束
+		if err := deleteUserConstraints(tx, userID); err != nil {
+			return err
+		}
+		if user.Waitlisted {
+			return nil
+		}
+		_, err := promoteWaitlisted(tx, &pool)
+		return err
+	})
+	if err != nil {
 		return fmt.Errorf("移除用户失败: %v", err)
 	}
 
 	// 清除相关缓存
 	us.cacheService.Delete(cache.CacheKeyPools)
 	us.cacheService.Delete(cache.GeneratePoolKey(int(user.PoolID)))
+	us.cacheService.Delete(cache.GeneratePoolUsersKey(int(user.PoolID)))
+	us.cacheService.Delete(cache.GeneratePoolStatsKey(int(user.PoolID)))
 	us.cacheService.Delete(cache.CacheKeyStats)
 
+	publishParticipantEvent(us.db, models.PoolEventParticipantLeft, user.PoolID, user.ID, user.Waitlisted)
+	us.webhooks.Emit(models.WebhookEventParticipantRemoved, user.PoolID, map[string]interface{}{
+		"userId":     user.ID,
+		"userData":   webhookUserData(us.db, user.PoolID, user.UserData),
+		"waitlisted": user.Waitlisted,
+	})
+
 	log.Printf("🗑️ 移除用户成功: ID %d，从匹配池 %d", userID, user.PoolID)
 	return nil
 }
 
-// getUserDisplayName 获取用户显示名称
-func (us *UserService) getUserDisplayName(userData map[string]interface{}) string {
+// userStatus 用户状态：候补用户为 waitlisted，未验证邮箱的用户为 pending_verification，其余为所在匹配池的生命周期状态
+func userStatus(user *models.PoolUser, pool *models.MatchPool) string {
+	if user.Waitlisted {
+		return "waitlisted"
+	}
+	if user.PendingVerification {
+		return "pending_verification"
+	}
+	return pool.CurrentStatus()
+}
+
+// userNameField 按优先级查找名称字段，没有名称字段时返回空字符串
+func userNameField(userData map[string]interface{}) string {
 	// 按优先级查找显示名称
 	priorities := []string{"name", "姓名", "昵称", "nickname", "username", "用户名"}
 
@@ -152,6 +185,14 @@ func (us *UserService) getUserDisplayName(userData map[string]interface{}) strin
 			}
 		}
 	}
+	return ""
+}
+
+// userDisplayName 获取用户显示名称
+func userDisplayName(userData map[string]interface{}) string {
+	if name := userNameField(userData); name != "" {
+		return name
+	}
 
 	// 如果没有找到名称字段，返回第一个非空字符串值
 	for _, value := range userData {
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 可验证匹配（承诺-公开）：
+//   1. 匹配前生成 32 字节随机种子 seed（十六进制字符串），公布承诺 SHA-256(seed)；
+//   2. 匹配时将参与者按用户ID升序排列，摘要 digest = SHA-256(各参与者 "<id>:<userData>\n" 依次拼接)；
+//   3. 随机数流的第 i 块为 SHA-256(seed + ":" + digest + ":" + i)，每块按大端序切分为 4 个 uint64，
+//      以拒绝采样得到无偏的区间随机数，对排序后的参与者做 Fisher-Yates 打乱；
+//   4. 匹配完成后公开种子，任何人都可以用公开的种子、摘要和参与者ID复算打乱顺序；
+//   5. 按打乱后的顺序执行配对（matcher.go、scoring.go、weighted_matching.go）。
+// 打乱步骤的版本号为 verifiableAlgorithm，配对步骤的版本号为 pairingAlgorithm，
+// 任一步骤对相同输入的结果发生变化时都需要更新对应的版本号；验证时只复算当前版本的记录，
+// 其他版本的记录明确报告版本不受支持，而不是报告配对不一致。
+//
+// 摘要覆盖参与者的报名数据，排除约束和打分字段也不公开，因此摘要校验以及有约束或打分模式的配对
+// 只能由服务器的验证接口完成；没有约束的 pair、gift 模式可以仅凭公开的输入独立复算配对。
+
+// verifiableAlgorithm 当前的打乱算法版本
+const verifiableAlgorithm = "sha256-fisher-yates-v1"
+
+// pairingAlgorithm 当前的配对算法版本，覆盖分组人数规划、约束和重复配对的搜索顺序、打分及最大权匹配
+const pairingAlgorithm = "pairing-v1"
+
+// seedStream 由种子和参与者摘要确定性推导的随机数流
+type seedStream struct {
+	prefix  string
+	counter uint64
+	buf     []byte
+}
+
+// newSeedStream 创建随机数流
+func newSeedStream(seed, digest string) *seedStream {
+	return &seedStream{prefix: seed + ":" + digest + ":"}
+}
+
+// next 读取下一个 uint64
+func (s *seedStream) next() (uint64, error) {
+	if len(s.buf) < 8 {
+		block := sha256.Sum256([]byte(s.prefix + strconv.FormatUint(s.counter, 10)))
+		s.counter++
+		s.buf = block[:]
+	}
+	v := binary.BigEndian.Uint64(s.buf[:8])
+	s.buf = s.buf[8:]
+	return v, nil
+}
+
+// intn 返回 [0, n) 内的均匀随机整数（拒绝采样）
+func (s *seedStream) intn(n int) int {
+	v, _ := uniformIntn(s.next, n)
+	return v
+}
+
+// seededShuffle 以种子和参与者摘要确定性地打乱参与者（调用方需保证 users 已按ID升序排列）
+func seededShuffle(users []models.PoolUser, seed, digest string) []models.PoolUser {
+	shuffled := make([]models.PoolUser, len(users))
+	copy(shuffled, users)
+
+	stream := newSeedStream(seed, digest)
+	for i := len(shuffled) - 1; i > 0; i-- {
+		j := stream.intn(i + 1)
+		shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+	}
+	return shuffled
+}
+
+// generateSeed 使用 crypto/rand 生成新的随机种子
+func generateSeed() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("生成随机种子失败: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// seedCommitment 计算种子的承诺值
+func seedCommitment(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])
+}
+
+// commitNextSeed 为匹配池生成下一轮的种子并公布承诺
+func commitNextSeed(pool *models.MatchPool) error {
+	seed, err := generateSeed()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	pool.PendingSeed = seed
+	pool.SeedCommitment = seedCommitment(seed)
+	pool.SeedCommittedAt = &now
+	return nil
+}
+
+// sortUsersByID 返回按用户ID升序排列的参与者副本
+func sortUsersByID(users []models.PoolUser) []models.PoolUser {
+	sorted := make([]models.PoolUser, len(users))
+	copy(sorted, users)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
+// participantDigest 计算参与者列表摘要（users 需已按ID升序排列）
+func participantDigest(users []models.PoolUser) string {
+	h := sha256.New()
+	for _, user := range users {
+		fmt.Fprintf(h, "%d:%s\n", user.ID, user.UserData)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// seedReveal 一轮可验证匹配使用的种子、参与者摘要和确定性打乱结果
+type seedReveal struct {
+	seed         string
+	commitment   string
+	digest       string
+	participants []models.PoolUser // 按用户ID升序排列
+	shuffled     []models.PoolUser
+}
+
+// newSeedReveal 使用匹配池事先承诺的种子打乱参与者
+func newSeedReveal(pool *models.MatchPool, users []models.PoolUser) (*seedReveal, error) {
+	if pool.PendingSeed == "" || pool.SeedCommitment != seedCommitment(pool.PendingSeed) {
+		return nil, fmt.Errorf("匹配池尚未公布有效的种子承诺")
+	}
+
+	participants := sortUsersByID(users)
+	digest := participantDigest(participants)
+	return &seedReveal{
+		seed:         pool.PendingSeed,
+		commitment:   pool.SeedCommitment,
+		digest:       digest,
+		participants: participants,
+		shuffled:     seededShuffle(participants, pool.PendingSeed, digest),
+	}, nil
+}
+
+// inputs 生成复算所需的输入快照，历史配对只保留本轮参与者之间的配对
+func (r *seedReveal) inputs(pool *models.MatchPool, constraints []models.PoolConstraint, history *repeatHistory) (string, error) {
+	snapshot := models.MatchInputs{
+		Algorithm:         verifiableAlgorithm,
+		Pairing:           pairingAlgorithm,
+		MatchMode:         pool.MatchMode,
+		GroupSize:         pool.GroupSize,
+		LeftoverPolicy:    pool.LeftoverPolicy,
+		AvoidRepeatRounds: pool.AvoidRepeatRounds,
+		Fields:            pool.Fields,
+		Participants:      r.participants,
+		Constraints:       constraints,
+	}
+
+	if history != nil {
+		for i := range r.participants {
+			for j := i + 1; j < len(r.participants); j++ {
+				if history.seen(&r.participants[i], &r.participants[j]) {
+					snapshot.RepeatPairs = append(snapshot.RepeatPairs, [2]uint{r.participants[i].ID, r.participants[j].ID})
+				}
+			}
+		}
+	}
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", fmt.Errorf("序列化匹配输入失败: %v", err)
+	}
+	return string(data), nil
+}
+
+// repeatHistoryFromPairs 根据输入快照中的用户ID对重建配对历史
+func repeatHistoryFromPairs(pairs [][2]uint) *repeatHistory {
+	h := &repeatHistory{
+		userPairs:     make(map[[2]uint]bool),
+		identityPairs: make(map[[2]string]bool),
+	}
+	for _, pair := range pairs {
+		h.userPairs[userPairKey(pair[0], pair[1])] = true
+	}
+	return h
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pairingIDs 将配对结果转换为便于比较的成员ID列表
+func pairingIDs(pairs []models.MatchPair) string {
+	groups := make([]string, len(pairs))
+	for i, pair := range pairs {
+		ids := make([]string, 0, pair.Size)
+		for _, member := range pairMembers(pair) {
+			ids = append(ids, fmt.Sprint(member.UserID))
+		}
+		sep := "-"
+		if pair.Directed {
+			sep = ">"
+		}
+		groups[i] = strings.Join(ids, sep)
+	}
+	return strings.Join(groups, " ")
+}
+
+// TestVerifiablePipelineGolden 固定种子和输入时打乱与配对的结果。
+// 该测试失败说明相同输入的结果发生了变化：打乱步骤变化需要更新 verifiableAlgorithm，
+// 配对步骤变化需要更新 pairingAlgorithm，否则已有记录会无法通过验证
+func TestVerifiablePipelineGolden(t *testing.T) {
+	const seed = "c0ffee00c0ffee00c0ffee00c0ffee00c0ffee00c0ffee00c0ffee00c0ffee00"
+	if verifiableAlgorithm != "sha256-fisher-yates-v1" || pairingAlgorithm != "pairing-v1" {
+		t.Fatalf("算法版本已更新，请同时更新本测试的期望结果")
+	}
+
+	users := testUsers(9, func(i int) map[string]interface{} {
+		return map[string]interface{}{
+			"team":    fmt.Sprintf("T%d", i%3),
+			"hobbies": []interface{}{"读书", "跑步", "音乐"}[i%3],
+		}
+	})
+	fields := []models.PoolField{
+		{FieldName: "team", MatchRule: models.MatchRuleDifferent, MatchWeight: 2},
+		{FieldName: "hobbies", MatchRule: models.MatchRuleSimilar, MatchWeight: 1},
+	}
+	constraints := []models.PoolConstraint{
+		excludePair(1, 1, 2),
+		{ID: 2, Type: models.ConstraintExcludeSameField, FieldName: "team"},
+	}
+	history := testHistory([2]uint{1, 4}, [2]uint{5, 9})
+
+	participants := sortUsersByID(users)
+	digest := participantDigest(participants)
+	shuffled := seededShuffle(participants, seed, digest)
+
+	ids := make([]uint, len(shuffled))
+	for i, user := range shuffled {
+		ids[i] = user.ID
+	}
+
+	tests := []struct {
+		name        string
+		pool        models.MatchPool
+		constraints []models.PoolConstraint
+		history     *repeatHistory
+		want        string
+	}{
+		{"两两配对", models.MatchPool{MatchMode: models.MatchModePair}, nil, nil, "4-9 3-5 6-1 7-8 2"},
+		{"约束和历史", models.MatchPool{MatchMode: models.MatchModePair}, constraints, history, "1-9 5-4 3-7 8-6 2"},
+		{"四人一组合并", models.MatchPool{MatchMode: models.MatchModePair, GroupSize: 4, LeftoverPolicy: models.LeftoverMerge}, nil, history, "4-9-3-6-7 5-1-8-2"},
+		{"礼物交换", models.MatchPool{MatchMode: models.MatchModeGift}, constraints[:1], nil, "4>9 9>3 3>5 5>6 6>1 1>7 7>8 8>2 2>4"},
+		{"打分", models.MatchPool{MatchMode: models.MatchModeScore, Fields: fields}, constraints[:1], history, "4-2 9-8 3-7 5-1 6"},
+	}
+
+	if digest != "81df44bf29d5ac8ff88852c481e1890c21c7236bf62159469100f0c86f4b0134" || fmt.Sprint(ids) != "[4 9 3 5 6 1 7 8 2]" {
+		t.Fatalf("摘要 %s、打乱顺序 %v 与期望不一致", digest, ids)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := newMatchPlan(&tt.pool, len(shuffled))
+			pairs, _, err := matchShuffled(shuffled, plan, tt.constraints, tt.history, userDisplayName)
+			if err != nil {
+				t.Fatalf("匹配失败: %v", err)
+			}
+			if got := pairingIDs(pairs); got != tt.want {
+				t.Errorf("配对结果为 %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestVerifyRecordPairingVersion 验证通过的记录在配对算法版本不受支持时明确报告版本，而不是报告配对不一致
+func TestVerifyRecordPairingVersion(t *testing.T) {
+	db := newTestDB(t)
+	poolService := NewPoolService(db)
+
+	pool, err := poolService.CreatePool(&models.CreatePoolRequest{
+		Name:       "可验证测试",
+		ValidUntil: time.Now().Add(24 * time.Hour),
+		Verifiable: true,
+		Fields: []models.PoolField{
+			{FieldName: "name", FieldLabel: "姓名", FieldType: models.FieldTypeText, IsRequired: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("创建匹配池失败: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		if _, err := poolService.JoinPool(&models.JoinPoolRequest{
+			PoolID:   pool.ID,
+			UserData: map[string]interface{}{"name": fmt.Sprintf("用户%d", i)},
+		}); err != nil {
+			t.Fatalf("加入匹配池失败: %v", err)
+		}
+	}
+	matched, err := poolService.StartMatch(&models.StartMatchRequest{PoolID: pool.ID})
+	if err != nil {
+		t.Fatalf("匹配失败: %v", err)
+	}
+
+	historyService := NewHistoryService(db)
+	result, err := historyService.VerifyRecord(matched.RecordID)
+	if err != nil {
+		t.Fatalf("验证失败: %v", err)
+	}
+	if !result.Verified || result.Pairing != pairingAlgorithm || !result.PublicInputs {
+		t.Fatalf("验证结果不正确: verified %v, pairing %s, publicInputs %v, problems %v",
+			result.Verified, result.Pairing, result.PublicInputs, result.Problems)
+	}
+
+	// 模拟由旧版本配对算法生成的记录
+	var record models.MatchRecord
+	db.First(&record, matched.RecordID)
+	inputs := strings.Replace(record.MatchInputs, `"pairing":"`+pairingAlgorithm+`"`, `"pairing":"pairing-v0"`, 1)
+	if inputs == record.MatchInputs {
+		t.Fatal("匹配输入中没有记录配对算法版本")
+	}
+	db.Model(&record).Update("match_inputs", inputs)
+
+	result, err = historyService.VerifyRecord(matched.RecordID)
+	if err != nil {
+		t.Fatalf("验证失败: %v", err)
+	}
+	if result.Verified || !result.CommitmentValid || !result.ParticipantsValid {
+		t.Errorf("验证结果不正确: verified %v, commitment %v, participants %v",
+			result.Verified, result.CommitmentValid, result.ParticipantsValid)
+	}
+	problems := strings.Join(result.Problems, "；")
+	if !strings.Contains(problems, "不支持的配对算法版本: pairing-v0") || strings.Contains(problems, "复算的配对与记录不一致") {
+		t.Errorf("应报告不支持的配对算法版本: %s", problems)
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"christmas-link-backend/cache"
+	"christmas-link-backend/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/big"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	verificationCodeTTL        = 30 * time.Minute // 验证码有效期
+	verificationMaxAttempts    = 5                // 同一验证码允许输错的次数
+	verificationResendInterval = time.Minute      // 重新发送验证码的最短间隔
+)
+
+// isEmailAddress 判断联系方式是否为单个邮箱地址
+func isEmailAddress(contact string) bool {
+	addr, err := mail.ParseAddress(contact)
+	return err == nil && addr.Address == contact
+}
+
+// hashVerificationCode 计算验证码的 SHA-256 摘要，数据库中只保存摘要
+func hashVerificationCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(code)))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueVerificationCode 为用户生成新的 6 位验证码并标记为待验证，之前的验证码和输错次数作废
+func issueVerificationCode(tx *gorm.DB, userID uint) (string, error) {
+	return storeVerificationCode(tx, userID, map[string]interface{}{
+		"pending_verification": true,
+		"verified_at":          nil,
+	})
+}
+
+// storeVerificationCode 生成新的 6 位验证码并保存摘要，同时写入 extra 中的字段；之前的验证码和输错次数作废
+func storeVerificationCode(tx *gorm.DB, userID uint, extra map[string]interface{}) (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", fmt.Errorf("生成验证码失败: %v", err)
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+
+	now := time.Now()
+	expiresAt := now.Add(verificationCodeTTL)
+	updates := map[string]interface{}{
+		"verification_code_hash":  hashVerificationCode(code),
+		"verification_expires_at": &expiresAt,
+		"verification_attempts":   0,
+		"verification_sent_at":    &now,
+	}
+	for key, value := range extra {
+		updates[key] = value
+	}
+	if err := tx.Model(&models.PoolUser{}).Where("id = ?", userID).Updates(updates).Error; err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// checkVerificationCode 校验用户当前的验证码，返回验证码摘要供调用方以条件更新使验证码失效。
+// 比较之前先以次数未达上限为条件占用一次尝试，并发提交的验证码也不会超过 verificationMaxAttempts 次
+func checkVerificationCode(db *gorm.DB, user *models.PoolUser, code string) (string, error) {
+	if user.VerificationCodeHash == "" || user.VerificationExpiresAt == nil || time.Now().After(*user.VerificationExpiresAt) {
+		return "", fmt.Errorf("验证码已过期，请重新发送验证码")
+	}
+
+	reserve := db.Model(&models.PoolUser{}).
+		Where("id = ? AND verification_attempts < ?", user.ID, verificationMaxAttempts).
+		UpdateColumn("verification_attempts", gorm.Expr("verification_attempts + 1"))
+	if reserve.Error != nil {
+		return "", reserve.Error
+	}
+	if reserve.RowsAffected != 1 {
+		return "", fmt.Errorf("验证码错误次数过多，请重新发送验证码")
+	}
+
+	hash := hashVerificationCode(code)
+	if subtle.ConstantTimeCompare([]byte(hash), []byte(user.VerificationCodeHash)) != 1 {
+		var attempts int
+		if err := db.Model(&models.PoolUser{}).Where("id = ?", user.ID).
+			Select("verification_attempts").Scan(&attempts).Error; err != nil {
+			return "", err
+		}
+		if remaining := verificationMaxAttempts - attempts; remaining > 0 {
+			return "", fmt.Errorf("验证码错误，还可以尝试 %d 次", remaining)
+		}
+		return "", fmt.Errorf("验证码错误次数过多，请重新发送验证码")
+	}
+	return hash, nil
+}
+
+// sendVerificationEmail 发送包含验证码和验证链接的邮件
+// 验证链接指向 PUBLIC_BASE_URL（默认 http://localhost:7776）下的 /api/participant/verify
+func sendVerificationEmail(sender MailSender, pool *models.MatchPool, userID uint, email, code string) error {
+	link := fmt.Sprintf("%s/api/participant/verify?userId=%d&code=%s",
+		strings.TrimRight(getEnvOrDefault("PUBLIC_BASE_URL", "http://localhost:7776"), "/"),
+		userID, url.QueryEscape(code))
+
+	body := fmt.Sprintf("你好！\n\n你报名了匹配池「%s」，请验证邮箱后参与匹配。\n\n验证码：%s\n\n也可以直接打开以下链接完成验证：\n%s\n\n验证码 %d 分钟内有效。如果不是你本人报名，请忽略这封邮件。\n",
+		pool.Name, code, link, int(verificationCodeTTL.Minutes()))
+
+	err := sender.Send(&MailMessage{
+		To:      email,
+		Subject: fmt.Sprintf("【%s】邮箱验证码", pool.Name),
+		Body:    body,
+	})
+	if err != nil {
+		log.Printf("❌ 发送邮箱验证码失败: 用户 %d, Pool %d: %v", userID, pool.ID, err)
+		return err
+	}
+	log.Printf("📧 已发送邮箱验证码: 用户 %d, Pool %d (%s)", userID, pool.ID, sender.Name())
+	return nil
+}
+
+// sendLookupEmail 发送查询匹配结果的一次性验证码
+func sendLookupEmail(sender MailSender, pool *models.MatchPool, userID uint, email, code string) error {
+	body := fmt.Sprintf("你好！\n\n有人请求查询你在匹配池「%s」中的匹配结果。\n\n验证码：%s\n\n验证码 %d 分钟内有效，只能使用一次。如果不是你本人操作，请忽略这封邮件。\n",
+		pool.Name, code, int(verificationCodeTTL.Minutes()))
+
+	err := sender.Send(&MailMessage{
+		To:      email,
+		Subject: fmt.Sprintf("【%s】查询匹配结果验证码", pool.Name),
+		Body:    body,
+	})
+	if err != nil {
+		log.Printf("❌ 发送查询验证码失败: 用户 %d, Pool %d: %v", userID, pool.ID, err)
+		return err
+	}
+	log.Printf("📧 已发送查询验证码: 用户 %d, Pool %d (%s)", userID, pool.ID, sender.Name())
+	return nil
+}
+
+// VerificationService 参与者邮箱验证服务
+type VerificationService struct {
+	db           *gorm.DB
+	cacheService *cache.CacheService
+	mailSender   MailSender
+}
+
+// NewVerificationService 创建邮箱验证服务实例
+func NewVerificationService(db *gorm.DB) *VerificationService {
+	return &VerificationService{
+		db:           db,
+		cacheService: cache.NewCacheService(),
+		mailSender:   NewMailSender(),
+	}
+}
+
+// findPendingUser 根据管理令牌或用户ID查找参与者，并检查所在匹配池是否还能验证邮箱
+func (s *VerificationService) findPendingUser(token string, userID uint) (*models.PoolUser, *models.MatchPool, error) {
+	var user *models.PoolUser
+	if token != "" {
+		found, err := findUserByToken(s.db, token)
+		if err != nil {
+			return nil, nil, err
+		}
+		user = found
+	} else {
+		var found models.PoolUser
+		if userID == 0 || s.db.First(&found, userID).Error != nil {
+			return nil, nil, fmt.Errorf("验证码无效或已过期")
+		}
+		user = &found
+	}
+
+	var pool models.MatchPool
+	if err := s.db.First(&pool, user.PoolID).Error; err != nil {
+		return nil, nil, fmt.Errorf("匹配池不存在")
+	}
+	switch pool.CurrentStatus() {
+	case models.PoolStatusMatching:
+		return nil, nil, fmt.Errorf("匹配池正在匹配中，请稍后再试")
+	case models.PoolStatusArchived:
+		return nil, nil, fmt.Errorf("匹配池已归档，不能再验证邮箱")
+	}
+	return user, &pool, nil
+}
+
+// Verify 校验验证码，通过后参与者转为已验证并参与之后的匹配；邮箱已验证时直接返回成功。
+// 同一验证码最多尝试 verificationMaxAttempts 次，之后失效，需要重新发送
+func (s *VerificationService) Verify(token string, userID uint, code string) error {
+	user, pool, err := s.findPendingUser(token, userID)
+	if err != nil {
+		return err
+	}
+	if !user.PendingVerification {
+		return nil
+	}
+
+	hash, err := checkVerificationCode(s.db, user, code)
+	if err != nil {
+		return err
+	}
+
+	// 只有验证码未被重新发送替换时才生效
+	now := time.Now()
+	result := s.db.Model(&models.PoolUser{}).
+		Where("id = ? AND pending_verification = ? AND verification_code_hash = ?", user.ID, true, hash).
+		Updates(map[string]interface{}{
+			"pending_verification":    false,
+			"verification_code_hash":  "",
+			"verification_expires_at": nil,
+			"verification_attempts":   0,
+			"verified_at":             &now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != 1 {
+		return fmt.Errorf("验证码无效或已过期")
+	}
+
+	// 清除相关缓存
+	s.cacheService.Delete(cache.CacheKeyPools)
+	s.cacheService.Delete(cache.GeneratePoolKey(int(pool.ID)))
+	s.cacheService.Delete(cache.GeneratePoolUsersKey(int(pool.ID)))
+	s.cacheService.Delete(cache.GeneratePoolStatsKey(int(pool.ID)))
+
+	publishParticipantEvent(s.db, models.PoolEventParticipantVerified, pool.ID, user.ID, user.Waitlisted)
+
+	log.Printf("📬 参与者邮箱验证成功: 用户 %d, Pool %d", user.ID, pool.ID)
+	return nil
+}
+
+// Resend 重新发送验证码，之前的验证码作废；两次发送至少间隔 verificationResendInterval
+func (s *VerificationService) Resend(token string) error {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return ErrInvalidManageToken
+	}
+	user, pool, err := s.findPendingUser(token, 0)
+	if err != nil {
+		return err
+	}
+	if !user.PendingVerification {
+		return fmt.Errorf("邮箱已验证，无需重新发送")
+	}
+	if user.VerificationSentAt != nil {
+		if wait := verificationResendInterval - time.Since(*user.VerificationSentAt); wait > 0 {
+			return fmt.Errorf("发送过于频繁，请在 %d 秒后重试", int(wait.Seconds())+1)
+		}
+	}
+
+	code, err := issueVerificationCode(s.db, user.ID)
+	if err != nil {
+		return err
+	}
+	if err := sendVerificationEmail(s.mailSender, pool, user.ID, user.ContactInfo, code); err != nil {
+		return fmt.Errorf("验证码发送失败，请稍后重试")
+	}
+	return nil
+}
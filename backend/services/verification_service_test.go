@@ -0,0 +1,272 @@
+package services
+
+import (
+	"bufio"
+	"christmas-link-backend/database"
+	"christmas-link-backend/models"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeSMTPServer 本地的最小 SMTP 服务器，收下所有邮件供测试检查
+type fakeSMTPServer struct {
+	listener net.Listener
+	mu       sync.Mutex
+	messages []*mail.Message
+	bodies   []string
+	received chan struct{}
+}
+
+// newFakeSMTPServer 在随机端口启动 SMTP 服务器，测试结束时关闭
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("启动 SMTP 服务器失败: %v", err)
+	}
+	s := &fakeSMTPServer{listener: listener, received: make(chan struct{}, 16)}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+// serve 处理一个 SMTP 连接：不声明任何扩展，因此客户端不会使用 STARTTLS 或认证
+func (s *fakeSMTPServer) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+
+	reply("220 localhost fake smtp")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		command := strings.ToUpper(strings.TrimSpace(line))
+		switch {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(command, "DATA"):
+			reply("354 end with <CRLF>.<CRLF>")
+			var data strings.Builder
+			for {
+				dataLine, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(dataLine, "."))
+			}
+			s.store(data.String())
+			reply("250 OK")
+		case strings.HasPrefix(command, "QUIT"):
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+// store 解析并保存收到的邮件
+func (s *fakeSMTPServer) store(data string) {
+	msg, err := mail.ReadMessage(strings.NewReader(data))
+	if err != nil {
+		return
+	}
+	var body strings.Builder
+	bodyReader := bufio.NewReader(msg.Body)
+	for {
+		line, err := bodyReader.ReadString('\n')
+		body.WriteString(strings.TrimSpace(line))
+		if err != nil {
+			break
+		}
+	}
+	decoded, _ := base64.StdEncoding.DecodeString(body.String())
+
+	s.mu.Lock()
+	s.messages = append(s.messages, msg)
+	s.bodies = append(s.bodies, string(decoded))
+	s.mu.Unlock()
+	s.received <- struct{}{}
+}
+
+// waitMessage 等待第 n 封邮件（从 1 开始）并返回其收件人和正文
+func (s *fakeSMTPServer) waitMessage(t *testing.T, n int) (string, string) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		s.mu.Lock()
+		if len(s.messages) >= n {
+			to, body := s.messages[n-1].Header.Get("To"), s.bodies[n-1]
+			s.mu.Unlock()
+			return to, body
+		}
+		s.mu.Unlock()
+		select {
+		case <-s.received:
+		case <-deadline:
+			t.Fatalf("等待第 %d 封邮件超时", n)
+		}
+	}
+}
+
+// newTestDB 创建临时的 SQLite 数据库并迁移表结构
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("打开数据库失败: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("迁移数据库失败: %v", err)
+	}
+	return db
+}
+
+// joinVerifyPool 创建需要验证邮箱的匹配池，并以 email 加入，返回加入结果
+func joinVerifyPool(t *testing.T, db *gorm.DB, email string) *models.JoinPoolResponse {
+	t.Helper()
+	poolService := NewPoolService(db)
+	pool, err := poolService.CreatePool(&models.CreatePoolRequest{
+		Name:        "验证测试",
+		ValidUntil:  time.Now().Add(24 * time.Hour),
+		VerifyEmail: true,
+		Fields: []models.PoolField{
+			{FieldName: "name", FieldLabel: "姓名", FieldType: models.FieldTypeText, IsRequired: true},
+		},
+	})
+	if err != nil {
+		t.Fatalf("创建匹配池失败: %v", err)
+	}
+
+	joined, err := poolService.JoinPool(&models.JoinPoolRequest{
+		PoolID:      pool.ID,
+		UserData:    map[string]interface{}{"name": "小明"},
+		ContactInfo: email,
+	})
+	if err != nil {
+		t.Fatalf("加入匹配池失败: %v", err)
+	}
+	if !joined.PendingVerification {
+		t.Fatal("加入需要验证邮箱的匹配池后应处于待验证状态")
+	}
+	return joined
+}
+
+var verificationCodePattern = regexp.MustCompile(`验证码：(\d{6})`)
+
+// TestVerifyEmailViaSMTP 加入 → 待验证 → 通过 SMTP 收到验证码 → 验证 → 已验证
+func TestVerifyEmailViaSMTP(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port, _ := net.SplitHostPort(server.listener.Addr().String())
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+
+	db := newTestDB(t)
+	joined := joinVerifyPool(t, db, "Xiaoming@Example.com")
+
+	var user models.PoolUser
+	db.First(&user, joined.UserID)
+	pool := models.MatchPool{ID: user.PoolID}
+	if pool.GetPendingCount(db) != 1 || pool.GetUserCount(db) != 0 {
+		t.Fatalf("待验证的用户不应计入人数: pending %d, users %d", pool.GetPendingCount(db), pool.GetUserCount(db))
+	}
+
+	to, body := server.waitMessage(t, 1)
+	if to != "xiaoming@example.com" {
+		t.Errorf("验证码发送到了 %s", to)
+	}
+	match := verificationCodePattern.FindStringSubmatch(body)
+	if match == nil {
+		t.Fatalf("邮件中没有验证码:\n%s", body)
+	}
+
+	verificationService := NewVerificationService(db)
+	wrong := "000000"
+	if match[1] == wrong {
+		wrong = "111111"
+	}
+	if err := verificationService.Verify(joined.ManageToken, 0, wrong); err == nil {
+		t.Fatal("错误的验证码不应通过")
+	}
+	if err := verificationService.Verify(joined.ManageToken, 0, match[1]); err != nil {
+		t.Fatalf("验证失败: %v", err)
+	}
+
+	db.First(&user, joined.UserID)
+	if user.PendingVerification || user.VerifiedAt == nil || user.VerificationAttempts != 0 {
+		t.Errorf("验证后状态不正确: pending %v, verifiedAt %v, attempts %d",
+			user.PendingVerification, user.VerifiedAt, user.VerificationAttempts)
+	}
+	if pool.GetPendingCount(db) != 0 || pool.GetUserCount(db) != 1 {
+		t.Errorf("验证后应计入人数: pending %d, users %d", pool.GetPendingCount(db), pool.GetUserCount(db))
+	}
+}
+
+// TestVerifyAttemptLimit 并发提交的错误验证码不能超过尝试次数上限，超过后正确的验证码也失效
+func TestVerifyAttemptLimit(t *testing.T) {
+	t.Setenv("SMTP_HOST", "")
+	db := newTestDB(t)
+	joined := joinVerifyPool(t, db, "xiaohong@example.com")
+
+	// 直接设置已知的验证码，便于提交错误和正确的验证码
+	code := "123456"
+	db.Model(&models.PoolUser{}).Where("id = ?", joined.UserID).
+		Update("verification_code_hash", hashVerificationCode(code))
+
+	verificationService := NewVerificationService(db)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			verificationService.Verify(joined.ManageToken, 0, fmt.Sprintf("9%05d", i))
+		}(i)
+	}
+	wg.Wait()
+
+	var user models.PoolUser
+	db.First(&user, joined.UserID)
+	if user.VerificationAttempts > verificationMaxAttempts {
+		t.Errorf("尝试次数 %d 超过上限 %d", user.VerificationAttempts, verificationMaxAttempts)
+	}
+
+	// 用完剩余的次数后，正确的验证码也不再有效
+	for i := user.VerificationAttempts; i < verificationMaxAttempts; i++ {
+		verificationService.Verify(joined.ManageToken, 0, "000000")
+	}
+	if err := verificationService.Verify(joined.ManageToken, 0, code); err == nil {
+		t.Error("超过尝试次数后正确的验证码不应通过")
+	}
+	db.First(&user, joined.UserID)
+	if !user.PendingVerification || user.VerificationAttempts != verificationMaxAttempts {
+		t.Errorf("超过尝试次数后状态不正确: pending %v, attempts %d", user.PendingVerification, user.VerificationAttempts)
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"christmas-link-backend/models"
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// Viewer 查看匹配结果的身份：管理员、已验证的参与者，或两者都不是的匿名访问者
+type Viewer struct {
+	Admin  bool
+	UserID uint // 已验证的参与者（PoolUser）ID，0 表示匿名
+}
+
+// VisibilityService 按字段可见性隐藏匹配结果中的用户数据
+type VisibilityService struct {
+	db *gorm.DB
+}
+
+// NewVisibilityService 创建字段可见性服务实例
+func NewVisibilityService(db *gorm.DB) *VisibilityService {
+	return &VisibilityService{db: db}
+}
+
+// TokenViewer 用参与者管理令牌验证参与者身份
+func (s *VisibilityService) TokenViewer(token string) (*Viewer, error) {
+	user, err := findUserByToken(s.db, token)
+	if err != nil {
+		return nil, fmt.Errorf("参与者身份验证失败")
+	}
+	return &Viewer{UserID: user.ID}, nil
+}
+
+// Redact 返回按查看者身份隐藏字段后的匹配结果副本：
+// public 字段所有人可见；partner 字段只有本人和匹配对象可见（交换礼物模式下只有送礼人能看到收礼人的字段）；
+// admin 字段只有本人和管理员可见。匹配池字段定义之外的数据视为 public
+func (s *VisibilityService) Redact(result *models.MatchResult, viewer Viewer) (*models.MatchResult, error) {
+	if viewer.Admin {
+		return result, nil
+	}
+
+	poolID := result.PoolID
+	if poolID == 0 {
+		// 旧的缓存结果没有匹配池ID
+		var record models.MatchRecord
+		if err := s.db.Select("pool_id").First(&record, result.RecordID).Error; err != nil {
+			return nil, err
+		}
+		poolID = record.PoolID
+	}
+
+	visibility, err := s.fieldVisibility(poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	redacted := *result
+	redacted.Pairs = make([]models.MatchPairResult, len(result.Pairs))
+	for i, pair := range result.Pairs {
+		redacted.Pairs[i] = s.redactPair(pair, visibility, viewer)
+	}
+	return &redacted, nil
+}
+
+// PublicUserData 返回匿名访问者可见的用户数据，只保留 public 字段（匹配池字段定义之外的数据视为 public），
+// 用于发送给第三方的事件
+func (s *VisibilityService) PublicUserData(poolID uint, userData json.RawMessage) (map[string]interface{}, error) {
+	visibility, err := s.fieldVisibility(poolID)
+	if err != nil {
+		return nil, err
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(userData, &data); err != nil {
+		return nil, err
+	}
+	public := make(map[string]interface{}, len(data))
+	for key, value := range data {
+		if v, ok := visibility[key]; ok && v != models.FieldVisibilityPublic {
+			continue
+		}
+		public[key] = value
+	}
+	return public, nil
+}
+
+// fieldVisibility 读取匹配池各字段的可见性
+func (s *VisibilityService) fieldVisibility(poolID uint) (map[string]string, error) {
+	var fields []models.PoolField
+	if err := s.db.Where("pool_id = ?", poolID).Find(&fields).Error; err != nil {
+		return nil, err
+	}
+	visibility := make(map[string]string, len(fields))
+	for _, field := range fields {
+		visibility[field.FieldName] = field.EffectiveVisibility()
+	}
+	return visibility, nil
+}
+
+// redactPair 隐藏单个配对中查看者无权看到的字段，显示名称按隐藏后的数据重新生成
+func (s *VisibilityService) redactPair(pair models.MatchPairResult, visibility map[string]string, viewer Viewer) models.MatchPairResult {
+	inPair := false
+	for _, member := range pair.Members {
+		if viewer.UserID != 0 && member.UserID == viewer.UserID {
+			inPair = true
+		}
+	}
+
+	// partnerOf 查看者是否为该用户的匹配对象
+	partnerOf := func(userID uint) bool {
+		if !inPair || userID == viewer.UserID {
+			return false
+		}
+		if pair.Directed {
+			return viewer.UserID == pair.User1ID && pair.User2ID != nil && *pair.User2ID == userID
+		}
+		return true
+	}
+
+	redact := func(userID uint, data map[string]interface{}) (map[string]interface{}, bool) {
+		if data == nil || (viewer.UserID != 0 && userID == viewer.UserID) {
+			return data, false
+		}
+		partner := partnerOf(userID)
+
+		visible := make(map[string]interface{}, len(data))
+		hidden := false
+		for key, value := range data {
+			switch visibility[key] {
+			case models.FieldVisibilityAdmin:
+				hidden = true
+				continue
+			case models.FieldVisibilityPartner:
+				if !partner {
+					hidden = true
+					continue
+				}
+			}
+			visible[key] = value
+		}
+		return visible, hidden
+	}
+
+	result := pair
+	if data, hidden := redact(pair.User1ID, pair.User1Data); hidden {
+		result.User1Data = data
+		result.User1 = userDisplayName(data)
+	}
+	if pair.User2ID != nil {
+		if data, hidden := redact(*pair.User2ID, pair.User2Data); hidden {
+			result.User2Data = data
+			result.User2 = userDisplayName(data)
+		}
+	}
+	if pair.Directed && result.User2 != "" {
+		result.Description = fmt.Sprintf("%s 送礼物给 %s", result.User1, result.User2)
+	}
+
+	result.Members = make([]models.MatchMemberResult, len(pair.Members))
+	for i, member := range pair.Members {
+		result.Members[i] = member
+		if data, hidden := redact(member.UserID, member.Data); hidden {
+			result.Members[i].Data = data
+			result.Members[i].Name = userDisplayName(data)
+		}
+	}
+	return result
+}
@@ -0,0 +1,384 @@
+package services
+
+import (
+	"bytes"
+	"christmas-link-backend/models"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// maxWebhookAttempts Webhook 的最大投递次数
+const maxWebhookAttempts = 6
+
+// webhookEvents 支持订阅的事件
+var webhookEvents = []string{
+	models.WebhookEventPoolCreated,
+	models.WebhookEventParticipantJoined,
+	models.WebhookEventParticipantRemoved,
+	models.WebhookEventMatchCompleted,
+	models.WebhookEventPoolExpired,
+}
+
+// webhookPayload 投递的 JSON 载荷
+type webhookPayload struct {
+	ID        string      `json:"id"` // 事件ID
+	Event     string      `json:"event"`
+	PoolID    uint        `json:"poolId"`
+	CreatedAt string      `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+// signWebhook 计算签名：HMAC-SHA256(secret, "<时间戳>.<请求体>")，十六进制编码
+func signWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// randomHex 生成 n 字节的随机十六进制字符串
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// WebhookService Webhook 订阅和投递服务
+// 事件发生时为每个匹配的订阅保存一条投递记录，由后台循环投递；失败后按指数退避重试，
+// 各实例通过条件更新领取投递，同一投递同一时间只会被一个实例发送
+type WebhookService struct {
+	db       *gorm.DB
+	client   *http.Client
+	outbox   *outbox
+	interval time.Duration
+}
+
+// NewWebhookService 创建 Webhook 服务实例，轮询间隔由环境变量 WEBHOOK_INTERVAL（秒）指定，默认15秒
+func NewWebhookService(db *gorm.DB) *WebhookService {
+	return &WebhookService{
+		db:       db,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		outbox:   newOutbox(db, &models.WebhookDelivery{}, models.WebhookStatusPending, models.WebhookStatusSending),
+		interval: time.Duration(getEnvInt64OrDefault("WEBHOOK_INTERVAL", 15)) * time.Second,
+	}
+}
+
+// CreateSubscription 创建 Webhook 订阅，未提供签名密钥时自动生成
+func (s *WebhookService) CreateSubscription(req *models.CreateWebhookRequest) (*models.CreateWebhookResponse, error) {
+	target, err := url.Parse(req.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return nil, fmt.Errorf("Webhook 地址必须是 http 或 https 网址")
+	}
+
+	if req.PoolID != nil {
+		var pool models.MatchPool
+		if err := s.db.First(&pool, *req.PoolID).Error; err != nil {
+			return nil, fmt.Errorf("匹配池不存在")
+		}
+	}
+
+	for _, event := range req.Events {
+		supported := false
+		for _, known := range webhookEvents {
+			if event == known {
+				supported = true
+				break
+			}
+		}
+		if !supported {
+			return nil, fmt.Errorf("不支持的事件: %s", event)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		if secret, err = randomHex(24); err != nil {
+			return nil, fmt.Errorf("生成签名密钥失败: %v", err)
+		}
+	}
+
+	subscription := models.WebhookSubscription{
+		PoolID: req.PoolID,
+		URL:    req.URL,
+		Secret: secret,
+		Active: true,
+	}
+	if len(req.Events) > 0 {
+		events, _ := json.Marshal(req.Events)
+		subscription.Events = events
+	}
+	if err := s.db.Create(&subscription).Error; err != nil {
+		return nil, fmt.Errorf("创建 Webhook 订阅失败: %v", err)
+	}
+
+	log.Printf("🪝 创建 Webhook 订阅: %d -> %s", subscription.ID, subscription.URL)
+	return &models.CreateWebhookResponse{WebhookSubscription: subscription, Secret: secret}, nil
+}
+
+// GetSubscriptions 获取全部 Webhook 订阅（不含签名密钥）
+func (s *WebhookService) GetSubscriptions() ([]models.WebhookSubscription, error) {
+	subscriptions := []models.WebhookSubscription{}
+	if err := s.db.Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, err
+	}
+	return subscriptions, nil
+}
+
+// DeleteSubscription 删除 Webhook 订阅及其投递记录
+func (s *WebhookService) DeleteSubscription(id uint) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.WebhookSubscription{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("Webhook 订阅不存在")
+		}
+		return tx.Where("subscription_id = ?", id).Delete(&models.WebhookDelivery{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("🪝 删除 Webhook 订阅: %d", id)
+	return nil
+}
+
+// GetDeliveries 获取订阅最近的投递记录，按时间从新到旧排列
+func (s *WebhookService) GetDeliveries(subscriptionID uint, limit int) ([]models.WebhookDelivery, error) {
+	var subscription models.WebhookSubscription
+	if err := s.db.First(&subscription, subscriptionID).Error; err != nil {
+		return nil, fmt.Errorf("Webhook 订阅不存在")
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	deliveries := []models.WebhookDelivery{}
+	if err := s.db.Where("subscription_id = ?", subscriptionID).
+		Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// webhookUserData 发送给第三方的用户数据只包含公开字段，读取字段设置失败时不发送用户数据
+func webhookUserData(db *gorm.DB, poolID uint, userData json.RawMessage) map[string]interface{} {
+	data, err := NewVisibilityService(db).PublicUserData(poolID, userData)
+	if err != nil {
+		log.Printf("⚠️ 读取用户公开数据失败: Pool %d: %v", poolID, err)
+		return map[string]interface{}{}
+	}
+	return data
+}
+
+// Emit 为订阅了该事件的 Webhook（该匹配池的订阅及全局订阅）保存投递记录，随后立即尝试投递；
+// 在事件所在的事务提交后调用，失败只记录日志，不影响业务操作
+func (s *WebhookService) Emit(event string, poolID uint, data interface{}) {
+	var subscriptions []models.WebhookSubscription
+	if err := s.db.Where("active = ? AND (pool_id IS NULL OR pool_id = ?)", true, poolID).
+		Find(&subscriptions).Error; err != nil {
+		log.Printf("⚠️ 查询 Webhook 订阅失败: %v", err)
+		return
+	}
+
+	var targets []models.WebhookSubscription
+	for _, subscription := range subscriptions {
+		events, err := subscription.EventList()
+		if err != nil {
+			continue
+		}
+		if len(events) == 0 {
+			targets = append(targets, subscription)
+			continue
+		}
+		for _, subscribed := range events {
+			if subscribed == event {
+				targets = append(targets, subscription)
+				break
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	eventID, err := randomHex(16)
+	if err != nil {
+		log.Printf("⚠️ 生成 Webhook 事件ID失败: %v", err)
+		return
+	}
+	now := time.Now()
+	payload, err := json.Marshal(webhookPayload{
+		ID:        eventID,
+		Event:     event,
+		PoolID:    poolID,
+		CreatedAt: now.Format(time.RFC3339),
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("⚠️ 序列化 Webhook 载荷失败: %v", err)
+		return
+	}
+
+	for _, subscription := range targets {
+		if err := s.db.Create(&models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        eventID,
+			Event:          event,
+			PoolID:         poolID,
+			Payload:        string(payload),
+			Status:         models.WebhookStatusPending,
+			NextAttemptAt:  now,
+		}).Error; err != nil {
+			log.Printf("⚠️ 保存 Webhook 投递记录失败: %v", err)
+		}
+	}
+
+	log.Printf("🪝 Webhook 事件 %s: Pool %d，%d 个订阅", event, poolID, len(targets))
+	go s.DeliverDue()
+}
+
+// Start 在后台启动投递循环，同时检查刚超过截止时间的匹配池
+func (s *WebhookService) Start() {
+	log.Printf("🪝 Webhook 投递服务已启动，实例: %s，轮询间隔: %v", s.outbox.instanceID, s.interval)
+
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			s.emitExpiredPools()
+			s.DeliverDue()
+			<-ticker.C
+		}
+	}()
+}
+
+// emitExpiredPools 为超过截止时间的开放匹配池发出 pool.expired 事件，每个截止时间只发一次；
+// 只检查最近一天内截止的匹配池，避免首次启用时为早已截止的匹配池补发
+func (s *WebhookService) emitExpiredPools() {
+	now := time.Now()
+
+	var pools []models.MatchPool
+	if err := s.db.Where("status = ? AND valid_until <= ? AND valid_until > ? AND (expired_event_at IS NULL OR expired_event_at < valid_until)",
+		models.PoolStatusOpen, now, now.Add(-24*time.Hour)).
+		Find(&pools).Error; err != nil {
+		log.Printf("⚠️ 查询已截止的匹配池失败: %v", err)
+		return
+	}
+
+	for _, pool := range pools {
+		// 以条件更新认领，多实例部署时只有一个实例发出事件
+		result := s.db.Model(&models.MatchPool{}).
+			Where("id = ? AND (expired_event_at IS NULL OR expired_event_at < valid_until)", pool.ID).
+			Update("expired_event_at", now)
+		if result.Error != nil || result.RowsAffected != 1 {
+			continue
+		}
+
+		publishStatusEvent(pool.ID, models.PoolStatusOpen, models.PoolStatusClosed)
+		s.Emit(models.WebhookEventPoolExpired, pool.ID, map[string]interface{}{
+			"poolId":        pool.ID,
+			"name":          pool.Name,
+			"validUntil":    pool.ValidUntil.Format("2006-01-02 15:04:05"),
+			"userCount":     pool.GetUserCount(s.db),
+			"waitlistCount": pool.GetWaitlistCount(s.db),
+		})
+	}
+}
+
+// DeliverDue 投递所有到期的记录，包括锁已过期（领取实例可能已崩溃）的记录
+func (s *WebhookService) DeliverDue() {
+	var deliveries []models.WebhookDelivery
+	if err := s.outbox.due(s.db, &deliveries); err != nil {
+		log.Printf("⚠️ 查询待投递的 Webhook 失败: %v", err)
+		return
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		if s.outbox.claim(delivery.ID, delivery.Status, &delivery.Attempts) {
+			s.deliver(delivery)
+		}
+	}
+}
+
+// deliver 发送一次投递：请求头携带事件名、投递ID、时间戳和签名，对方返回 2xx 视为成功
+func (s *WebhookService) deliver(delivery *models.WebhookDelivery) {
+	var subscription models.WebhookSubscription
+	if err := s.db.First(&subscription, delivery.SubscriptionID).Error; err != nil {
+		s.finish(delivery, models.WebhookStatusFailed, 0, "Webhook 订阅已不存在")
+		return
+	}
+
+	body := []byte(delivery.Payload)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		s.finish(delivery, models.WebhookStatusFailed, 0, err.Error())
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Christmas-Link-Webhook/1.0")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+signWebhook(subscription.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.retryOrFail(delivery, 0, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		s.retryOrFail(delivery, resp.StatusCode, fmt.Sprintf("对方返回 HTTP %d", resp.StatusCode))
+		return
+	}
+	s.finish(delivery, models.WebhookStatusDelivered, resp.StatusCode, "")
+}
+
+// retryOrFail 未达到最大次数时按指数退避（30秒、1分钟、2分钟……）重试，否则标记失败
+func (s *WebhookService) retryOrFail(delivery *models.WebhookDelivery, responseStatus int, lastError string) {
+	if delivery.Attempts >= maxWebhookAttempts {
+		s.finish(delivery, models.WebhookStatusFailed, responseStatus, lastError)
+		return
+	}
+
+	nextAttemptAt := time.Now().Add(30 * time.Second << (delivery.Attempts - 1))
+	s.outbox.retry(delivery.ID, nextAttemptAt, lastError, map[string]interface{}{"response_status": responseStatus})
+	log.Printf("⚠️ Webhook 投递 %d 失败，将于 %s 重试: %s", delivery.ID, nextAttemptAt.Format("2006-01-02 15:04:05"), lastError)
+}
+
+// finish 记录投递的最终结果
+func (s *WebhookService) finish(delivery *models.WebhookDelivery, status string, responseStatus int, lastError string) {
+	extra := map[string]interface{}{"response_status": responseStatus}
+	if status == models.WebhookStatusDelivered {
+		extra["delivered_at"] = time.Now()
+	}
+	s.outbox.finish(delivery.ID, status, lastError, extra)
+
+	if status == models.WebhookStatusDelivered {
+		log.Printf("🪝 Webhook 投递 %d 成功: %s", delivery.ID, delivery.Event)
+	} else {
+		log.Printf("❌ Webhook 投递 %d 失败: %s, %s", delivery.ID, delivery.Event, lastError)
+	}
+}
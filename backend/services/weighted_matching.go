@@ -0,0 +1,623 @@
+package services
+
+// weightedEdge 带权边，连接顶点 u 与 v
+type weightedEdge struct {
+	u, v   int
+	weight int64
+}
+
+// maxWeightMatching 求一般图的最大权匹配（Edmonds 带花算法，O(n³)），
+// maxCardinality 为 true 时在所有最大基数匹配中求权值最大者。
+// 返回 mate 数组：mate[i] 为与顶点 i 匹配的顶点，未匹配时为 -1。
+// 实现参考 Joris van Rantwijk 的 mwmatching，全部使用整数运算；对偶变量保存为实际值的两倍
+func maxWeightMatching(vertexCount int, edges []weightedEdge, maxCardinality bool) []int {
+	m := &blossomMatcher{
+		n:              vertexCount,
+		edges:          edges,
+		maxCardinality: maxCardinality,
+	}
+	return m.solve()
+}
+
+// blossomMatcher 带花算法的求解状态
+type blossomMatcher struct {
+	n              int
+	edges          []weightedEdge
+	maxCardinality bool
+
+	endpoint         []int   // endpoint[p] 为端点 p 所在的顶点，边 k 的端点为 2k 和 2k+1
+	neighbend        [][]int // neighbend[v] 为与顶点 v 相连的远端端点
+	mate             []int   // mate[v] 为与 v 匹配的远端端点，未匹配时为 -1
+	label            []int   // 0：未标记，1：S，2：T（5 为 scanBlossom 中的临时标记）
+	labelend         []int
+	inblossom        []int
+	blossomparent    []int
+	blossomchilds    [][]int
+	blossombase      []int
+	blossomendps     [][]int
+	bestedge         []int
+	blossombestedges [][]int
+	unusedblossoms   []int
+	dualvar          []int64
+	allowedge        []bool
+	queue            []int
+}
+
+// slack 边 k 的松弛量（两倍值）
+func (m *blossomMatcher) slack(k int) int64 {
+	e := m.edges[k]
+	return m.dualvar[e.u] + m.dualvar[e.v] - 2*e.weight
+}
+
+// blossomLeaves 返回花 b 中的所有顶点
+func (m *blossomMatcher) blossomLeaves(b int) []int {
+	if b < m.n {
+		return []int{b}
+	}
+	var leaves []int
+	for _, t := range m.blossomchilds[b] {
+		leaves = append(leaves, m.blossomLeaves(t)...)
+	}
+	return leaves
+}
+
+// assignLabel 为顶点 w 所在的顶层花设置标记 t，p 为到达该花的边的端点
+func (m *blossomMatcher) assignLabel(w, t, p int) {
+	b := m.inblossom[w]
+	m.label[w], m.label[b] = t, t
+	m.labelend[w], m.labelend[b] = p, p
+	m.bestedge[w], m.bestedge[b] = -1, -1
+	if t == 1 {
+		m.queue = append(m.queue, m.blossomLeaves(b)...)
+	} else if t == 2 {
+		base := m.blossombase[b]
+		m.assignLabel(m.endpoint[m.mate[base]], 1, m.mate[base]^1)
+	}
+}
+
+// scanBlossom 沿交错树回溯 v 与 w，找到新花的基点；若两者属于不同的树（发现增广路）则返回 -1
+func (m *blossomMatcher) scanBlossom(v, w int) int {
+	var path []int
+	base := -1
+	for v != -1 || w != -1 {
+		b := m.inblossom[v]
+		if m.label[b]&4 != 0 {
+			base = m.blossombase[b]
+			break
+		}
+		path = append(path, b)
+		m.label[b] = 5
+		if m.labelend[b] == -1 {
+			v = -1
+		} else {
+			v = m.endpoint[m.labelend[b]]
+			b = m.inblossom[v]
+			v = m.endpoint[m.labelend[b]]
+		}
+		if w != -1 {
+			v, w = w, v
+		}
+	}
+	for _, b := range path {
+		m.label[b] = 1
+	}
+	return base
+}
+
+// addBlossom 以 base 为基点、经由边 k 构造新的花
+func (m *blossomMatcher) addBlossom(base, k int) {
+	v, w := m.edges[k].u, m.edges[k].v
+	bb := m.inblossom[base]
+	bv := m.inblossom[v]
+	bw := m.inblossom[w]
+
+	b := m.unusedblossoms[len(m.unusedblossoms)-1]
+	m.unusedblossoms = m.unusedblossoms[:len(m.unusedblossoms)-1]
+	m.blossombase[b] = base
+	m.blossomparent[b] = -1
+	m.blossomparent[bb] = b
+
+	var path, endps []int
+	for bv != bb {
+		m.blossomparent[bv] = b
+		path = append(path, bv)
+		endps = append(endps, m.labelend[bv])
+		v = m.endpoint[m.labelend[bv]]
+		bv = m.inblossom[v]
+	}
+	path = append(path, bb)
+	reverseInts(path)
+	reverseInts(endps)
+	endps = append(endps, 2*k)
+	for bw != bb {
+		m.blossomparent[bw] = b
+		path = append(path, bw)
+		endps = append(endps, m.labelend[bw]^1)
+		w = m.endpoint[m.labelend[bw]]
+		bw = m.inblossom[w]
+	}
+	m.blossomchilds[b] = path
+	m.blossomendps[b] = endps
+
+	m.label[b] = 1
+	m.labelend[b] = m.labelend[bb]
+	m.dualvar[b] = 0
+	for _, leaf := range m.blossomLeaves(b) {
+		if m.label[m.inblossom[leaf]] == 2 {
+			m.queue = append(m.queue, leaf)
+		}
+		m.inblossom[leaf] = b
+	}
+
+	// 计算新花到各 S 花的最小松弛边
+	bestedgeto := make([]int, 2*m.n)
+	for i := range bestedgeto {
+		bestedgeto[i] = -1
+	}
+	for _, child := range path {
+		var nblists [][]int
+		if m.blossombestedges[child] == nil {
+			for _, leaf := range m.blossomLeaves(child) {
+				list := make([]int, len(m.neighbend[leaf]))
+				for i, p := range m.neighbend[leaf] {
+					list[i] = p / 2
+				}
+				nblists = append(nblists, list)
+			}
+		} else {
+			nblists = [][]int{m.blossombestedges[child]}
+		}
+		for _, nblist := range nblists {
+			for _, e := range nblist {
+				i, j := m.edges[e].u, m.edges[e].v
+				if m.inblossom[j] == b {
+					i, j = j, i
+				}
+				_ = i
+				bj := m.inblossom[j]
+				if bj != b && m.label[bj] == 1 && (bestedgeto[bj] == -1 || m.slack(e) < m.slack(bestedgeto[bj])) {
+					bestedgeto[bj] = e
+				}
+			}
+		}
+		m.blossombestedges[child] = nil
+		m.bestedge[child] = -1
+	}
+
+	var best []int
+	for _, e := range bestedgeto {
+		if e != -1 {
+			best = append(best, e)
+		}
+	}
+	m.blossombestedges[b] = best
+	m.bestedge[b] = -1
+	for _, e := range best {
+		if m.bestedge[b] == -1 || m.slack(e) < m.slack(m.bestedge[b]) {
+			m.bestedge[b] = e
+		}
+	}
+}
+
+// expandBlossom 展开花 b；endstage 为 true 时表示阶段结束时展开对偶变量为零的 S 花
+func (m *blossomMatcher) expandBlossom(b int, endstage bool) {
+	for _, s := range m.blossomchilds[b] {
+		m.blossomparent[s] = -1
+		if s < m.n {
+			m.inblossom[s] = s
+		} else if endstage && m.dualvar[s] == 0 {
+			m.expandBlossom(s, endstage)
+		} else {
+			for _, leaf := range m.blossomLeaves(s) {
+				m.inblossom[leaf] = s
+			}
+		}
+	}
+
+	if !endstage && m.label[b] == 2 {
+		// 展开 T 花时需要重新标记子花，保持交错树结构
+		childs := m.blossomchilds[b]
+		entrychild := m.inblossom[m.endpoint[m.labelend[b]^1]]
+		j := indexOf(childs, entrychild)
+		var jstep, endptrick int
+		if j&1 != 0 {
+			j -= len(childs)
+			jstep = 1
+			endptrick = 0
+		} else {
+			jstep = -1
+			endptrick = 1
+		}
+
+		p := m.labelend[b]
+		for j != 0 {
+			m.label[m.endpoint[p^1]] = 0
+			m.label[m.endpoint[m.blossomendps[b][wrap(j-endptrick, len(childs))]^endptrick^1]] = 0
+			m.assignLabel(m.endpoint[p^1], 2, p)
+			m.allowedge[m.blossomendps[b][wrap(j-endptrick, len(childs))]/2] = true
+			j += jstep
+			p = m.blossomendps[b][wrap(j-endptrick, len(childs))] ^ endptrick
+			m.allowedge[p/2] = true
+			j += jstep
+		}
+
+		bv := childs[wrap(j, len(childs))]
+		m.label[m.endpoint[p^1]], m.label[bv] = 2, 2
+		m.labelend[m.endpoint[p^1]], m.labelend[bv] = p, p
+		m.bestedge[bv] = -1
+		j += jstep
+
+		for childs[wrap(j, len(childs))] != entrychild {
+			bv = childs[wrap(j, len(childs))]
+			if m.label[bv] == 1 {
+				j += jstep
+				continue
+			}
+			labeled := -1
+			for _, leaf := range m.blossomLeaves(bv) {
+				if m.label[leaf] != 0 {
+					labeled = leaf
+					break
+				}
+			}
+			if labeled != -1 {
+				m.label[labeled] = 0
+				m.label[m.endpoint[m.mate[m.blossombase[bv]]]] = 0
+				m.assignLabel(labeled, 2, m.labelend[labeled])
+			}
+			j += jstep
+		}
+	}
+
+	m.label[b], m.labelend[b] = -1, -1
+	m.blossomchilds[b], m.blossomendps[b] = nil, nil
+	m.blossombase[b] = -1
+	m.blossombestedges[b] = nil
+	m.bestedge[b] = -1
+	m.unusedblossoms = append(m.unusedblossoms, b)
+}
+
+// augmentBlossom 沿花 b 内部交换匹配边，使顶点 v 成为花的新基点
+func (m *blossomMatcher) augmentBlossom(b, v int) {
+	t := v
+	for m.blossomparent[t] != b {
+		t = m.blossomparent[t]
+	}
+	if t >= m.n {
+		m.augmentBlossom(t, v)
+	}
+
+	childs := m.blossomchilds[b]
+	i := indexOf(childs, t)
+	j := i
+	var jstep, endptrick int
+	if i&1 != 0 {
+		j -= len(childs)
+		jstep = 1
+		endptrick = 0
+	} else {
+		jstep = -1
+		endptrick = 1
+	}
+
+	for j != 0 {
+		j += jstep
+		t = childs[wrap(j, len(childs))]
+		p := m.blossomendps[b][wrap(j-endptrick, len(childs))] ^ endptrick
+		if t >= m.n {
+			m.augmentBlossom(t, m.endpoint[p])
+		}
+		j += jstep
+		t = childs[wrap(j, len(childs))]
+		if t >= m.n {
+			m.augmentBlossom(t, m.endpoint[p^1])
+		}
+		m.mate[m.endpoint[p]] = p ^ 1
+		m.mate[m.endpoint[p^1]] = p
+	}
+
+	m.blossomchilds[b] = append(append([]int(nil), childs[i:]...), childs[:i]...)
+	endps := m.blossomendps[b]
+	m.blossomendps[b] = append(append([]int(nil), endps[i:]...), endps[:i]...)
+	m.blossombase[b] = m.blossombase[m.blossomchilds[b][0]]
+}
+
+// augmentMatching 沿经过边 k 的增广路交换匹配边
+func (m *blossomMatcher) augmentMatching(k int) {
+	ends := [2][2]int{{m.edges[k].u, 2*k + 1}, {m.edges[k].v, 2 * k}}
+	for _, end := range ends {
+		s, p := end[0], end[1]
+		for {
+			bs := m.inblossom[s]
+			if bs >= m.n {
+				m.augmentBlossom(bs, s)
+			}
+			m.mate[s] = p
+			if m.labelend[bs] == -1 {
+				break
+			}
+			t := m.endpoint[m.labelend[bs]]
+			bt := m.inblossom[t]
+			s = m.endpoint[m.labelend[bt]]
+			j := m.endpoint[m.labelend[bt]^1]
+			if bt >= m.n {
+				m.augmentBlossom(bt, j)
+			}
+			m.mate[j] = m.labelend[bt]
+			p = m.labelend[bt] ^ 1
+		}
+	}
+}
+
+// solve 执行带花算法的主循环
+func (m *blossomMatcher) solve() []int {
+	n := m.n
+	mate := make([]int, n)
+	for i := range mate {
+		mate[i] = -1
+	}
+	if len(m.edges) == 0 {
+		return mate
+	}
+
+	var maxweight int64
+	for _, e := range m.edges {
+		if e.weight > maxweight {
+			maxweight = e.weight
+		}
+	}
+
+	m.endpoint = make([]int, 2*len(m.edges))
+	m.neighbend = make([][]int, n)
+	for k, e := range m.edges {
+		m.endpoint[2*k] = e.u
+		m.endpoint[2*k+1] = e.v
+		m.neighbend[e.u] = append(m.neighbend[e.u], 2*k+1)
+		m.neighbend[e.v] = append(m.neighbend[e.v], 2*k)
+	}
+
+	m.mate = mate
+	m.label = make([]int, 2*n)
+	m.labelend = filledInts(2*n, -1)
+	m.inblossom = make([]int, n)
+	for i := range m.inblossom {
+		m.inblossom[i] = i
+	}
+	m.blossomparent = filledInts(2*n, -1)
+	m.blossomchilds = make([][]int, 2*n)
+	m.blossombase = filledInts(2*n, -1)
+	for i := 0; i < n; i++ {
+		m.blossombase[i] = i
+	}
+	m.blossomendps = make([][]int, 2*n)
+	m.bestedge = filledInts(2*n, -1)
+	m.blossombestedges = make([][]int, 2*n)
+	for b := 2*n - 1; b >= n; b-- {
+		m.unusedblossoms = append(m.unusedblossoms, b)
+	}
+	m.dualvar = make([]int64, 2*n)
+	for i := 0; i < n; i++ {
+		m.dualvar[i] = maxweight
+	}
+	m.allowedge = make([]bool, len(m.edges))
+
+	for stage := 0; stage < n; stage++ {
+		// 每个阶段开始时清空标记，所有未匹配顶点标记为 S
+		for i := range m.label {
+			m.label[i] = 0
+			m.bestedge[i] = -1
+		}
+		for b := n; b < 2*n; b++ {
+			m.blossombestedges[b] = nil
+		}
+		for k := range m.allowedge {
+			m.allowedge[k] = false
+		}
+		m.queue = m.queue[:0]
+		for v := 0; v < n; v++ {
+			if m.mate[v] == -1 && m.label[m.inblossom[v]] == 0 {
+				m.assignLabel(v, 1, -1)
+			}
+		}
+
+		augmented := false
+		for {
+			// 扩展交错树，直到找到增广路或无法继续
+			for len(m.queue) > 0 && !augmented {
+				v := m.queue[len(m.queue)-1]
+				m.queue = m.queue[:len(m.queue)-1]
+
+				for _, p := range m.neighbend[v] {
+					k := p / 2
+					w := m.endpoint[p]
+					if m.inblossom[v] == m.inblossom[w] {
+						continue
+					}
+					var kslack int64
+					if !m.allowedge[k] {
+						kslack = m.slack(k)
+						if kslack <= 0 {
+							m.allowedge[k] = true
+						}
+					}
+					if m.allowedge[k] {
+						if m.label[m.inblossom[w]] == 0 {
+							m.assignLabel(w, 2, p^1)
+						} else if m.label[m.inblossom[w]] == 1 {
+							base := m.scanBlossom(v, w)
+							if base >= 0 {
+								m.addBlossom(base, k)
+							} else {
+								m.augmentMatching(k)
+								augmented = true
+								break
+							}
+						} else if m.label[w] == 0 {
+							m.label[w] = 2
+							m.labelend[w] = p ^ 1
+						}
+					} else if m.label[m.inblossom[w]] == 1 {
+						b := m.inblossom[v]
+						if m.bestedge[b] == -1 || kslack < m.slack(m.bestedge[b]) {
+							m.bestedge[b] = k
+						}
+					} else if m.label[w] == 0 {
+						if m.bestedge[w] == -1 || kslack < m.slack(m.bestedge[w]) {
+							m.bestedge[w] = k
+						}
+					}
+				}
+			}
+			if augmented {
+				break
+			}
+
+			// 计算对偶变量的调整量
+			deltatype := -1
+			var delta int64
+			deltaedge, deltablossom := -1, -1
+
+			if !m.maxCardinality {
+				deltatype = 1
+				delta = minInt64(m.dualvar[:n])
+			}
+			for v := 0; v < n; v++ {
+				if m.label[m.inblossom[v]] == 0 && m.bestedge[v] != -1 {
+					d := m.slack(m.bestedge[v])
+					if deltatype == -1 || d < delta {
+						delta = d
+						deltatype = 2
+						deltaedge = m.bestedge[v]
+					}
+				}
+			}
+			for b := 0; b < 2*n; b++ {
+				if m.blossomparent[b] == -1 && m.label[b] == 1 && m.bestedge[b] != -1 {
+					d := m.slack(m.bestedge[b]) / 2
+					if deltatype == -1 || d < delta {
+						delta = d
+						deltatype = 3
+						deltaedge = m.bestedge[b]
+					}
+				}
+			}
+			for b := n; b < 2*n; b++ {
+				if m.blossombase[b] >= 0 && m.blossomparent[b] == -1 && m.label[b] == 2 &&
+					(deltatype == -1 || m.dualvar[b] < delta) {
+					delta = m.dualvar[b]
+					deltatype = 4
+					deltablossom = b
+				}
+			}
+			if deltatype == -1 {
+				// 最大基数模式下已无法继续扩展，做最后一次对偶调整后结束
+				deltatype = 1
+				delta = minInt64(m.dualvar[:n])
+				if delta < 0 {
+					delta = 0
+				}
+			}
+
+			for v := 0; v < n; v++ {
+				switch m.label[m.inblossom[v]] {
+				case 1:
+					m.dualvar[v] -= delta
+				case 2:
+					m.dualvar[v] += delta
+				}
+			}
+			for b := n; b < 2*n; b++ {
+				if m.blossombase[b] >= 0 && m.blossomparent[b] == -1 {
+					switch m.label[b] {
+					case 1:
+						m.dualvar[b] += delta
+					case 2:
+						m.dualvar[b] -= delta
+					}
+				}
+			}
+
+			if deltatype == 1 {
+				break
+			} else if deltatype == 2 {
+				m.allowedge[deltaedge] = true
+				i, j := m.edges[deltaedge].u, m.edges[deltaedge].v
+				if m.label[m.inblossom[i]] == 0 {
+					i, j = j, i
+				}
+				_ = j
+				m.queue = append(m.queue, i)
+			} else if deltatype == 3 {
+				m.allowedge[deltaedge] = true
+				m.queue = append(m.queue, m.edges[deltaedge].u)
+			} else if deltatype == 4 {
+				m.expandBlossom(deltablossom, false)
+			}
+		}
+
+		if !augmented {
+			break
+		}
+
+		// 阶段结束，展开对偶变量为零的顶层 S 花
+		for b := n; b < 2*n; b++ {
+			if m.blossomparent[b] == -1 && m.blossombase[b] >= 0 && m.label[b] == 1 && m.dualvar[b] == 0 {
+				m.expandBlossom(b, true)
+			}
+		}
+	}
+
+	result := make([]int, n)
+	for v := 0; v < n; v++ {
+		result[v] = -1
+		if m.mate[v] >= 0 {
+			result[v] = m.endpoint[m.mate[v]]
+		}
+	}
+	return result
+}
+
+// reverseInts 原地反转切片
+func reverseInts(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
+
+// indexOf 返回 v 在切片中的下标
+func indexOf(s []int, v int) int {
+	for i, x := range s {
+		if x == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// wrap 将可能为负的下标转换为有效下标（对应 Python 的负数索引）
+func wrap(i, n int) int {
+	if i < 0 {
+		return i + n
+	}
+	return i
+}
+
+// filledInts 创建填充指定值的切片
+func filledInts(n, value int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = value
+	}
+	return s
+}
+
+// minInt64 返回切片中的最小值
+func minInt64(s []int64) int64 {
+	min := s[0]
+	for _, v := range s[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// matchingValue 校验 mate 是合法的匹配（对称且只使用给定的边），返回匹配边数和总权重
+func matchingValue(t *testing.T, n int, edges []weightedEdge, mate []int) (int, int64) {
+	t.Helper()
+	if len(mate) != n {
+		t.Fatalf("mate 长度为 %d，期望 %d", len(mate), n)
+	}
+
+	weights := make(map[[2]int]int64)
+	for _, e := range edges {
+		weights[[2]int{e.u, e.v}] = e.weight
+		weights[[2]int{e.v, e.u}] = e.weight
+	}
+
+	count := 0
+	var total int64
+	for i, j := range mate {
+		if j == -1 {
+			continue
+		}
+		if j < 0 || j >= n || mate[j] != i || j == i {
+			t.Fatalf("mate 不是合法的匹配: %v", mate)
+		}
+		w, ok := weights[[2]int{i, j}]
+		if !ok {
+			t.Fatalf("匹配使用了不存在的边 %d-%d: %v", i, j, mate)
+		}
+		if i < j {
+			count++
+			total += w
+		}
+	}
+	return count, total
+}
+
+// bruteForceMatching 枚举所有匹配，返回最优匹配的边数和总权重；
+// maxCardinality 为 true 时先比较边数再比较权重
+func bruteForceMatching(n int, edges []weightedEdge, maxCardinality bool) (int, int64) {
+	weight := make([][]int64, n)
+	exists := make([][]bool, n)
+	for i := range weight {
+		weight[i] = make([]int64, n)
+		exists[i] = make([]bool, n)
+	}
+	for _, e := range edges {
+		weight[e.u][e.v], weight[e.v][e.u] = e.weight, e.weight
+		exists[e.u][e.v], exists[e.v][e.u] = true, true
+	}
+
+	better := func(count int, total int64, bestCount int, bestTotal int64) bool {
+		if maxCardinality && count != bestCount {
+			return count > bestCount
+		}
+		return total > bestTotal
+	}
+
+	used := make([]bool, n)
+	var search func(i, count int, total int64) (int, int64)
+	search = func(i, count int, total int64) (int, int64) {
+		for i < n && used[i] {
+			i++
+		}
+		if i == n {
+			return count, total
+		}
+
+		// 顶点 i 不参与匹配
+		used[i] = true
+		bestCount, bestTotal := search(i+1, count, total)
+		for j := i + 1; j < n; j++ {
+			if used[j] || !exists[i][j] {
+				continue
+			}
+			used[j] = true
+			c, w := search(i+1, count+1, total+weight[i][j])
+			if better(c, w, bestCount, bestTotal) {
+				bestCount, bestTotal = c, w
+			}
+			used[j] = false
+		}
+		used[i] = false
+		return bestCount, bestTotal
+	}
+	return search(0, 0, 0)
+}
+
+// TestMaxWeightMatchingKnownCases 已知结果的小图
+func TestMaxWeightMatchingKnownCases(t *testing.T) {
+	tests := []struct {
+		name           string
+		n              int
+		edges          []weightedEdge
+		maxCardinality bool
+		want           []int
+	}{
+		{"空图", 0, nil, false, []int{}},
+		{"单条边", 2, []weightedEdge{{0, 1, 1}}, false, []int{1, 0}},
+		{"权重大的边优先", 3, []weightedEdge{{0, 1, 10}, {1, 2, 11}}, false, []int{-1, 2, 1}},
+		{"最大权", 4, []weightedEdge{{0, 1, 5}, {1, 2, 11}, {2, 3, 5}}, false, []int{-1, 2, 1, -1}},
+		{"最大基数", 4, []weightedEdge{{0, 1, 5}, {1, 2, 11}, {2, 3, 5}}, true, []int{1, 0, 3, 2}},
+		{"忽略负权边", 2, []weightedEdge{{0, 1, -1}}, false, []int{-1, -1}},
+		{"最大基数时使用负权边", 2, []weightedEdge{{0, 1, -1}}, true, []int{1, 0}},
+		// 需要构造并展开花的奇环
+		{"奇环", 6, []weightedEdge{{0, 1, 9}, {0, 2, 9}, {1, 2, 10}, {1, 3, 8}, {2, 4, 8}, {3, 4, 10}, {4, 5, 6}}, false, []int{2, 3, 0, 1, 5, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mate := maxWeightMatching(tt.n, tt.edges, tt.maxCardinality)
+			if fmt.Sprint(mate) != fmt.Sprint(tt.want) {
+				t.Errorf("匹配结果为 %v，期望 %v", mate, tt.want)
+			}
+		})
+	}
+}
+
+// TestMaxWeightMatchingBruteForce 随机小图上与穷举得到的最优值比较，覆盖奇数顶点数和两种 maxCardinality
+func TestMaxWeightMatchingBruteForce(t *testing.T) {
+	tests := []struct {
+		vertices       []int
+		density        float64
+		minWeight      int64
+		maxWeight      int64
+		maxCardinality bool
+	}{
+		{[]int{1, 2, 3, 4, 5}, 0.8, 1, 10, false},
+		{[]int{1, 2, 3, 4, 5}, 0.8, 1, 10, true},
+		{[]int{6, 7, 8, 9}, 0.3, 1, 20, false},
+		{[]int{6, 7, 8, 9}, 0.3, 1, 20, true},
+		{[]int{6, 7, 8, 9}, 0.7, 1, 20, false},
+		{[]int{6, 7, 8, 9}, 0.7, 1, 20, true},
+		{[]int{7, 8, 9, 10}, 1, 1, 5, false},
+		{[]int{7, 8, 9, 10}, 1, 1, 5, true},
+		{[]int{5, 6, 7, 8}, 0.6, -10, 10, false},
+		{[]int{5, 6, 7, 8}, 0.6, -10, 10, true},
+	}
+
+	rng := rand.New(rand.NewSource(1227))
+	for _, tt := range tests {
+		for _, n := range tt.vertices {
+			name := fmt.Sprintf("%d个顶点_密度%.1f_权重%d到%d_maxCardinality=%v", n, tt.density, tt.minWeight, tt.maxWeight, tt.maxCardinality)
+			t.Run(name, func(t *testing.T) {
+				for trial := 0; trial < 60; trial++ {
+					var edges []weightedEdge
+					for i := 0; i < n; i++ {
+						for j := i + 1; j < n; j++ {
+							if rng.Float64() < tt.density {
+								weight := tt.minWeight + rng.Int63n(tt.maxWeight-tt.minWeight+1)
+								edges = append(edges, weightedEdge{u: i, v: j, weight: weight})
+							}
+						}
+					}
+
+					mate := maxWeightMatching(n, edges, tt.maxCardinality)
+					count, total := matchingValue(t, n, edges, mate)
+					wantCount, wantTotal := bruteForceMatching(n, edges, tt.maxCardinality)
+					if total != wantTotal || (tt.maxCardinality && count != wantCount) {
+						t.Fatalf("边 %v：匹配 %v 为 %d 条边、总权重 %d，最优为 %d 条边、总权重 %d",
+							edges, mate, count, total, wantCount, wantTotal)
+					}
+				}
+			})
+		}
+	}
+}
@@ -33,6 +33,7 @@ export const API_ENDPOINTS = {
   // 匹配池管理
   POOLS: '/api/pools',
   POOL_BY_ID: (id: string) => `/api/pools/${id}`,
+  POOL_EVENTS: (id: string) => `/api/pools/${id}/events`,
   JOIN_POOL: '/api/pools/join',
   
   // 匹配功能
@@ -70,13 +71,36 @@ export const apiRequest = async (
   });
 
   if (!response.ok) {
-    throw new Error(`API 请求失败: ${response.status} ${response.statusText}`);
+    // 尽量使用后端返回的错误信息，并附带 data（如逐字段的校验错误 fieldErrors）
+    const body = await response.json().catch(() => null);
+    const error = new Error(body?.message || `API 请求失败: ${response.status} ${response.statusText}`) as Error & { data?: any };
+    error.data = body?.data;
+    throw error;
   }
 
   return response.json();
 };
 
 // API 方法
+// 匹配池实时事件（Server-Sent Events）
+export interface PoolEvent {
+  type: string;
+  poolId: number;
+  data: any;
+  timestamp: string;
+}
+
+export const POOL_EVENT_TYPES = [
+  'snapshot',
+  'participant.joined',
+  'participant.left',
+  'participant.verified',
+  'status.changed',
+  'match.completed',
+  'pool.updated',
+  'pool.deleted',
+] as const;
+
 export const api = {
   // 匹配池管理
   createPool: (data: any) => 
@@ -91,6 +115,15 @@ export const api = {
   getPoolById: (id: string) => 
     apiRequest(API_ENDPOINTS.POOL_BY_ID(id)),
     
+  // 订阅匹配池实时事件，返回取消订阅函数；连接断开后浏览器自动重连并重新收到 snapshot
+  subscribePoolEvents: (id: string, onEvent: (event: PoolEvent) => void) => {
+    const source = new EventSource(`${API_BASE_URL}${API_ENDPOINTS.POOL_EVENTS(id)}`);
+    POOL_EVENT_TYPES.forEach(type => {
+      source.addEventListener(type, (e) => onEvent(JSON.parse((e as MessageEvent).data)));
+    });
+    return () => source.close();
+  },
+    
   joinPool: (data: any) => 
     apiRequest(API_ENDPOINTS.JOIN_POOL, {
       method: 'POST',
@@ -13,22 +13,47 @@ interface MatchRecord {
   status: 'completed' | 'in_progress';
 }
 
+interface MatchMember {
+  name: string;
+  data: { [key: string]: any };
+}
+
 interface MatchPair {
   pair: number;
   user1: string;
   user2?: string;
   user1Data: { [key: string]: any };
   user2Data?: { [key: string]: any };
+  members?: MatchMember[];
 }
 
 interface MatchDetails {
   pairs: MatchPair[];
 }
 
+interface MatchNotification {
+  id: number;
+  pair: number;
+  userId: number;
+  email: string;
+  status: 'pending' | 'sending' | 'sent' | 'failed' | 'skipped';
+  attempts: number;
+  lastError: string;
+}
+
+const NOTIFICATION_STATUS_LABELS: Record<MatchNotification['status'], string> = {
+  pending: '等待发送',
+  sending: '发送中',
+  sent: '已发送',
+  failed: '发送失败',
+  skipped: '无邮箱',
+};
+
 const AdminHistory: React.FC = () => {
   const [selectedRecord, setSelectedRecord] = useState<MatchRecord | null>(null);
   const [records, setRecords] = useState<MatchRecord[]>([]);
   const [matchDetails, setMatchDetails] = useState<MatchDetails | null>(null);
+  const [notifications, setNotifications] = useState<MatchNotification[]>([]);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
   const { isAdmin, adminToken, logout } = useAdmin();
@@ -107,10 +132,57 @@ const AdminHistory: React.FC = () => {
     }
   };
 
+  // 获取各配对的匹配结果通知发送状态
+  const fetchNotifications = async (recordId: number) => {
+    try {
+      const response = await fetch(
+        `${import.meta.env.VITE_API_BASE_URL || 'http://localhost:7776'}/api/history/${recordId}/notifications`,
+        {
+          headers: {
+            'Authorization': `Bearer ${adminToken}`,
+          },
+        }
+      );
+
+      const data = await response.json();
+      setNotifications(data.success && data.data ? data.data : []);
+    } catch (error) {
+      console.error('获取通知状态错误:', error);
+      setNotifications([]);
+    }
+  };
+
+  // 重新发送某个配对的匹配结果通知
+  const handleResendNotification = async (pairNumber: number) => {
+    if (!selectedRecord) return;
+
+    try {
+      const response = await fetch(
+        `${import.meta.env.VITE_API_BASE_URL || 'http://localhost:7776'}/api/history/${selectedRecord.id}/pairs/${pairNumber}/notify`,
+        {
+          method: 'POST',
+          headers: {
+            'Authorization': `Bearer ${adminToken}`,
+          },
+        }
+      );
+
+      const data = await response.json();
+      if (!data.success) {
+        setError(data.message || '重新发送通知失败');
+      }
+      await fetchNotifications(selectedRecord.id);
+    } catch (error) {
+      console.error('重新发送通知错误:', error);
+      setError('重新发送通知失败');
+    }
+  };
+
   const handleRecordClick = async (record: MatchRecord) => {
     setSelectedRecord(record);
     setMatchDetails(null);
-    await fetchMatchDetails(record.id);
+    setNotifications([]);
+    await Promise.all([fetchMatchDetails(record.id), fetchNotifications(record.id)]);
   };
 
   const handleLogout = () => {
@@ -202,24 +274,36 @@ const AdminHistory: React.FC = () => {
                   <div key={index} className="pair-card admin-pair">
                     <div className="pair-header">
                       <h5>配对 {pair.pair}</h5>
+                      {notifications
+                        .filter(notification => notification.pair === pair.pair)
+                        .map(notification => (
+                          <span
+                            key={notification.id}
+                            className={`notification-status ${notification.status}`}
+                            title={notification.lastError || notification.email}
+                          >
+                            📮 {NOTIFICATION_STATUS_LABELS[notification.status]}
+                          </span>
+                        ))}
+                      <button
+                        className="secondary-button"
+                        onClick={() => handleResendNotification(pair.pair)}
+                      >
+                        重新发送通知
+                      </button>
                     </div>
                     <div className="pair-users">
-                      <div className="user-info">
-                        <h6>用户1: {pair.user1}</h6>
-                        <div className="user-data">
-                          {Object.entries(pair.user1Data).map(([key, value]) => (
-                            <div key={key} className="data-item">
-                              <span className="data-key">{key}:</span>
-                              <span className="data-value">{String(value)}</span>
-                            </div>
-                          ))}
-                        </div>
-                      </div>
-                      {pair.user2 && pair.user2Data && (
-                        <div className="user-info">
-                          <h6>用户2: {pair.user2}</h6>
+                      {(pair.members && pair.members.length > 0
+                        ? pair.members
+                        : [
+                            { name: pair.user1, data: pair.user1Data },
+                            ...(pair.user2 && pair.user2Data ? [{ name: pair.user2, data: pair.user2Data }] : []),
+                          ]
+                      ).map((member, memberIndex) => (
+                        <div key={memberIndex} className="user-info">
+                          <h6>用户{memberIndex + 1}: {member.name}</h6>
                           <div className="user-data">
-                            {Object.entries(pair.user2Data).map(([key, value]) => (
+                            {Object.entries(member.data || {}).map(([key, value]) => (
                               <div key={key} className="data-item">
                                 <span className="data-key">{key}:</span>
                                 <span className="data-value">{String(value)}</span>
@@ -227,7 +311,7 @@ const AdminHistory: React.FC = () => {
                             ))}
                           </div>
                         </div>
-                      )}
+                      ))}
                     </div>
                   </div>
                 ))}
@@ -13,12 +13,18 @@ interface MatchRecord {
   status: 'completed' | 'in_progress';
 }
 
+interface MatchMember {
+  name: string;
+  data: { [key: string]: any };
+}
+
 interface MatchPair {
   pair: number;
   user1: string;
   user2?: string;
   user1Data: { [key: string]: any };
   user2Data?: { [key: string]: any };
+  members?: MatchMember[];
 }
 
 interface MatchDetails {
@@ -31,6 +37,7 @@ interface UserHistoryResult {
   data: Record<string, any>;
   partnerId?: string;
   partnerData?: Record<string, any>;
+  groupPartnersData?: Record<string, any>[]; // 多人分组时其他成员的信息
   isAlone?: boolean;
 }
 
@@ -54,6 +61,24 @@ const History: React.FC<HistoryProps> = () => {
     const users: UserHistoryResult[] = [];
 
     matchDetails.pairs.forEach(pair => {
+      // 多人分组：每名成员的匹配对象为组内其他成员
+      if (pair.members && pair.members.length > 2) {
+        const members = pair.members;
+        members.forEach((member, memberIndex) => {
+          const others = members.filter((_, otherIndex) => otherIndex !== memberIndex);
+          users.push({
+            name: member.name,
+            cn: member.data.cn || member.name,
+            data: member.data,
+            partnerId: others.map(other => other.name).join(', '),
+            partnerData: others[0].data,
+            groupPartnersData: others.map(other => other.data),
+            isAlone: false
+          });
+        });
+        return;
+      }
+
       // 添加用户1
       users.push({
         name: pair.user1,
@@ -289,8 +314,9 @@ const History: React.FC<HistoryProps> = () => {
                               <td>
                                 {user.partnerId && user.partnerData ? (
                                   <div className="partner-info">
-                                    <div className="partner-details">
-                                      {Object.entries(user.partnerData)
+                                    {(user.groupPartnersData || [user.partnerData]).map((partnerData, partnerIndex) => (
+                                    <div key={partnerIndex} className="partner-details">
+                                      {Object.entries(partnerData)
                                         .filter(([key]) => key !== 'cn') // 过滤掉cn字段以保持匿名性
                                         .map(([key, value]) => (
                                         <div key={key} className="detail-item">
@@ -299,6 +325,7 @@ const History: React.FC<HistoryProps> = () => {
                                         </div>
                                       ))}
                                     </div>
+                                    ))}
                                     <div className="anonymity-notice">
                                       <small>🎭 对方姓名已隐藏以保持匿名性</small>
                                     </div>
@@ -1,5 +1,5 @@
 import React, { useState, useEffect } from 'react';
-import { api } from '../config/api';
+import { api, PoolEvent } from '../config/api';
 import '../styles/Match.css';
 
 interface MatchPool {
@@ -8,7 +8,7 @@ interface MatchPool {
   description: string;
   userCount: number;
   validUntil: string;
-  status: 'active' | 'expired' | 'matched';
+  status: 'draft' | 'open' | 'closed' | 'matching' | 'matched' | 'archived';
   cooldownTime?: number;
   lastMatchedAt?: string;
 }
@@ -54,6 +54,41 @@ const Match: React.FC<MatchProps> = () => {
     loadPools();
   }, []);
 
+  // 实时更新所选匹配池的人数和状态
+  useEffect(() => {
+    if (!selectedPool) return;
+    const poolId = selectedPool.id;
+
+    const updatePool = (patch: Partial<MatchPool>) => {
+      setPools(prev => prev.map(pool => pool.id === poolId ? { ...pool, ...patch } : pool));
+      setSelectedPool(prev => prev && prev.id === poolId ? { ...prev, ...patch } : prev);
+    };
+
+    return api.subscribePoolEvents(String(poolId), (event: PoolEvent) => {
+      switch (event.type) {
+        case 'snapshot':
+        case 'pool.updated':
+          updatePool(event.data);
+          break;
+        case 'participant.joined':
+        case 'participant.left':
+        case 'participant.verified':
+          updatePool({ userCount: event.data.userCount });
+          break;
+        case 'status.changed':
+          updatePool({ status: event.data.to });
+          break;
+        case 'match.completed':
+          loadPools();
+          break;
+        case 'pool.deleted':
+          setPools(prev => prev.filter(pool => pool.id !== poolId));
+          setSelectedPool(null);
+          break;
+      }
+    });
+  }, [selectedPool?.id]);
+
   const loadPools = async () => {
     try {
       const response = await api.getPools();
@@ -323,7 +358,8 @@ const Match: React.FC<MatchProps> = () => {
             };
 
             const cooldownStatus = calculateCooldownStatus(pool);
-            const canMatch = pool.status === 'active' || !cooldownStatus.isInCooldown;
+            const canMatch = pool.status === 'open' || pool.status === 'closed' ||
+              (pool.status === 'matched' && !cooldownStatus.isInCooldown);
 
             return (
               <div 
@@ -334,10 +370,12 @@ const Match: React.FC<MatchProps> = () => {
                 <div className="pool-header">
                   <h4>{pool.name}</h4>
                   <span className={`pool-status ${pool.status}`}>
-                    {pool.status === 'active' ? '活跃' : 
-                     pool.status === 'matched' ? 
-                       (cooldownStatus.isInCooldown ? `冷却中 ${cooldownStatus.remainingTime}s` : '可重新匹配') : 
-                     '已过期'}
+                    {pool.status === 'open' ? '报名中' :
+                     pool.status === 'closed' ? '已截止' :
+                     pool.status === 'matching' ? '匹配中' :
+                     pool.status === 'matched' ?
+                       (cooldownStatus.isInCooldown ? `冷却中 ${cooldownStatus.remainingTime}s` : '可重新匹配') :
+                     pool.status === 'draft' ? '草稿' : '已归档'}
                   </span>
                 </div>
                 
@@ -5,17 +5,33 @@ import '../styles/Register.css';
 interface PoolField {
   name: string;
   label: string;
-  type: 'text' | 'textarea' | 'number' | 'email' | 'url';
+  type: 'text' | 'textarea' | 'number' | 'email' | 'url' | 'select' | 'multiselect' | 'boolean' | 'date';
   required: boolean;
+  options?: string[];
+  visibility?: 'public' | 'partner' | 'admin';
+  unique?: boolean;
 }
 
+const VISIBILITY_LABELS: Record<string, string> = {
+  public: '公开',
+  partner: '仅匹配对象可见',
+  admin: '仅管理员可见'
+};
+
+// 需要设置可选值的字段类型
+const CHOICE_TYPES: PoolField['type'][] = ['select', 'multiselect'];
+
 interface MatchPool {
   id: number;
   name: string;
   description: string;
   userCount: number;
+  maxParticipants: number;
+  waitlistCount: number;
+  verifyEmail: boolean;
+  pendingCount: number;
   validUntil: string;
-  status: 'active' | 'expired' | 'matched';
+  status: 'draft' | 'open' | 'closed' | 'matching' | 'matched' | 'archived';
   fields: PoolField[];
 }
 
@@ -69,6 +85,8 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
     validUntil: '',
     description: '',
     cooldownTime: 5, // 默认5秒冷却时间
+    verifyEmail: false,
+    notifyMatches: false,
     fields: [
       {
         name: 'cn',
@@ -83,9 +101,12 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
     name: '',
     label: '',
     type: 'text' as const,
-    required: false
+    required: false,
+    visibility: 'public'
   });
   
+  const [optionsText, setOptionsText] = useState('');
+  
   const [isSubmitting, setIsSubmitting] = useState(false);
   const [message, setMessage] = useState<{ type: 'success' | 'error', text: string } | null>(null);
 
@@ -95,6 +116,15 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
       return;
     }
 
+    // 选择类字段的可选值以逗号、顿号或换行分隔
+    const options = Array.from(new Set(
+      optionsText.split(/[,，、\n]/).map(option => option.trim()).filter(option => option)
+    ));
+    if (CHOICE_TYPES.includes(newField.type) && options.length === 0) {
+      setMessage({ type: 'error', text: '单选、多选字段至少需要一个可选值' });
+      return;
+    }
+
     // 防止添加cn字段（已经是必填字段）
     if (newField.name === 'cn') {
       setMessage({ type: 'error', text: 'cn字段已存在且为必填字段，无法重复添加' });
@@ -108,15 +138,20 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
 
     setPoolForm(prev => ({
       ...prev,
-      fields: [...prev.fields, { ...newField }]
+      fields: [...prev.fields, {
+        ...newField,
+        options: CHOICE_TYPES.includes(newField.type) ? options : undefined
+      }]
     }));
 
     setNewField({
       name: '',
       label: '',
       type: 'text',
-      required: false
+      required: false,
+      visibility: 'public'
     });
+    setOptionsText('');
 
     setMessage(null);
   };
@@ -176,6 +211,8 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
         validUntil: '',
         description: '',
         cooldownTime: 5,
+        verifyEmail: false,
+        notifyMatches: false,
         fields: [
           {
             name: 'cn',
@@ -261,6 +298,28 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
               />
               <small className="form-hint">匹配后需等待指定时间才能重新匹配，默认5秒</small>
             </div>
+
+            <div className="form-group checkbox-group">
+              <label>
+                <input
+                  type="checkbox"
+                  checked={poolForm.verifyEmail}
+                  onChange={(e) => setPoolForm(prev => ({ ...prev, verifyEmail: e.target.checked }))}
+                  disabled={isSubmitting}
+                />
+                要求验证邮箱
+              </label>
+              <label>
+                <input
+                  type="checkbox"
+                  checked={poolForm.notifyMatches}
+                  onChange={(e) => setPoolForm(prev => ({ ...prev, notifyMatches: e.target.checked }))}
+                  disabled={isSubmitting}
+                />
+                匹配后邮件通知参与者
+              </label>
+              <small className="form-hint">验证邮箱：参与者需填写邮箱并输入收到的验证码，验证通过后才参与匹配；邮件通知：匹配完成后把匹配对象发送到参与者的联系邮箱</small>
+            </div>
           </div>
 
           <div className="form-section">
@@ -305,9 +364,42 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                     <option value="number">数字</option>
                     <option value="email">邮箱</option>
                     <option value="url">链接</option>
+                    <option value="select">单选</option>
+                    <option value="multiselect">多选</option>
+                    <option value="boolean">勾选</option>
+                    <option value="date">日期</option>
                   </select>
                 </div>
 
+                <div className="form-group">
+                  <label>可见性</label>
+                  <select
+                    value={newField.visibility || 'public'}
+                    onChange={(e) => setNewField(prev => ({ 
+                      ...prev, 
+                      visibility: e.target.value as PoolField['visibility'] 
+                    }))}
+                    disabled={isSubmitting}
+                  >
+                    {Object.entries(VISIBILITY_LABELS).map(([value, label]) => (
+                      <option key={value} value={value}>{label}</option>
+                    ))}
+                  </select>
+                </div>
+
+                {CHOICE_TYPES.includes(newField.type) && (
+                  <div className="form-group">
+                    <label>可选值</label>
+                    <input
+                      type="text"
+                      value={optionsText}
+                      onChange={(e) => setOptionsText(e.target.value)}
+                      placeholder="以逗号分隔，如: S, M, L"
+                      disabled={isSubmitting}
+                    />
+                  </div>
+                )}
+
                 <div className="form-group checkbox-group">
                   <label>
                     <input
@@ -318,6 +410,15 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                     />
                     必填
                   </label>
+                  <label>
+                    <input
+                      type="checkbox"
+                      checked={!!newField.unique}
+                      onChange={(e) => setNewField(prev => ({ ...prev, unique: e.target.checked }))}
+                      disabled={isSubmitting}
+                    />
+                    不可重复
+                  </label>
                 </div>
 
                 <button
@@ -340,7 +441,12 @@ const CreatePoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                       <span className="field-name">{field.name}</span>
                       <span className="field-label">({field.label})</span>
                       <span className="field-type">[{field.type}]</span>
+                      {field.options && <span className="field-options">{field.options.join(' / ')}</span>}
+                      {field.visibility && field.visibility !== 'public' && (
+                        <span className="field-options">{VISIBILITY_LABELS[field.visibility]}</span>
+                      )}
                       {field.required && <span className="field-required">*必填</span>}
+                      {field.unique && <span className="field-options">不可重复</span>}
                       {field.name === 'cn' && <span className="field-primary">主键</span>}
                     </div>
                     <button
@@ -386,6 +492,8 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
   const [pools, setPools] = useState<MatchPool[]>([]);
   const [selectedPool, setSelectedPool] = useState<MatchPool | null>(null);
   const [userData, setUserData] = useState<Record<string, any>>({});
+  const [fieldErrors, setFieldErrors] = useState<Record<string, string>>({});
+  const [manageToken, setManageToken] = useState<string | null>(null);
   const [contactInfo, setContactInfo] = useState('');
   const [isLoading, setIsLoading] = useState(true);
   const [isSubmitting, setIsSubmitting] = useState(false);
@@ -408,7 +516,7 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
       
       // 确保poolsData是数组
       if (Array.isArray(poolsData)) {
-        const activePools = poolsData.filter((pool: MatchPool) => pool.status === 'active');
+        const activePools = poolsData.filter((pool: MatchPool) => pool.status === 'open');
         
         // 调试：检查每个池的fields字段
         activePools.forEach((pool, index) => {
@@ -478,6 +586,10 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
       ...prev,
       [fieldName]: value
     }));
+    setFieldErrors(prev => {
+      const { [fieldName]: _, ...rest } = prev;
+      return rest;
+    });
   };
 
   const handleSubmit = async (e: React.FormEvent) => {
@@ -490,7 +602,8 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
 
     // 验证必填字段
     for (const field of (selectedPool.fields || [])) {
-      if (field.required && !userData[field.name]) {
+      const value = userData[field.name];
+      if (field.required && (!value || (Array.isArray(value) && value.length === 0))) {
         setMessage({ type: 'error', text: `${field.label} 是必填字段` });
         return;
       }
@@ -505,21 +618,28 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
     setMessage(null);
     
     try {
-      await api.joinPool({
+      const response = await api.joinPool({
         poolId: selectedPool.id,
         userData,
         contactInfo
       });
       
-      setMessage({ type: 'success', text: `成功加入匹配池 "${selectedPool.name}"！` });
+      if (response?.data?.waitlisted || response?.data?.pendingVerification) {
+        setMessage({ type: 'success', text: response.message });
+      } else {
+        setMessage({ type: 'success', text: `成功加入匹配池 "${selectedPool.name}"！` });
+      }
+      setManageToken(response?.data?.manageToken || null);
       
       // 重置表单
       setSelectedPool(null);
       setUserData({});
+      setFieldErrors({});
       setContactInfo('');
       
     } catch (error) {
       console.error('加入匹配池失败:', error);
+      setFieldErrors((error as any)?.data?.fieldErrors || {});
       setMessage({ 
         type: 'error', 
         text: error instanceof Error ? error.message : '加入匹配池失败，请重试' 
@@ -548,6 +668,15 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
               {message.text}
             </div>
           )}
+
+          {manageToken && (
+            <div className="manage-token">
+              <p>🔑 您的管理令牌（只显示这一次，请妥善保存）：</p>
+              <code>{manageToken}</code>
+              <p>凭此令牌可以在"移除用户"页面查看、修改或退出报名，并在匹配完成后查询您的匹配对象。</p>
+              <p>如果匹配池要求验证邮箱，请在该页面输入邮件中的验证码（或直接打开邮件中的链接）。</p>
+            </div>
+          )}
           
           {pools.length === 0 ? (
             <div className="empty-state">
@@ -567,7 +696,11 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                   <h3>{pool.name}</h3>
                   <p>{pool.description}</p>
                   <div className="pool-meta">
-                    <span>👥 {pool.userCount} 人已加入</span>
+                    <span>
+                      👥 {pool.userCount}{pool.maxParticipants > 0 ? ` / ${pool.maxParticipants}` : ''} 人已加入
+                      {pool.waitlistCount > 0 && `（候补 ${pool.waitlistCount} 人）`}
+                      {pool.pendingCount > 0 && `（待验证邮箱 ${pool.pendingCount} 人）`}
+                    </span>
                     <span>⏰ {new Date(pool.validUntil).toLocaleString()}</span>
                   </div>
                 </div>
@@ -603,15 +736,65 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
               <div key={field.name} className="form-group">
                 <label htmlFor={field.name}>
                   {field.label} {field.required && '*'}
+                  {field.visibility && field.visibility !== 'public' && (
+                    <span className="field-options">（{VISIBILITY_LABELS[field.visibility]}）</span>
+                  )}
                 </label>
                 
-                {field.type === 'textarea' ? (
+                {field.type === 'select' ? (
+                  <select
+                    id={field.name}
+                    value={userData[field.name] || ''}
+                    onChange={(e) => handleFieldChange(field.name, e.target.value)}
+                    disabled={isSubmitting}
+                    className={fieldErrors[field.name] ? 'input-error' : ''}
+                  >
+                    <option value="">请选择</option>
+                    {(field.options || []).map(option => (
+                      <option key={option} value={option}>{option}</option>
+                    ))}
+                  </select>
+                ) : field.type === 'multiselect' ? (
+                  <div className={`choice-group ${fieldErrors[field.name] ? 'input-error' : ''}`}>
+                    {(field.options || []).map(option => {
+                      const selected: string[] = userData[field.name] || [];
+                      return (
+                        <label key={option} className="choice-option">
+                          <input
+                            type="checkbox"
+                            checked={selected.includes(option)}
+                            onChange={(e) => handleFieldChange(
+                              field.name,
+                              e.target.checked
+                                ? [...selected, option]
+                                : selected.filter(item => item !== option)
+                            )}
+                            disabled={isSubmitting}
+                          />
+                          {option}
+                        </label>
+                      );
+                    })}
+                  </div>
+                ) : field.type === 'boolean' ? (
+                  <label className="choice-option">
+                    <input
+                      type="checkbox"
+                      id={field.name}
+                      checked={userData[field.name] === true}
+                      onChange={(e) => handleFieldChange(field.name, e.target.checked)}
+                      disabled={isSubmitting}
+                    />
+                    {field.label}
+                  </label>
+                ) : field.type === 'textarea' ? (
                   <textarea
                     id={field.name}
                     value={userData[field.name] || ''}
                     onChange={(e) => handleFieldChange(field.name, e.target.value)}
                     rows={3}
                     disabled={isSubmitting}
+                    className={fieldErrors[field.name] ? 'input-error' : ''}
                   />
                 ) : (
                   <input
@@ -620,8 +803,12 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                     value={userData[field.name] || ''}
                     onChange={(e) => handleFieldChange(field.name, e.target.value)}
                     disabled={isSubmitting}
+                    className={fieldErrors[field.name] ? 'input-error' : ''}
                   />
                 )}
+                {fieldErrors[field.name] && (
+                  <span className="field-error">{fieldErrors[field.name]}</span>
+                )}
               </div>
             ))}
             
@@ -631,10 +818,20 @@ const JoinPoolForm: React.FC<{ onBack: () => void }> = ({ onBack }) => {
                 type="text"
                 id="contactInfo"
                 value={contactInfo}
-                onChange={(e) => setContactInfo(e.target.value)}
-                placeholder="用于移除时查找，如邮箱或手机号"
+                onChange={(e) => {
+                  setContactInfo(e.target.value);
+                  setFieldErrors(prev => {
+                    const { contactInfo: _, ...rest } = prev;
+                    return rest;
+                  });
+                }}
+                placeholder={selectedPool.verifyEmail ? '邮箱，加入后需要输入收到的验证码' : '邮箱或手机号，同一匹配池不能重复报名'}
                 disabled={isSubmitting}
+                className={fieldErrors.contactInfo ? 'input-error' : ''}
               />
+              {fieldErrors.contactInfo && (
+                <span className="field-error">{fieldErrors.contactInfo}</span>
+              )}
             </div>
           </div>
 
@@ -2,15 +2,30 @@ import React, { useState } from 'react';
 import { API_BASE_URL } from '../config/api';
 import '../styles/Remove.css';
 
-interface User {
-  id: number;
-  name: string;
-  contact_info: string;
-  cn: string;
-  filename: string;
-  pool_id: number;
-  pool_name: string;
-  created_at: string;
+interface ParticipantEntry {
+  userId: number;
+  poolId: number;
+  poolName: string;
+  status: string;
+  userData: Record<string, any>;
+  contactInfo: string;
+  joinedAt: string;
+  waitlisted: boolean;
+  waitlistPosition: number;
+  editable: boolean;
+  pendingVerification: boolean;
+}
+
+interface MatchRound {
+  recordId: number;
+  round: number;
+  poolName: string;
+  timestamp: string;
+  pairs: {
+    pair: number;
+    description?: string;
+    members: { userId: number; name: string; data: Record<string, any> }[];
+  }[];
 }
 
 interface RemoveProps {
@@ -19,41 +34,107 @@ interface RemoveProps {
 }
 
 const Remove: React.FC<RemoveProps> = ({ onNavigate }) => {
-  const [identifier, setIdentifier] = useState('');
+  const [token, setToken] = useState('');
   const [isSearching, setIsSearching] = useState(false);
   const [isRemoving, setIsRemoving] = useState(false);
   const [isRemoved, setIsRemoved] = useState(false);
-  const [userInfo, setUserInfo] = useState<User | null>(null);
+  const [userInfo, setUserInfo] = useState<ParticipantEntry | null>(null);
   const [error, setError] = useState<string | null>(null);
+  const [matches, setMatches] = useState<MatchRound[] | null>(null);
+  const [verificationCode, setVerificationCode] = useState('');
+  const [verifyNotice, setVerifyNotice] = useState<string | null>(null);
 
+  // 凭加入匹配池时获得的管理令牌查询本人的报名信息
   const handleSearch = async () => {
-    if (!identifier.trim()) return;
+    if (!token.trim()) return;
     
     try {
       setIsSearching(true);
       setError(null);
       
-      const response = await fetch(`${API_BASE_URL}/api/users/search?q=${encodeURIComponent(identifier)}`);
-      if (response.ok) {
-        const users = await response.json();
-        if (users && users.length > 0) {
-          setUserInfo(users[0]); // 取第一个匹配的用户
-        } else {
-          setError('未找到匹配的用户信息');
-        }
-      } else if (response.status === 404) {
-        setError('未找到匹配的用户信息');
+      const response = await fetch(`${API_BASE_URL}/api/participant`, {
+        headers: { 'X-Participant-Token': token.trim() },
+      });
+      const result = await response.json();
+      if (response.ok && result.success) {
+        setUserInfo(result.data);
+      } else if (response.status === 401) {
+        setError('管理令牌无效，请检查后重试');
       } else {
-        setError('查找用户失败，请稍后重试');
+        setError(result.message || '查找报名信息失败，请稍后重试');
       }
     } catch (err) {
       setError('网络错误，请稍后重试');
-      console.error('查找用户失败:', err);
+      console.error('查找报名信息失败:', err);
     } finally {
       setIsSearching(false);
     }
   };
 
+  // 提交邮件中的验证码
+  const handleVerify = async () => {
+    if (!verificationCode.trim()) return;
+
+    try {
+      setError(null);
+      const response = await fetch(`${API_BASE_URL}/api/participant/verify`, {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json', 'X-Participant-Token': token.trim() },
+        body: JSON.stringify({ code: verificationCode.trim() }),
+      });
+      const result = await response.json();
+      if (response.ok && result.success) {
+        setVerificationCode('');
+        setVerifyNotice(result.message);
+        await handleSearch();
+      } else {
+        setError(result.message || '邮箱验证失败，请稍后重试');
+      }
+    } catch (err) {
+      setError('网络错误，请稍后重试');
+      console.error('邮箱验证失败:', err);
+    }
+  };
+
+  // 重新发送验证码
+  const handleResend = async () => {
+    try {
+      setError(null);
+      const response = await fetch(`${API_BASE_URL}/api/participant/verify/resend`, {
+        method: 'POST',
+        headers: { 'X-Participant-Token': token.trim() },
+      });
+      const result = await response.json();
+      if (response.ok && result.success) {
+        setVerifyNotice(result.message);
+      } else {
+        setError(result.message || '重新发送失败，请稍后重试');
+      }
+    } catch (err) {
+      setError('网络错误，请稍后重试');
+      console.error('重新发送验证码失败:', err);
+    }
+  };
+
+  // 查询本人在各轮匹配中的配对
+  const handleLoadMatches = async () => {
+    try {
+      setError(null);
+      const response = await fetch(`${API_BASE_URL}/api/participant/matches`, {
+        headers: { 'X-Participant-Token': token.trim() },
+      });
+      const result = await response.json();
+      if (response.ok && result.success) {
+        setMatches(result.data || []);
+      } else {
+        setError(result.message || '获取匹配结果失败，请稍后重试');
+      }
+    } catch (err) {
+      setError('网络错误，请稍后重试');
+      console.error('获取匹配结果失败:', err);
+    }
+  };
+
   const handleRemove = async () => {
     if (!userInfo) return;
     
@@ -61,18 +142,20 @@ const Remove: React.FC<RemoveProps> = ({ onNavigate }) => {
       setIsRemoving(true);
       setError(null);
       
-      const response = await fetch(`${API_BASE_URL}/api/users/${userInfo.id}`, {
+      const response = await fetch(`${API_BASE_URL}/api/participant`, {
         method: 'DELETE',
+        headers: { 'X-Participant-Token': token.trim() },
       });
+      const result = await response.json();
       
-      if (response.ok) {
+      if (response.ok && result.success) {
         setIsRemoved(true);
       } else {
-        setError('移除失败，请稍后重试');
+        setError(result.message || '退出失败，请稍后重试');
       }
     } catch (err) {
       setError('网络错误，请稍后重试');
-      console.error('移除用户失败:', err);
+      console.error('退出报名失败:', err);
     } finally {
       setIsRemoving(false);
     }
@@ -91,7 +174,7 @@ const Remove: React.FC<RemoveProps> = ({ onNavigate }) => {
             onClick={() => {
               setIsRemoved(false);
               setUserInfo(null);
-              setIdentifier('');
+              setToken('');
               setError(null);
               onNavigate?.('/');
             }}
@@ -114,7 +197,7 @@ const Remove: React.FC<RemoveProps> = ({ onNavigate }) => {
             <h3>注意事项</h3>
             <ul>
               <li>移除后将无法继续参与匹配</li>
-              <li>已有的匹配记录将被清除</li>
+              <li>匹配开始后无法退出</li>
               <li>如需重新参与，需要重新注册</li>
             </ul>
           </div>
@@ -122,19 +205,19 @@ const Remove: React.FC<RemoveProps> = ({ onNavigate }) => {
 
         <div className="search-section">
           <div className="form-group">
-            <label>请输入注册时使用的姓名、邮箱或联系方式:</label>
+            <label>请输入加入匹配池时获得的管理令牌:</label>
             <div className="search-input-group">
               <input
                 type="text"
-                value={identifier}
-                onChange={(e) => setIdentifier(e.target.value)}
-                placeholder="姓名、邮箱、微信号或QQ号"
+                value={token}
+                onChange={(e) => setToken(e.target.value)}
+                placeholder="管理令牌"
                 onKeyPress={(e) => e.key === 'Enter' && handleSearch()}
               />
               <button 
                 className="search-btn"
                 onClick={handleSearch}
-                disabled={!identifier.trim() || isSearching}
+                disabled={!token.trim() || isSearching}
               >
                 {isSearching ? '查找中...' : '查找'}
               </button>
@@ -151,20 +234,86 @@ const Remove: React.FC<RemoveProps> = ({ onNavigate }) => {
 
         {userInfo && (
           <div className="user-info-section">
-            <h3>找到以下用户信息:</h3>
+            <h3>你的报名信息:</h3>
             <div className="user-card">
               <div className="user-avatar">👤</div>
               <div className="user-details">
-                <h4>{userInfo.name}</h4>
-                <p>联系方式: {userInfo.contact_info}</p>
-                <p>CN: {userInfo.cn}</p>
-                <p>文件名: {userInfo.filename}</p>
-                <p>所在池: {userInfo.pool_name}</p>
-                <p>注册时间: {new Date(userInfo.created_at).toLocaleDateString()}</p>
-                <p>状态: <span className="status active">活跃</span></p>
+                {Object.entries(userInfo.userData || {}).map(([key, value]) => (
+                  <p key={key}>{key}: {Array.isArray(value) ? value.join('、') : String(value)}</p>
+                ))}
+                <p>联系方式: {userInfo.contactInfo}</p>
+                <p>所在池: {userInfo.poolName}</p>
+                <p>注册时间: {userInfo.joinedAt}</p>
+                <p>
+                  状态: <span className="status active">
+                    {userInfo.waitlisted
+                      ? `候补第 ${userInfo.waitlistPosition} 位`
+                      : userInfo.pendingVerification ? '待验证邮箱' : userInfo.status}
+                  </span>
+                </p>
               </div>
             </div>
 
+            {userInfo.pendingVerification && (
+              <div className="form-group">
+                <label>请输入邮件中的验证码，验证通过后才会参与匹配:</label>
+                <div className="search-input-group">
+                  <input
+                    type="text"
+                    value={verificationCode}
+                    onChange={(e) => setVerificationCode(e.target.value)}
+                    placeholder="6 位验证码"
+                    onKeyPress={(e) => e.key === 'Enter' && handleVerify()}
+                  />
+                  <button
+                    className="search-btn"
+                    onClick={handleVerify}
+                    disabled={!verificationCode.trim()}
+                  >
+                    验证
+                  </button>
+                  <button className="cancel-btn" onClick={handleResend}>
+                    重新发送
+                  </button>
+                </div>
+              </div>
+            )}
+            {verifyNotice && <p>{verifyNotice}</p>}
+
+            <div className="button-group">
+              <button className="search-btn" onClick={handleLoadMatches}>
+                查看我的匹配
+              </button>
+            </div>
+
+            {matches && (
+              <div className="user-info-section">
+                {matches.length === 0 ? (
+                  <p>尚未匹配，请等待匹配完成</p>
+                ) : matches.map(match => (
+                  <div key={match.recordId} className="user-card">
+                    <div className="user-details">
+                      <h4>{match.poolName} · 第 {match.round} 轮（{match.timestamp}）</h4>
+                      {match.pairs.map(pair => (
+                        <div key={pair.pair}>
+                          {pair.description && <p>{pair.description}</p>}
+                          {pair.members
+                            .filter(member => member.userId !== userInfo.userId)
+                            .map(member => (
+                              <p key={member.userId}>
+                                {member.name}：{Object.entries(member.data || {})
+                                  .map(([key, value]) => `${key}=${Array.isArray(value) ? value.join('、') : value}`)
+                                  .join('，')}
+                              </p>
+                            ))}
+                        </div>
+                      ))}
+                    </div>
+                  </div>
+                ))}
+              </div>
+            )}
+
             <div className="confirm-section">
               <div className="confirm-box">
                 <h4>确认移除</h4>
@@ -174,6 +323,7 @@ const Remove: React.FC<RemoveProps> = ({ onNavigate }) => {
                     className="cancel-btn"
                     onClick={() => {
                       setUserInfo(null);
+                      setMatches(null);
                       setError(null);
                     }}
                   >